@@ -0,0 +1,160 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/creack/pty"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/sys/unix"
+)
+
+// PTY is the controlling side of a pseudo terminal a command runs in. Reads
+// return the output of the command and writes are its input.
+type PTY interface {
+	io.ReadWriteCloser
+	// Start starts cmd in a new session with the terminal as its controlling
+	// TTY.
+	Start(cmd *exec.Cmd) error
+	Resize(size TTYSize) error
+	// Signal sends sig to the foreground process group of the terminal.
+	Signal(sig syscall.Signal) error
+}
+
+// PTYFactory allocates pseudo terminals.
+type PTYFactory interface {
+	Open(modes gossh.TerminalModes) (PTY, error)
+}
+
+// DefaultPTYFactory allocates pseudo terminals of the OS.
+var DefaultPTYFactory PTYFactory = osPTYFactory{}
+
+type osPTYFactory struct{}
+
+// Open applies modes to the TTY right away, so the command never observes the
+// default modes.
+func (osPTYFactory) Open(modes gossh.TerminalModes) (PTY, error) {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyTerminalModes(tty, modes); err != nil {
+		ptmx.Close()
+		tty.Close()
+		return nil, fmt.Errorf("failed to apply terminal modes: %w", err)
+	}
+
+	// Control keeps ptmx from being closed while an ioctl is in progress.
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		ptmx.Close()
+		tty.Close()
+		return nil, err
+	}
+
+	return &osPTY{File: ptmx, tty: tty, conn: conn}, nil
+}
+
+type osPTY struct {
+	*os.File
+	conn syscall.RawConn
+
+	mu  sync.Mutex
+	tty *os.File
+	cmd *exec.Cmd
+}
+
+func (p *osPTY) Start(cmd *exec.Cmd) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tty == nil {
+		return errors.New("pty already started")
+	}
+	// The TTY is only needed by the command from here on.
+	defer func() {
+		p.tty.Close()
+		p.tty = nil
+	}()
+
+	cmd.Stdin = p.tty
+	cmd.Stdout = p.tty
+	cmd.Stderr = p.tty
+	// The command leads a new session with the TTY, its stdin, as controlling
+	// terminal. Nothing depends on the terminal of the daemon, which has none
+	// when it runs as a service. A process group of its own or foreground
+	// control of the daemon's terminal can't be combined with a new session.
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Foreground = false
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd = cmd
+
+	return nil
+}
+
+func (p *osPTY) Close() error {
+	p.mu.Lock()
+	if p.tty != nil {
+		p.tty.Close()
+		p.tty = nil
+	}
+	p.mu.Unlock()
+
+	return p.File.Close()
+}
+
+func (p *osPTY) Resize(size TTYSize) error {
+	var errno syscall.Errno
+	err := p.conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCSWINSZ),
+			uintptr(unsafe.Pointer(&struct{ h, w, x, y uint16 }{uint16(size.Height), uint16(size.Width), 0, 0})))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Signal sends sig to the foreground process group of the TTY, which is the
+// running job rather than the shell if the shell uses job control.
+func (p *osPTY) Signal(sig syscall.Signal) error {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.mu.Unlock()
+	if cmd == nil {
+		return errors.New("pty not started")
+	}
+
+	pgrp := 0
+	var err error
+	ctrlErr := p.conn.Control(func(fd uintptr) {
+		pgrp, err = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	})
+	if ctrlErr != nil || err != nil || pgrp <= 0 {
+		return cmd.Process.Signal(sig)
+	}
+
+	return syscall.Kill(-pgrp, sig)
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package common
+
+import "runtime"
+
+// SchedAttr are the scheduling attributes of processes started by
+// RunWithSchedAttr.
+type SchedAttr struct {
+	// Nice is the niceness, zero keeps the niceness of the caller. Negative
+	// values require CAP_SYS_NICE.
+	Nice int
+	// CPUAffinity lists the CPUs the processes may run on, empty keeps the
+	// affinity of the caller.
+	CPUAffinity []int
+}
+
+// RunWithSchedAttr runs fn on an OS thread with the given scheduling
+// attributes. Both are per-thread attributes on Linux that processes fn starts
+// inherit from the first instruction on, unlike with a setpriority or
+// sched_setaffinity after the start.
+func RunWithSchedAttr(attr SchedAttr, fn func() error) error {
+	if attr.Nice == 0 && len(attr.CPUAffinity) == 0 {
+		return fn()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		// The thread is never unlocked, so it exits with the goroutine instead
+		// of running other goroutines, as an unprivileged process can't lower
+		// the niceness again.
+		runtime.LockOSThread()
+
+		if err := setThreadSchedAttr(attr); err != nil {
+			errCh <- err
+			return
+		}
+
+		errCh <- fn()
+	}()
+
+	return <-errCh
+}
@@ -0,0 +1,33 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build linux
+
+package common
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setThreadSchedAttr applies attr to the calling thread.
+func setThreadSchedAttr(attr SchedAttr) error {
+	if attr.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), attr.Nice); err != nil {
+			return fmt.Errorf("failed to set niceness %d: %w", attr.Nice, err)
+		}
+	}
+
+	if len(attr.CPUAffinity) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range attr.CPUAffinity {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("failed to set CPU affinity %v: %w", attr.CPUAffinity, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build !linux
+
+package common
+
+import "errors"
+
+func setThreadSchedAttr(attr SchedAttr) error {
+	return errors.New("scheduling attributes are only supported on Linux")
+}
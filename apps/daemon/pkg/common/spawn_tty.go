@@ -4,14 +4,16 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
-	"unsafe"
+	"time"
 
-	"github.com/creack/pty"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 type TTYSize struct {
@@ -20,43 +22,144 @@ type TTYSize struct {
 }
 
 type SpawnTTYOptions struct {
+	// Ctx hangs up the shell once it is done, like a closed terminal would.
+	Ctx    context.Context
 	Dir    string
 	StdIn  io.Reader
 	StdOut io.Writer
 	Term   string
-	Env    []string
-	SizeCh <-chan TTYSize
+	// Shell is the program run in the PTY, GetShell() if empty.
+	Shell string
+	// BaseEnv is the environment the shell inherits, os.Environ() if nil. Env
+	// is added to it.
+	BaseEnv []string
+	Env     []string
+	SizeCh  <-chan TTYSize
+	// SignalCh delivers signals to the foreground process group of the TTY.
+	SignalCh <-chan syscall.Signal
+	// Modes are the terminal modes requested by the SSH client, applied to
+	// the TTY before the shell starts.
+	Modes gossh.TerminalModes
+	// PTY is the TTY the shell runs in. If nil, one is opened by PTYFactory,
+	// or DefaultPTYFactory if that is nil, too.
+	PTY        PTY
+	PTYFactory PTYFactory
+	// Sched are the scheduling attributes the shell starts with.
+	Sched SchedAttr
+	// KillGrace is how long the shell may take to exit after the hangup once
+	// Ctx is done, before its process group is killed. Zero never kills it.
+	KillGrace time.Duration
 }
 
+// SpawnTTY runs the shell in a PTY until it exits and returns its exit error
+// like exec.Cmd.Wait.
 func SpawnTTY(opts SpawnTTYOptions) error {
-	shell := GetShell()
-	cmd := exec.Command(shell)
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	shell := opts.Shell
+	if shell == "" {
+		shell = GetShell()
+	}
+	cmd := exec.CommandContext(ctx, shell)
+	// The shell leads its own session, so its process ID is the ID of its
+	// process group. Interactive shells ignore SIGTERM, so they are hung up
+	// like by a closed terminal instead.
+	var killMu sync.Mutex
+	var kill *time.Timer
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		if opts.KillGrace > 0 {
+			killMu.Lock()
+			kill = time.AfterFunc(opts.KillGrace, func() {
+				_ = syscall.Kill(-pgid, syscall.SIGKILL)
+			})
+			killMu.Unlock()
+		}
+		return syscall.Kill(-pgid, syscall.SIGHUP)
+	}
+	// The process group ID may be reused once the shell was waited for.
+	defer func() {
+		killMu.Lock()
+		defer killMu.Unlock()
+		if kill != nil {
+			kill.Stop()
+		}
+	}()
 
 	cmd.Dir = opts.Dir
 
 	cmd.Env = append(cmd.Env, fmt.Sprintf("TERM=%s", opts.Term))
-	cmd.Env = append(cmd.Env, os.Environ()...)
+	baseEnv := opts.BaseEnv
+	if baseEnv == nil {
+		baseEnv = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, baseEnv...)
 	cmd.Env = append(cmd.Env, fmt.Sprintf("SHELL=%s", shell))
 	cmd.Env = append(cmd.Env, opts.Env...)
 
-	f, err := pty.Start(cmd)
-	if err != nil {
-		return err
+	f := opts.PTY
+	if f == nil {
+		factory := opts.PTYFactory
+		if factory == nil {
+			factory = DefaultPTYFactory
+		}
+
+		var err error
+		f, err = factory.Open(opts.Modes)
+		if err != nil {
+			return err
+		}
 	}
 
 	defer f.Close()
 
+	// A size requested before the PTY was opened is applied right away, so
+	// the shell doesn't start with the default size.
+	select {
+	case win, ok := <-opts.SizeCh:
+		if ok {
+			_ = f.Resize(win)
+		}
+	default:
+	}
+
+	err := RunWithSchedAttr(opts.Sched, func() error {
+		return f.Start(cmd)
+	})
+	if err != nil {
+		return err
+	}
+
 	go func() {
 		for win := range opts.SizeCh {
-			syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCSWINSZ),
-				uintptr(unsafe.Pointer(&struct{ h, w, x, y uint16 }{uint16(win.Height), uint16(win.Width), 0, 0})))
+			_ = f.Resize(win)
 		}
 	}()
 
+	if opts.SignalCh != nil {
+		go func() {
+			for sig := range opts.SignalCh {
+				_ = f.Signal(sig)
+			}
+		}()
+	}
+
 	go func() {
 		io.Copy(f, opts.StdIn) // stdin
 	}()
 
 	_, err = io.Copy(opts.StdOut, f) // stdout
-	return err
+
+	// Closing the PTY hangs up the shell in case it still runs because its
+	// output couldn't be written.
+	_ = f.Close()
+	if cmd.Process == nil {
+		// The PTY didn't start the shell, like fakes in tests.
+		return err
+	}
+
+	return cmd.Wait()
 }
@@ -0,0 +1,136 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build linux
+
+package common
+
+import (
+	"os"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/sys/unix"
+)
+
+// sshDisabledChar disables a control character in SSH terminal modes. It is
+// mapped to the Linux _POSIX_VDISABLE value.
+const sshDisabledChar = 255
+
+var terminalControlChars = map[uint8]int{
+	gossh.VINTR:    unix.VINTR,
+	gossh.VQUIT:    unix.VQUIT,
+	gossh.VERASE:   unix.VERASE,
+	gossh.VKILL:    unix.VKILL,
+	gossh.VEOF:     unix.VEOF,
+	gossh.VEOL:     unix.VEOL,
+	gossh.VEOL2:    unix.VEOL2,
+	gossh.VSTART:   unix.VSTART,
+	gossh.VSTOP:    unix.VSTOP,
+	gossh.VSUSP:    unix.VSUSP,
+	gossh.VREPRINT: unix.VREPRINT,
+	gossh.VWERASE:  unix.VWERASE,
+	gossh.VLNEXT:   unix.VLNEXT,
+	gossh.VSWTCH:   unix.VSWTC,
+	gossh.VDISCARD: unix.VDISCARD,
+}
+
+var terminalInputFlags = map[uint8]uint32{
+	gossh.IGNPAR:  unix.IGNPAR,
+	gossh.PARMRK:  unix.PARMRK,
+	gossh.INPCK:   unix.INPCK,
+	gossh.ISTRIP:  unix.ISTRIP,
+	gossh.INLCR:   unix.INLCR,
+	gossh.IGNCR:   unix.IGNCR,
+	gossh.ICRNL:   unix.ICRNL,
+	gossh.IUCLC:   unix.IUCLC,
+	gossh.IXON:    unix.IXON,
+	gossh.IXANY:   unix.IXANY,
+	gossh.IXOFF:   unix.IXOFF,
+	gossh.IMAXBEL: unix.IMAXBEL,
+	gossh.IUTF8:   unix.IUTF8,
+}
+
+var terminalLocalFlags = map[uint8]uint32{
+	gossh.ISIG:    unix.ISIG,
+	gossh.ICANON:  unix.ICANON,
+	gossh.XCASE:   unix.XCASE,
+	gossh.ECHO:    unix.ECHO,
+	gossh.ECHOE:   unix.ECHOE,
+	gossh.ECHOK:   unix.ECHOK,
+	gossh.ECHONL:  unix.ECHONL,
+	gossh.NOFLSH:  unix.NOFLSH,
+	gossh.TOSTOP:  unix.TOSTOP,
+	gossh.IEXTEN:  unix.IEXTEN,
+	gossh.ECHOCTL: unix.ECHOCTL,
+	gossh.ECHOKE:  unix.ECHOKE,
+	gossh.PENDIN:  unix.PENDIN,
+}
+
+var terminalOutputFlags = map[uint8]uint32{
+	gossh.OPOST:  unix.OPOST,
+	gossh.OLCUC:  unix.OLCUC,
+	gossh.ONLCR:  unix.ONLCR,
+	gossh.OCRNL:  unix.OCRNL,
+	gossh.ONOCR:  unix.ONOCR,
+	gossh.ONLRET: unix.ONLRET,
+}
+
+var terminalControlFlags = map[uint8]uint32{
+	gossh.PARENB: unix.PARENB,
+	gossh.PARODD: unix.PARODD,
+}
+
+// applyTerminalModes sets the termios modes requested by an SSH client on
+// tty. Modes without a Linux equivalent, like the line speeds that have no
+// effect on a pseudo terminal, are ignored.
+func applyTerminalModes(tty *os.File, modes gossh.TerminalModes) error {
+	if len(modes) == 0 {
+		return nil
+	}
+
+	termios, err := unix.IoctlGetTermios(int(tty.Fd()), unix.TCGETS)
+	if err != nil {
+		return err
+	}
+
+	for opcode, value := range modes {
+		if index, ok := terminalControlChars[opcode]; ok {
+			if value == sshDisabledChar {
+				value = 0
+			}
+			termios.Cc[index] = uint8(value)
+			continue
+		}
+
+		switch opcode {
+		case gossh.CS7:
+			if value != 0 {
+				termios.Cflag = termios.Cflag&^unix.CSIZE | unix.CS7
+			}
+		case gossh.CS8:
+			if value != 0 {
+				termios.Cflag = termios.Cflag&^unix.CSIZE | unix.CS8
+			}
+		default:
+			setTerminalFlag(&termios.Iflag, terminalInputFlags, opcode, value)
+			setTerminalFlag(&termios.Lflag, terminalLocalFlags, opcode, value)
+			setTerminalFlag(&termios.Oflag, terminalOutputFlags, opcode, value)
+			setTerminalFlag(&termios.Cflag, terminalControlFlags, opcode, value)
+		}
+	}
+
+	return unix.IoctlSetTermios(int(tty.Fd()), unix.TCSETS, termios)
+}
+
+func setTerminalFlag(flags *uint32, known map[uint8]uint32, opcode uint8, value uint32) {
+	flag, ok := known[opcode]
+	if !ok {
+		return
+	}
+
+	if value != 0 {
+		*flags |= flag
+	} else {
+		*flags &^= flag
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build !linux
+
+package common
+
+import (
+	"os"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// applyTerminalModes ignores the modes requested by an SSH client, their
+// termios equivalents are only mapped for Linux.
+func applyTerminalModes(tty *os.File, modes gossh.TerminalModes) error {
+	return nil
+}
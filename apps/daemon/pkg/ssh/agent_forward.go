@@ -0,0 +1,126 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// agentSocketPattern matches the directories created by ssh.NewAgentListener.
+const agentSocketPattern = "auth-agent*"
+
+// agentListener removes the temporary directory ssh.NewAgentListener created
+// for the socket once it is closed. Closing the listener only unlinks the
+// socket itself.
+type agentListener struct {
+	net.Listener
+	server *Server
+	dir    string
+}
+
+func (l *agentListener) Close() error {
+	err := l.Listener.Close()
+	l.server.agentDirs.Delete(l.dir)
+
+	if rmErr := os.RemoveAll(l.dir); rmErr != nil {
+		log.Warnf("Failed to remove agent socket directory %s: %v", l.dir, rmErr)
+	}
+
+	return err
+}
+
+// startAgentForwarding serves the agent forwarded by the client of session on
+// a new socket. The returned listener must be closed on session teardown.
+func (s *Server) startAgentForwarding(session ssh.Session) (net.Listener, error) {
+	l, err := ssh.NewAgentListener()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(l.Addr().String())
+	s.agentDirs.Store(dir, struct{}{})
+
+	listener := &agentListener{
+		Listener: l,
+		server:   s,
+		dir:      dir,
+	}
+	go ssh.ForwardAgentConnections(listener, session)
+
+	return listener, nil
+}
+
+// forwardAgent starts agent forwarding if the client of session requested it
+// and returns the listener to close on teardown, nil if it didn't or
+// forwarding failed. It ends the session and returns false if forwarding
+// failed and RequireAgentForwarding is set.
+func (s *Server) forwardAgent(session ssh.Session) (net.Listener, bool) {
+	if !agentForwardingAllowed(session) {
+		return nil, true
+	}
+
+	l, err := s.startAgentForwarding(session)
+	if err == nil {
+		return l, true
+	}
+
+	if !s.RequireAgentForwarding {
+		log.Warnf("Starting session %s of user %s without agent forwarding: %v", session.Context().SessionID(), session.User(), err)
+		return nil, true
+	}
+
+	log.Errorf("Failed to start agent listener: %v", err)
+	_, _ = fmt.Fprintln(session.Stderr(), "Agent forwarding is unavailable")
+	setCloseReason(session, CloseReasonError)
+	_ = session.Exit(1)
+	return nil, false
+}
+
+// removeAgentSockets removes the agent sockets of all sessions still running
+// on server shutdown.
+func (s *Server) removeAgentSockets() {
+	s.agentDirs.Range(func(dir, _ any) bool {
+		if err := os.RemoveAll(dir.(string)); err != nil {
+			log.Warnf("Failed to remove agent socket directory %s: %v", dir, err)
+		}
+		s.agentDirs.Delete(dir)
+		return true
+	})
+}
+
+// removeStaleAgentSockets removes agent sockets left behind by a previous run
+// that was killed before its sessions were torn down. Sockets still accepting
+// connections belong to another process and are kept.
+func removeStaleAgentSockets() {
+	dirs, err := filepath.Glob(filepath.Join(os.TempDir(), agentSocketPattern))
+	if err != nil {
+		return
+	}
+
+	for _, dir := range dirs {
+		socket := filepath.Join(dir, "listener.sock")
+		if _, err := os.Lstat(socket); err != nil {
+			continue
+		}
+
+		conn, err := net.DialTimeout("unix", socket, time.Second)
+		if err == nil {
+			conn.Close()
+			continue
+		}
+
+		log.Debugf("Removing stale agent socket %s", socket)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("Failed to remove stale agent socket directory %s: %v", dir, err)
+		}
+	}
+}
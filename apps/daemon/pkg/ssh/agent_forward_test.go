@@ -0,0 +1,99 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAgentSocketIsRemovedAfterSession(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	addr := startTestServer(t, newTestServer(t))
+	client := dialTestServer(t, addr)
+	require.NoError(t, agent.ForwardToAgent(client, agent.NewKeyring()))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, agent.RequestAgentForwarding(session))
+
+	output, err := session.Output(`test -S "$SSH_AUTH_SOCK" && echo "$SSH_AUTH_SOCK"`)
+	require.NoError(t, err)
+
+	socket := strings.TrimSpace(string(output))
+	require.NotEmpty(t, socket)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Dir(socket))
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRemoveStaleAgentSockets(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	listen := func(name string) *net.UnixListener {
+		dir := filepath.Join(tmp, name)
+		require.NoError(t, os.Mkdir(dir, 0700))
+
+		l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(dir, "listener.sock"), Net: "unix"})
+		require.NoError(t, err)
+		return l
+	}
+
+	stale := listen("auth-agent-stale")
+	stale.SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	live := listen("auth-agent-live")
+	defer live.Close()
+
+	removeStaleAgentSockets()
+
+	require.NoDirExists(t, filepath.Join(tmp, "auth-agent-stale"))
+	require.FileExists(t, filepath.Join(tmp, "auth-agent-live", "listener.sock"))
+}
+
+func TestAgentForwardingFailure(t *testing.T) {
+	for name, tc := range map[string]struct {
+		required bool
+		output   string
+		err      bool
+	}{
+		"optional": {output: "started without agent\n"},
+		"required": {required: true, output: "Agent forwarding is unavailable\n", err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.RequireAgentForwarding = tc.required
+			client := dialTestServer(t, startTestServer(t, s))
+			require.NoError(t, agent.ForwardToAgent(client, agent.NewKeyring()))
+			// Without a temporary directory the agent socket can't be created.
+			t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			require.NoError(t, agent.RequestAgentForwarding(session))
+
+			output, err := session.CombinedOutput(`test -z "$SSH_AUTH_SOCK" && echo started without agent`)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.output, string(output))
+		})
+	}
+}
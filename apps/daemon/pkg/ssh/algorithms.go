@@ -0,0 +1,85 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// The default algorithms are those preferred by golang.org/x/crypto/ssh
+// without the ones based on SHA-1.
+var (
+	defaultKeyExchanges = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256",
+	}
+	defaultCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com",
+		"chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	}
+	defaultMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512",
+	}
+)
+
+// algorithmsConfig returns the algorithms clients may negotiate.
+func (s *Server) algorithmsConfig() gossh.Config {
+	config := gossh.Config{
+		KeyExchanges: defaultKeyExchanges,
+		Ciphers:      defaultCiphers,
+		MACs:         defaultMACs,
+	}
+
+	if len(s.KeyExchanges) > 0 {
+		config.KeyExchanges = s.KeyExchanges
+	}
+	if len(s.Ciphers) > 0 {
+		config.Ciphers = s.Ciphers
+	}
+	if len(s.MACs) > 0 {
+		config.MACs = s.MACs
+	}
+
+	return config
+}
+
+// validateAlgorithms rejects algorithms golang.org/x/crypto/ssh doesn't
+// implement, which it would otherwise silently drop.
+func (s *Server) validateAlgorithms() error {
+	config := s.algorithmsConfig()
+
+	supported := config
+	supported.SetDefaults()
+
+	for _, kind := range []struct {
+		name                string
+		requested, accepted []string
+	}{
+		{"key exchange", config.KeyExchanges, supported.KeyExchanges},
+		{"cipher", config.Ciphers, supported.Ciphers},
+		{"MAC", config.MACs, supported.MACs},
+	} {
+		for _, algorithm := range kind.requested {
+			if !slices.Contains(kind.accepted, algorithm) {
+				return fmt.Errorf("unsupported %s algorithm %q", kind.name, algorithm)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) serverConfig(ctx ssh.Context) *gossh.ServerConfig {
+	return &gossh.ServerConfig{
+		Config:       s.algorithmsConfig(),
+		MaxAuthTries: s.MaxAuthTries,
+	}
+}
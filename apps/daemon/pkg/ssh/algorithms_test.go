@@ -0,0 +1,68 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func dialWithAlgorithms(addr string, config gossh.Config) error {
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		Config:          config,
+		User:            "daytona",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+func TestRestrictedAlgorithms(t *testing.T) {
+	s := newTestServer(t)
+	s.KeyExchanges = []string{"curve25519-sha256"}
+	s.Ciphers = []string{"aes256-gcm@openssh.com"}
+	s.MACs = []string{"hmac-sha2-512-etm@openssh.com"}
+	require.NoError(t, s.validateAlgorithms())
+
+	addr := startTestServer(t, s)
+
+	require.NoError(t, dialWithAlgorithms(addr, gossh.Config{
+		KeyExchanges: []string{"curve25519-sha256"},
+		Ciphers:      []string{"aes256-gcm@openssh.com"},
+	}))
+
+	err := dialWithAlgorithms(addr, gossh.Config{Ciphers: []string{"aes128-ctr"}})
+	require.ErrorContains(t, err, "no common algorithm for client to server cipher")
+
+	err = dialWithAlgorithms(addr, gossh.Config{KeyExchanges: []string{"ecdh-sha2-nistp256"}})
+	require.ErrorContains(t, err, "no common algorithm for key exchange")
+}
+
+func TestDefaultAlgorithmsRejectSHA1(t *testing.T) {
+	addr := startTestServer(t, newTestServer(t))
+
+	require.NoError(t, dialWithAlgorithms(addr, gossh.Config{}))
+
+	err := dialWithAlgorithms(addr, gossh.Config{
+		Ciphers: []string{"aes128-ctr"},
+		MACs:    []string{"hmac-sha1"},
+	})
+	require.ErrorContains(t, err, "no common algorithm for client to server MAC")
+
+	err = dialWithAlgorithms(addr, gossh.Config{KeyExchanges: []string{"diffie-hellman-group14-sha1"}})
+	require.ErrorContains(t, err, "no common algorithm for key exchange")
+}
+
+func TestValidateAlgorithms(t *testing.T) {
+	s := newTestServer(t)
+	s.Ciphers = []string{"aes128-ctr", "rot13"}
+
+	require.EqualError(t, s.validateAlgorithms(), `unsupported cipher algorithm "rot13"`)
+}
@@ -0,0 +1,269 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// attachSubsystem attaches admins to the PTY session selected with the
+// DAYTONA_ATTACH_SESSION variable, the ID listed by Server.Sessions. With
+// DAYTONA_ATTACH_MODE=control their input is passed to the shell next to the
+// input of its user, otherwise they only view the output.
+const (
+	attachSubsystem  = "daytona-attach"
+	attachSessionEnv = "DAYTONA_ATTACH_SESSION"
+	attachModeEnv    = "DAYTONA_ATTACH_MODE"
+
+	attachModeView    = "view"
+	attachModeControl = "control"
+
+	attachViewerBacklog = 256
+
+	attachEndedMessage  = "The session ended."
+	attachDeniedMessage = "Permission denied: attaching to sessions requires admin access."
+)
+
+var errAttachDisabled = errors.New("attaching to sessions is not enabled")
+
+// ptyAttach fans the output of a PTY session out to attached viewers and
+// merges their input with the input of the session.
+type ptyAttach struct {
+	mu      sync.Mutex
+	viewers map[*ptyViewer]struct{}
+	input   *io.PipeWriter
+	closed  bool
+	done    chan struct{}
+}
+
+type ptyViewer struct {
+	output  chan []byte
+	dropped int
+}
+
+func newPTYAttach() *ptyAttach {
+	return &ptyAttach{
+		viewers: map[*ptyViewer]struct{}{},
+		done:    make(chan struct{}),
+	}
+}
+
+// output returns the writer the PTY output of the session is copied to,
+// which copies to attached viewers, too.
+func (a *ptyAttach) output(w io.Writer) io.Writer {
+	return &attachWriter{Writer: w, attach: a}
+}
+
+// stdin returns the reader the shell reads its input from, the input of the
+// session merged with the input of viewers in control mode.
+func (a *ptyAttach) stdin(session io.Reader) io.Reader {
+	r, w := io.Pipe()
+
+	a.mu.Lock()
+	a.input = w
+	a.mu.Unlock()
+
+	go func() {
+		_, err := io.Copy(w, session)
+		_ = w.CloseWithError(err)
+	}()
+
+	return r
+}
+
+// broadcast copies p to every viewer. Viewers never slow down the session,
+// output is dropped for those falling behind.
+func (a *ptyAttach) broadcast(p []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.viewers) == 0 {
+		return
+	}
+
+	chunk := append([]byte(nil), p...)
+	for viewer := range a.viewers {
+		select {
+		case viewer.output <- chunk:
+		default:
+			viewer.dropped++
+		}
+	}
+}
+
+// writeInput passes the input of a viewer in control mode to the shell.
+func (a *ptyAttach) writeInput(p []byte) (int, error) {
+	a.mu.Lock()
+	input := a.input
+	a.mu.Unlock()
+
+	if input == nil {
+		return 0, io.ErrClosedPipe
+	}
+	// Writes to the pipe are serialized with the input of the session.
+	return input.Write(p)
+}
+
+func (a *ptyAttach) addViewer() (*ptyViewer, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, false
+	}
+
+	viewer := &ptyViewer{output: make(chan []byte, attachViewerBacklog)}
+	a.viewers[viewer] = struct{}{}
+	return viewer, true
+}
+
+func (a *ptyAttach) removeViewer(viewer *ptyViewer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.viewers, viewer)
+}
+
+func (a *ptyAttach) takeDropped(viewer *ptyViewer) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	dropped := viewer.dropped
+	viewer.dropped = 0
+	return dropped
+}
+
+// close detaches all viewers once the session ended and stops passing input
+// to the shell, which no longer reads it.
+func (a *ptyAttach) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.closed {
+		a.closed = true
+		close(a.done)
+		if a.input != nil {
+			_ = a.input.Close()
+		}
+	}
+}
+
+type attachWriter struct {
+	io.Writer
+	attach *ptyAttach
+}
+
+func (w *attachWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.attach.broadcast(p[:n])
+	return n, err
+}
+
+// ptyAttachIO returns the input and output of the shell of a PTY session,
+// which admins may attach to if SessionAttach is set.
+func ptyAttachIO(session ssh.Session, stdin io.Reader, stdout io.Writer) (io.Reader, io.Writer) {
+	tracked, ok := session.(*trackedSession)
+	if !ok || tracked.attach == nil {
+		return stdin, stdout
+	}
+
+	return tracked.attach.stdin(stdin), tracked.attach.output(stdout)
+}
+
+func (s *Server) attachHandler(session ssh.Session) error {
+	identity, _ := IdentityFromContext(session.Context())
+	if !identity.Admin {
+		log.Warnf("Denying %s subsystem to user %s of session %s", attachSubsystem, session.User(), session.Context().SessionID())
+		_, _ = fmt.Fprintln(session.Stderr(), attachDeniedMessage)
+		setCloseReason(session, CloseReasonPolicyDenied)
+		_ = session.Exit(1)
+		return nil
+	}
+
+	if !s.SessionAttach {
+		return errAttachDisabled
+	}
+
+	env := environMap(session.Environ())
+	id := env[attachSessionEnv]
+	mode := env[attachModeEnv]
+	if mode == "" {
+		mode = attachModeView
+	}
+	if mode != attachModeView && mode != attachModeControl {
+		return fmt.Errorf("unknown attach mode %q", mode)
+	}
+
+	attach := s.attachPoint(id)
+	if attach == nil {
+		return fmt.Errorf("no PTY session with ID %q", id)
+	}
+
+	viewer, ok := attach.addViewer()
+	if !ok {
+		return fmt.Errorf("no PTY session with ID %q", id)
+	}
+	defer attach.removeViewer(viewer)
+
+	log.Infof("User %s of session %s attached to session %s in %s mode", session.User(), session.Context().SessionID(), id, mode)
+	defer log.Infof("User %s of session %s detached from session %s", session.User(), session.Context().SessionID(), id)
+
+	// The session context only ends with the connection, so viewers are
+	// detached as soon as they close their side of the channel.
+	detached := make(chan struct{})
+	go func() {
+		defer close(detached)
+		if mode == attachModeControl {
+			_, _ = io.Copy(writerFunc(attach.writeInput), session)
+			return
+		}
+		_, _ = io.Copy(io.Discard, session)
+	}()
+
+	for {
+		select {
+		case p := <-viewer.output:
+			if dropped := attach.takeDropped(viewer); dropped > 0 {
+				_, _ = fmt.Fprintf(session.Stderr(), "\r\n[%d chunks of output dropped]\r\n", dropped)
+			}
+			if _, err := session.Write(p); err != nil {
+				logSessionError(log.WarnLevel, err, "Failed to write to attached session %s: %v", session.Context().SessionID(), err)
+				return nil
+			}
+		case <-attach.done:
+			_, _ = fmt.Fprintln(session.Stderr(), attachEndedMessage)
+			_ = session.Exit(0)
+			return nil
+		case <-detached:
+			return nil
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// environMap returns the variables of env by name, the last one winning.
+func environMap(env []string) map[string]string {
+	vars := map[string]string{}
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
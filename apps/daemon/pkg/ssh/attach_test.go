@@ -0,0 +1,175 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// attachedOutput collects the output of an attached channel.
+type attachedOutput struct {
+	mu  sync.Mutex
+	out strings.Builder
+}
+
+func (o *attachedOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.out.Write(p)
+}
+
+func (o *attachedOutput) contains(s string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.Contains(o.out.String(), s)
+}
+
+func openAttachSubsystem(t *testing.T, client *gossh.Client, id, mode string) (gossh.Channel, <-chan *gossh.Request) {
+	t.Helper()
+
+	ch, reqs, err := client.OpenChannel("session", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ch.Close()
+	})
+
+	for name, value := range map[string]string{attachSessionEnv: id, attachModeEnv: mode} {
+		ok, err := ch.SendRequest("env", true, gossh.Marshal(struct{ Name, Value string }{name, value}))
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	ok, err := ch.SendRequest("subsystem", true, gossh.Marshal(struct{ Name string }{attachSubsystem}))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	return ch, reqs
+}
+
+func TestAttach(t *testing.T) {
+	s := newTestServer(t)
+	s.SessionAttach = true
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{
+			"admin": {ID: "admin", Admin: true},
+			"user":  {ID: "user"},
+		},
+	}
+	addr := startTestServer(t, s)
+
+	// startShell starts the PTY shell of the primary session and returns its
+	// input and the ID to attach to.
+	started := map[string]bool{}
+	startShell := func(t *testing.T) (*gossh.Session, io.Writer, string) {
+		session, err := dialTestServer(t, addr, gossh.Password("user")).NewSession()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = session.Close()
+		})
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+		stdin, err := session.StdinPipe()
+		require.NoError(t, err)
+		session.Stdout = io.Discard
+		require.NoError(t, session.Shell())
+
+		var id string
+		require.Eventually(t, func() bool {
+			for _, info := range s.Sessions() {
+				if info.PTY && !started[info.ID] {
+					id = info.ID
+					started[id] = true
+					return true
+				}
+			}
+			return false
+		}, 5*time.Second, 10*time.Millisecond)
+
+		return session, stdin, id
+	}
+
+	t.Run("view", func(t *testing.T) {
+		session, stdin, id := startShell(t)
+
+		ch, reqs := openAttachSubsystem(t, dialTestServer(t, addr, gossh.Password("admin")), id, attachModeView)
+		output := &attachedOutput{}
+		go func() {
+			_, _ = io.Copy(output, ch)
+		}()
+
+		// Viewers only see output written after they attached.
+		require.Eventually(t, func() bool {
+			_, err := fmt.Fprintln(stdin, "echo view-$((40 + 2))")
+			require.NoError(t, err)
+			return output.contains("view-42")
+		}, 15*time.Second, 100*time.Millisecond)
+
+		// Input of viewers isn't passed to the shell.
+		_, err := ch.Write([]byte("echo ignored-$((1 + 1))\n"))
+		require.NoError(t, err)
+
+		_, err = fmt.Fprintln(stdin, "exit 0")
+		require.NoError(t, err)
+		require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+		require.Equal(t, uint32(0), waitExitStatus(t, reqs))
+		stderr, err := io.ReadAll(ch.Stderr())
+		require.NoError(t, err)
+		require.Equal(t, attachEndedMessage+"\n", string(stderr))
+		require.False(t, output.contains("ignored-2"))
+	})
+
+	t.Run("control", func(t *testing.T) {
+		session, _, id := startShell(t)
+
+		ch, _ := openAttachSubsystem(t, dialTestServer(t, addr, gossh.Password("admin")), id, attachModeControl)
+		output := &attachedOutput{}
+		go func() {
+			_, _ = io.Copy(output, ch)
+		}()
+
+		require.Eventually(t, func() bool {
+			_, err := ch.Write([]byte("echo control-$((40 + 2))\n"))
+			require.NoError(t, err)
+			return output.contains("control-42")
+		}, 15*time.Second, 100*time.Millisecond)
+
+		// The admin takes over the shell of the session.
+		_, err := ch.Write([]byte("exit 3\n"))
+		require.NoError(t, err)
+
+		err = runWithTimeout(t, 10*time.Second, session.Wait)
+		var exitErr *gossh.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 3, exitErr.ExitStatus())
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		_, _, id := startShell(t)
+
+		ch, reqs := openAttachSubsystem(t, dialTestServer(t, addr, gossh.Password("user")), id, attachModeView)
+
+		require.Equal(t, uint32(1), waitExitStatus(t, reqs))
+		stderr, err := io.ReadAll(ch.Stderr())
+		require.NoError(t, err)
+		require.Equal(t, attachDeniedMessage+"\n", string(stderr))
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		ch, reqs := openAttachSubsystem(t, dialTestServer(t, addr, gossh.Password("admin")), "999", attachModeView)
+
+		require.Equal(t, uint32(1), waitExitStatus(t, reqs))
+		stderr, err := io.ReadAll(ch.Stderr())
+		require.NoError(t, err)
+		require.Contains(t, string(stderr), `no PTY session with ID "999"`)
+	})
+}
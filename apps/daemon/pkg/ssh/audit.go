@@ -0,0 +1,155 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AuditSchemaVersion is the version of AuditRecord. Within a version fields
+// are only added, never renamed, removed or changed in meaning.
+const AuditSchemaVersion = 1
+
+// Audit events.
+const (
+	AuditSessionStart = "session_start"
+	AuditSessionEnd   = "session_end"
+)
+
+// AuditRecord is a line of the audit log, a JSON object per event:
+//
+//	schema_version  AuditSchemaVersion the record was written with
+//	event           session_start or session_end
+//	time            RFC 3339 time of the event
+//	session_id      ID of the SSH session
+//	channel_id      ID of the session channel as listed by Server.Sessions
+//	user            user name the client logged in with
+//	identity        ID of the Identity it authenticated as, if any
+//	tenant          tenant the connection is tagged with, if any
+//	remote_addr     IP address of the client
+//	local_addr      address the client connected to
+//	client_version  SSH version string of the client
+//	subsystem       name of the subsystem, if one was requested
+//	command         command as requested by the client, if any
+//	pty             whether the session has a PTY
+//
+// session_end records add:
+//
+//	exit_code       exit status sent to the client
+//	reason          CloseReason the session ended with
+//	duration_ms     duration of the session in milliseconds
+//	bytes_in        bytes of input the session read
+//	bytes_out       bytes of output the session wrote, including stderr
+//	stderr_tail     last lines of stderr of a failed command, if
+//	                StderrTailLines is set
+type AuditRecord struct {
+	SchemaVersion int       `json:"schema_version"`
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	SessionID     string    `json:"session_id"`
+	ChannelID     string    `json:"channel_id"`
+	User          string    `json:"user"`
+	Identity      string    `json:"identity"`
+	Tenant        string    `json:"tenant"`
+	RemoteAddr    string    `json:"remote_addr"`
+	LocalAddr     string    `json:"local_addr"`
+	ClientVersion string    `json:"client_version"`
+	Subsystem     string    `json:"subsystem"`
+	Command       string    `json:"command"`
+	PTY           bool      `json:"pty"`
+
+	ExitCode   *int        `json:"exit_code,omitempty"`
+	Reason     CloseReason `json:"reason,omitempty"`
+	DurationMS *int64      `json:"duration_ms,omitempty"`
+	BytesIn    *int64      `json:"bytes_in,omitempty"`
+	BytesOut   *int64      `json:"bytes_out,omitempty"`
+	StderrTail string      `json:"stderr_tail,omitempty"`
+}
+
+func newAuditRecord(event string, session *trackedSession) AuditRecord {
+	ctx := session.Context()
+	identity, _ := IdentityFromContext(ctx)
+	_, _, isPty := session.Pty()
+
+	record := AuditRecord{
+		SchemaVersion: AuditSchemaVersion,
+		Event:         event,
+		Time:          time.Now().UTC(),
+		SessionID:     ctx.SessionID(),
+		ChannelID:     session.id,
+		User:          session.User(),
+		Identity:      identity.ID,
+		Tenant:        identity.Tenant,
+		LocalAddr:     ctx.LocalAddr().String(),
+		ClientVersion: ctx.ClientVersion(),
+		Subsystem:     session.Subsystem(),
+		Command:       session.RawCommand(),
+		PTY:           isPty,
+	}
+	if ip := remoteIP(ctx); ip != nil {
+		record.RemoteAddr = ip.String()
+	}
+	return record
+}
+
+func (s *Server) auditSessionStart(session *trackedSession) {
+	if s.AuditLog == nil {
+		return
+	}
+
+	s.writeAudit(newAuditRecord(AuditSessionStart, session))
+}
+
+func (s *Server) auditSessionEnd(session *trackedSession, end SessionEnd, bytesIn, bytesOut int64) {
+	if s.AuditLog == nil {
+		return
+	}
+
+	record := newAuditRecord(AuditSessionEnd, session)
+	durationMS := end.Duration.Milliseconds()
+	record.ExitCode = &end.ExitCode
+	record.Reason = end.Reason
+	record.DurationMS = &durationMS
+	record.BytesIn = &bytesIn
+	record.BytesOut = &bytesOut
+	record.StderrTail = end.StderrTail
+	s.writeAudit(record)
+}
+
+// writeAudit writes record as a single line, so records of concurrent
+// sessions never interleave.
+func (s *Server) writeAudit(record AuditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Warnf("Failed to encode audit record of session %s: %v", record.SessionID, err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	if _, err := s.AuditLog.Write(line); err != nil {
+		log.Warnf("Failed to write audit record of session %s: %v", record.SessionID, err)
+	}
+}
+
+// OpenAuditLog opens the file at path for appending audit records, creating
+// it readable only by the owner. A path of "-" writes them to stdout.
+func OpenAuditLog(path string) (*os.File, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return f, nil
+}
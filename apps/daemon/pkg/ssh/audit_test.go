@@ -0,0 +1,111 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestAuditLog(t *testing.T) {
+	var audit bytes.Buffer
+	ends := make(chan SessionEnd, 1)
+
+	s := newTestServer(t)
+	s.AuditLog = &audit
+	s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{"secret": {ID: "alice", Tenant: "acme"}},
+	}
+	client := dialTestServer(t, startTestServer(t, s), gossh.Password("secret"))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	err = session.Run("echo audited; exit 3")
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+
+	var end SessionEnd
+	select {
+	case end = <-ends:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't end")
+	}
+
+	var records []map[string]any
+	lines := bufio.NewScanner(&audit)
+	for lines.Scan() {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+
+	fields := []string{
+		"schema_version", "event", "time", "session_id", "channel_id", "user", "identity", "tenant",
+		"remote_addr", "local_addr", "client_version", "subsystem", "command", "pty",
+	}
+	endOnly := []string{"exit_code", "reason", "duration_ms", "bytes_in", "bytes_out"}
+
+	start := records[0]
+	require.ElementsMatch(t, fields, recordKeys(start))
+	require.Equal(t, float64(AuditSchemaVersion), start["schema_version"])
+	require.Equal(t, AuditSessionStart, start["event"])
+	require.Equal(t, end.SessionID, start["session_id"])
+	require.Equal(t, "1", start["channel_id"])
+	require.Equal(t, "daytona", start["user"])
+	require.Equal(t, "alice", start["identity"])
+	require.Equal(t, "acme", start["tenant"])
+	require.Equal(t, "127.0.0.1", start["remote_addr"])
+	require.Equal(t, "echo audited; exit 3", start["command"])
+	require.Equal(t, false, start["pty"])
+	require.Contains(t, start["client_version"], "SSH-2.0-")
+	_, err = time.Parse(time.RFC3339Nano, start["time"].(string))
+	require.NoError(t, err)
+
+	ended := records[1]
+	require.ElementsMatch(t, append(fields, endOnly...), recordKeys(ended))
+	require.Equal(t, AuditSessionEnd, ended["event"])
+	require.Equal(t, end.SessionID, ended["session_id"])
+	require.Equal(t, "1", ended["channel_id"])
+	require.Equal(t, float64(3), ended["exit_code"])
+	require.Equal(t, string(CloseReasonExit), ended["reason"])
+	require.Equal(t, float64(len("audited\n")), ended["bytes_out"])
+}
+
+func recordKeys(record map[string]any) []string {
+	var keys []string
+	for key := range record {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte("existing\n"), 0o600))
+
+	f, err := OpenAuditLog(path)
+	require.NoError(t, err)
+	_, err = f.WriteString("appended\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "existing\nappended\n", string(data))
+
+	f, err = OpenAuditLog("-")
+	require.NoError(t, err)
+	require.Equal(t, os.Stdout, f)
+}
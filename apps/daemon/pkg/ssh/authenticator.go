@@ -0,0 +1,143 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	contextKeyIdentity = &contextKey{"identity"}
+	// contextKeyAuthenticated holds the identity of the last successful
+	// authentication attempt until commitIdentity takes it over.
+	contextKeyAuthenticated = &contextKey{"authenticated"}
+)
+
+// defaultMaxAuthTries is the limit golang.org/x/crypto/ssh applies if
+// Server.MaxAuthTries is zero.
+const defaultMaxAuthTries = 6
+
+// Identity is the principal a client authenticated as.
+type Identity struct {
+	// ID identifies the principal, e.g. a user or the fingerprint of a key.
+	ID string
+	// WorkspaceID is the workspace the credentials grant access to, if the
+	// backend maps them to one.
+	WorkspaceID string
+	// Tenant tags the connection for per-tenant accounting and limits, e.g.
+	// with an organization, see Server.TenantUsage. Empty leaves it untagged.
+	Tenant string
+	// Admin grants access to administrative subsystems, like daytona-logs
+	// streaming the logs of the server, see Server.Logs.
+	Admin bool
+}
+
+// Authenticator validates client credentials and maps them to an identity.
+// Implementations may be called concurrently for different connections.
+type Authenticator interface {
+	AuthPublicKey(ctx ssh.Context, key ssh.PublicKey) (Identity, bool)
+	AuthPassword(ctx ssh.Context, password string) (Identity, bool)
+}
+
+// IdentityFromContext returns the identity the client of ctx authenticated
+// as. It is only set when the server uses an Authenticator.
+func IdentityFromContext(ctx ssh.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKeyIdentity).(Identity)
+	return identity, ok
+}
+
+// authenticator returns the Authenticator of the server, falling back to
+// AuthorizedKeysFile. Clients aren't authenticated if it returns nil.
+func (s *Server) authenticator() Authenticator {
+	if s.Authenticator != nil {
+		return s.Authenticator
+	}
+
+	if s.AuthorizedKeysFile != "" {
+		return &AuthorizedKeysAuthenticator{Path: s.AuthorizedKeysFile}
+	}
+
+	return nil
+}
+
+func (s *Server) withAuthenticator(sshServer *ssh.Server) {
+	if s.UnlockFunc != nil {
+		sshServer.KeyboardInteractiveHandler = s.unlockHandler
+	}
+
+	auth := s.authenticator()
+	if auth == nil {
+		return
+	}
+
+	sshServer.PublicKeyHandler = func(ctx ssh.Context, key ssh.PublicKey) bool {
+		identity, ok := auth.AuthPublicKey(ctx, key)
+		if ok {
+			authenticated(ctx, &identity)
+		} else {
+			s.authFailed(ctx)
+		}
+		return ok
+	}
+
+	// The file based authenticator doesn't know passwords, so the method
+	// isn't offered for it.
+	if _, ok := auth.(*AuthorizedKeysAuthenticator); ok {
+		return
+	}
+
+	sshServer.PasswordHandler = func(ctx ssh.Context, password string) bool {
+		identity, ok := auth.AuthPassword(ctx, password)
+		if ok {
+			// Options of keys the client only queried don't apply.
+			ctx.SetValue(contextKeyKeyOptions, nil)
+			authenticated(ctx, &identity)
+		} else {
+			s.authFailed(ctx)
+		}
+		return ok
+	}
+}
+
+// authenticated records the identity of a successful authentication attempt,
+// nil for methods that don't map to one. Clients may query whether a key is
+// accepted without signing with it and then authenticate otherwise, so every
+// attempt replaces the one before, and golang.org/x/crypto/ssh calls the
+// public key callback last for the key that signed.
+func authenticated(ctx ssh.Context, identity *Identity) {
+	ctx.SetValue(contextKeyAuthenticated, identity)
+}
+
+// commitIdentity makes the identity the client authenticated with available
+// to IdentityFromContext and tags the connection with its tenant. It must
+// only be called once authentication completed.
+func (s *Server) commitIdentity(ctx ssh.Context) {
+	identity, _ := ctx.Value(contextKeyAuthenticated).(*Identity)
+	if identity == nil {
+		return
+	}
+
+	ctx.SetValue(contextKeyIdentity, *identity)
+	s.tagConnection(ctx, *identity)
+}
+
+// authFailed counts a failed authentication attempt of the connection of ctx.
+// golang.org/x/crypto/ssh disconnects the client once it reaches MaxAuthTries,
+// which is logged here as the library doesn't.
+func (s *Server) authFailed(ctx ssh.Context) {
+	conn, ok := ctx.Value(contextKeyConnState).(*trackedConn)
+	if !ok {
+		return
+	}
+
+	limit := s.MaxAuthTries
+	if limit == 0 {
+		limit = defaultMaxAuthTries
+	}
+	if failures := conn.authFailures.Add(1); limit > 0 && failures == int64(limit) {
+		log.Warnf("Disconnecting %s (user %s) after %d failed authentication attempts", ctx.RemoteAddr(), ctx.User(), failures)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type mockAuthenticator struct {
+	key       ssh.PublicKey
+	passwords map[string]Identity
+}
+
+func (a *mockAuthenticator) AuthPublicKey(ctx ssh.Context, key ssh.PublicKey) (Identity, bool) {
+	if a.key != nil && ssh.KeysEqual(a.key, key) {
+		return Identity{ID: "key-user", WorkspaceID: "workspace-1"}, true
+	}
+	return Identity{}, false
+}
+
+func (a *mockAuthenticator) AuthPassword(ctx ssh.Context, password string) (Identity, bool) {
+	identity, ok := a.passwords[password]
+	return identity, ok
+}
+
+func TestAuthenticator(t *testing.T) {
+	signer := newTestSigner(t)
+	identities := make(chan Identity, 1)
+
+	s := newTestServer(t)
+	s.Authenticator = &mockAuthenticator{
+		key: signer.PublicKey(),
+		passwords: map[string]Identity{
+			"secret": {ID: "password-user", WorkspaceID: "workspace-2"},
+		},
+	}
+	s.ContextProvider = func(ctx ssh.Context) {
+		identity, _ := IdentityFromContext(ctx)
+		identities <- identity
+	}
+
+	addr := startTestServer(t, s)
+
+	run := func(t *testing.T, client *gossh.Client) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("echo ok")
+		require.NoError(t, err)
+		require.Equal(t, "ok\n", string(output))
+	}
+
+	t.Run("public key", func(t *testing.T) {
+		run(t, dialTestServer(t, addr, gossh.PublicKeys(signer)))
+		require.Equal(t, Identity{ID: "key-user", WorkspaceID: "workspace-1"}, <-identities)
+	})
+
+	t.Run("password", func(t *testing.T) {
+		run(t, dialTestServer(t, addr, gossh.Password("secret")))
+		require.Equal(t, Identity{ID: "password-user", WorkspaceID: "workspace-2"}, <-identities)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		for _, auth := range []gossh.AuthMethod{
+			gossh.Password("wrong"),
+			gossh.PublicKeys(newTestSigner(t)),
+		} {
+			_, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+				User:            "daytona",
+				Auth:            []gossh.AuthMethod{auth},
+				HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+				Timeout:         5 * time.Second,
+			})
+			require.ErrorContains(t, err, "unable to authenticate")
+		}
+	})
+}
+
+// querySigner offers the key of a signer without being able to sign with it:
+// the server rejects its signatures before verifying them, so the client only
+// queries whether the key is accepted and goes on with the next method.
+type querySigner struct {
+	gossh.Signer
+}
+
+func (s querySigner) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	return &gossh.Signature{Format: "unsupported"}, nil
+}
+
+func TestAuthenticatorQueriedKey(t *testing.T) {
+	signer := newTestSigner(t)
+
+	for name, tc := range map[string]struct {
+		unlock   bool
+		auth     gossh.AuthMethod
+		identity Identity
+	}{
+		"password": {
+			auth:     gossh.Password("secret"),
+			identity: Identity{ID: "password-user", WorkspaceID: "workspace-2"},
+		},
+		"unlock": {
+			unlock:   true,
+			auth:     gossh.KeyboardInteractive((&passphrases{answers: []string{"correct horse"}}).challenge),
+			identity: Identity{ID: "none"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			identities := make(chan Identity, 1)
+
+			s := newTestServer(t)
+			s.Authenticator = &mockAuthenticator{
+				key: signer.PublicKey(),
+				passwords: map[string]Identity{
+					"secret": {ID: "password-user", WorkspaceID: "workspace-2"},
+				},
+			}
+			if tc.unlock {
+				s.UnlockFunc = func(ctx ssh.Context, passphrase string) error { return nil }
+			}
+			s.ContextProvider = func(ctx ssh.Context) {
+				identity, ok := IdentityFromContext(ctx)
+				if !ok {
+					identity = Identity{ID: "none"}
+				}
+				identities <- identity
+			}
+			addr := startTestServer(t, s)
+
+			client := dialTestServer(t, addr, gossh.PublicKeys(querySigner{signer}), tc.auth)
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			require.NoError(t, session.Run("true"))
+
+			// Only the method that completed authentication counts.
+			require.Equal(t, tc.identity, <-identities)
+		})
+	}
+}
+
+func TestAuthorizedKeysAuthenticatorIdentity(t *testing.T) {
+	signer := newTestSigner(t)
+	identities := make(chan Identity, 1)
+
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(signer, ""))
+	s.ContextProvider = func(ctx ssh.Context) {
+		identity, _ := IdentityFromContext(ctx)
+		identities <- identity
+	}
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr, gossh.PublicKeys(signer))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, session.Run("true"))
+
+	require.Equal(t, Identity{ID: gossh.FingerprintSHA256(signer.PublicKey())}, <-identities)
+
+	// Passwords aren't offered as the file can't validate them.
+	_, err = gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "daytona",
+		Auth:            []gossh.AuthMethod{gossh.Password("secret")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	require.ErrorContains(t, err, "no supported methods remain")
+}
+
+func TestMaxAuthTries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		maxAuthTries int
+		attempts     int
+		err          string
+	}{
+		"below limit":    {maxAuthTries: 4, attempts: 4},
+		"limit exceeded": {maxAuthTries: 2, attempts: 2, err: "too many authentication failures"},
+		"default limit":  {attempts: 4},
+		"unlimited":      {maxAuthTries: -1, attempts: 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.MaxAuthTries = tc.maxAuthTries
+			s.Authenticator = &mockAuthenticator{
+				passwords: map[string]Identity{"secret": {ID: "password-user"}},
+			}
+			addr := startTestServer(t, s)
+
+			// Three wrong passwords before the right one.
+			attempts := 0
+			password := gossh.RetryableAuthMethod(gossh.PasswordCallback(func() (string, error) {
+				if attempts++; attempts <= 3 {
+					return "wrong", nil
+				}
+				return "secret", nil
+			}), 4)
+
+			client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+				User:            "daytona",
+				Auth:            []gossh.AuthMethod{password},
+				HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+				Timeout:         5 * time.Second,
+			})
+			require.Equal(t, tc.attempts, attempts)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, client.Close())
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// keyOptions are the OpenSSH authorized_keys options enforced for sessions
+// authenticated with the key they were declared for.
+type keyOptions struct {
+	// Command replaces the command of every session, and subsystems are
+	// denied as they would bypass it.
+	Command           string
+	NoPty             bool
+	NoPortForwarding  bool
+	NoAgentForwarding bool
+}
+
+type authorizedKey struct {
+	key     gossh.PublicKey
+	options keyOptions
+}
+
+// parseKeyOptions parses the options of a single authorized_keys line as
+// returned by gossh.ParseAuthorizedKey. Options are applied in order, so
+// "restrict,pty" permits a PTY but nothing else. Options that aren't enforced
+// fail, like OpenSSH fails for unknown ones, so keys are never accepted with
+// less restrictions than the file declares.
+func parseKeyOptions(options []string) (keyOptions, error) {
+	opts := keyOptions{}
+
+	for _, option := range options {
+		name, value, hasValue := strings.Cut(option, "=")
+
+		switch strings.ToLower(name) {
+		case "command":
+			if !hasValue {
+				return opts, fmt.Errorf("option %q requires a value", name)
+			}
+			command, err := unquoteOptionValue(value)
+			if err != nil {
+				return opts, fmt.Errorf("invalid value for option %q: %w", name, err)
+			}
+			opts.Command = command
+		case "no-pty":
+			opts.NoPty = true
+		case "pty":
+			opts.NoPty = false
+		case "no-port-forwarding":
+			opts.NoPortForwarding = true
+		case "port-forwarding":
+			opts.NoPortForwarding = false
+		case "no-agent-forwarding":
+			opts.NoAgentForwarding = true
+		case "agent-forwarding":
+			opts.NoAgentForwarding = false
+		case "restrict":
+			opts.NoPty = true
+			opts.NoPortForwarding = true
+			opts.NoAgentForwarding = true
+		case "no-x11-forwarding", "x11-forwarding", "no-user-rc", "user-rc":
+			// X11 forwarding and ~/.ssh/rc aren't supported, so these
+			// options hold anyway.
+		default:
+			// Options like from= or permitopen= restrict the key in ways
+			// that aren't enforced, so it must not be accepted without them.
+			return opts, fmt.Errorf("unsupported option %q", name)
+		}
+	}
+
+	return opts, nil
+}
+
+func unquoteOptionValue(value string) (string, error) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", fmt.Errorf("value must be enclosed in double quotes")
+	}
+
+	return strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`), nil
+}
+
+func loadAuthorizedKeys(path string) ([]authorizedKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []authorizedKey{}
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, _, options, rest, err := gossh.ParseAuthorizedKey(data)
+		if err != nil {
+			// ParseAuthorizedKey skips invalid lines on its own and only fails
+			// once no valid key is left.
+			break
+		}
+		data = rest
+
+		opts, err := parseKeyOptions(options)
+		if err != nil {
+			log.Warnf("Skipping key %s in %s: %v", gossh.FingerprintSHA256(key), path, err)
+			continue
+		}
+
+		keys = append(keys, authorizedKey{
+			key:     key,
+			options: opts,
+		})
+	}
+
+	return keys, nil
+}
+
+// AuthorizedKeysAuthenticator authorizes keys listed in an OpenSSH
+// authorized_keys file and enforces their options. The file is read on every
+// attempt so changes apply to new connections immediately.
+type AuthorizedKeysAuthenticator struct {
+	Path string
+}
+
+func (a *AuthorizedKeysAuthenticator) AuthPublicKey(ctx ssh.Context, key ssh.PublicKey) (Identity, bool) {
+	keys, err := loadAuthorizedKeys(a.Path)
+	if err != nil {
+		log.Errorf("Failed to load authorized keys: %v", err)
+		return Identity{}, false
+	}
+
+	for _, authorized := range keys {
+		if ssh.KeysEqual(authorized.key, key) {
+			ctx.SetValue(contextKeyKeyOptions, authorized.options)
+			return Identity{ID: gossh.FingerprintSHA256(key)}, true
+		}
+	}
+
+	return Identity{}, false
+}
+
+func (a *AuthorizedKeysAuthenticator) AuthPassword(ctx ssh.Context, password string) (Identity, bool) {
+	return Identity{}, false
+}
+
+func keyOptionsFromContext(ctx ssh.Context) keyOptions {
+	opts, _ := ctx.Value(contextKeyKeyOptions).(keyOptions)
+	return opts
+}
@@ -0,0 +1,239 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newTestSigner(t *testing.T) gossh.Signer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := gossh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	return signer
+}
+
+func writeAuthorizedKeys(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+
+	return path
+}
+
+func authorizedKeyLine(signer gossh.Signer, options string) string {
+	line := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(signer.PublicKey())))
+	if options != "" {
+		line = options + " " + line
+	}
+	return line
+}
+
+func TestParseKeyOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []string
+		expected keyOptions
+	}{
+		{
+			name:     "command",
+			options:  []string{`command="echo \"hi\""`},
+			expected: keyOptions{Command: `echo "hi"`},
+		},
+		{
+			name:     "no-pty",
+			options:  []string{"no-pty"},
+			expected: keyOptions{NoPty: true},
+		},
+		{
+			name:     "no-port-forwarding",
+			options:  []string{"no-port-forwarding"},
+			expected: keyOptions{NoPortForwarding: true},
+		},
+		{
+			name:     "restrict",
+			options:  []string{"restrict"},
+			expected: keyOptions{NoPty: true, NoPortForwarding: true, NoAgentForwarding: true},
+		},
+		{
+			name:     "restrict with pty",
+			options:  []string{"restrict", "pty"},
+			expected: keyOptions{NoPortForwarding: true, NoAgentForwarding: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseKeyOptions(tt.options)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, opts)
+		})
+	}
+
+	for _, options := range [][]string{
+		{"command=echo"},
+		{`from="10.0.0.0/8"`},
+		{`permitopen="localhost:8080"`},
+		{`environment="PATH=/tmp"`},
+		{"cert-authority"},
+	} {
+		_, err := parseKeyOptions(options)
+		require.Error(t, err, options)
+	}
+
+	opts, err := parseKeyOptions([]string{"no-x11-forwarding", "no-user-rc"})
+	require.NoError(t, err)
+	require.Equal(t, keyOptions{}, opts)
+}
+
+func TestAuthorizedKeysSkipsUnsupportedOptions(t *testing.T) {
+	restricted := newTestSigner(t)
+	plain := newTestSigner(t)
+
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = writeAuthorizedKeys(t,
+		authorizedKeyLine(restricted, `from="192.0.2.1"`),
+		authorizedKeyLine(plain, ""),
+	)
+	addr := startTestServer(t, s)
+
+	// The key limited to another network isn't accepted from here.
+	_, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "daytona",
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(restricted)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	require.ErrorContains(t, err, "unable to authenticate")
+
+	dialTestServer(t, addr, gossh.PublicKeys(plain))
+}
+
+func TestAuthorizedKeysRejectsUnknownKey(t *testing.T) {
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(newTestSigner(t), ""))
+
+	addr := startTestServer(t, s)
+
+	_, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "daytona",
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(newTestSigner(t))},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	require.Error(t, err)
+}
+
+func TestAuthorizedKeysForcedCommand(t *testing.T) {
+	signer := newTestSigner(t)
+
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(signer, `command="echo forced:$SSH_ORIGINAL_COMMAND"`))
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr, gossh.PublicKeys(signer))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	output, err := session.Output("echo requested")
+	require.NoError(t, err)
+	require.Equal(t, "forced:echo requested\n", string(output))
+}
+
+func TestAuthorizedKeysForcedCommandDeniesSubsystems(t *testing.T) {
+	signer := newTestSigner(t)
+
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(signer, `command="echo forced"`))
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr, gossh.PublicKeys(signer))
+
+	for _, subsystem := range []string{"sftp", daytonaSubsystem} {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		require.Error(t, session.RequestSubsystem(subsystem), subsystem)
+		session.Close()
+	}
+
+	_, err := sftp.NewClient(client)
+	require.Error(t, err)
+}
+
+func TestAuthorizedKeysNoPty(t *testing.T) {
+	signer := newTestSigner(t)
+
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(signer, "no-pty"))
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr, gossh.PublicKeys(signer))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.Error(t, session.RequestPty("xterm", 24, 80, gossh.TerminalModes{}))
+
+	output, err := session.Output("tty || true")
+	require.NoError(t, err)
+	require.Contains(t, string(output), "not a tty")
+}
+
+func TestAuthorizedKeysNoPortForwarding(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer target.Close()
+
+	for _, options := range []string{"no-port-forwarding", "restrict"} {
+		t.Run(options, func(t *testing.T) {
+			signer := newTestSigner(t)
+
+			s := newTestServer(t)
+			s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(signer, options))
+
+			addr := startTestServer(t, s)
+			client := dialTestServer(t, addr, gossh.PublicKeys(signer))
+
+			_, err := client.Dial("tcp", target.Addr().String())
+			require.Error(t, err)
+
+			_, err = client.Listen("tcp", "127.0.0.1:0")
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("unrestricted", func(t *testing.T) {
+		signer := newTestSigner(t)
+
+		s := newTestServer(t)
+		s.AuthorizedKeysFile = writeAuthorizedKeys(t, authorizedKeyLine(signer, ""))
+
+		addr := startTestServer(t, s)
+		client := dialTestServer(t, addr, gossh.PublicKeys(signer))
+
+		conn, err := client.Dial("tcp", target.Addr().String())
+		require.NoError(t, err)
+		_ = conn.Close()
+	})
+}
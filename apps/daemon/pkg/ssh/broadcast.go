@@ -0,0 +1,36 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Broadcast writes message to the terminals of all active PTY sessions, e.g.
+// "workspace will stop in 5 minutes", on a line of its own like wall(1).
+// Sessions without a PTY aren't notified, their output may be parsed by
+// programs like git or rsync. The message is written in the background, so
+// clients that don't read their output don't hold up the caller; it may
+// therefore not have reached all terminals when Broadcast returns.
+func (s *Server) Broadcast(message string) {
+	message = strings.TrimRight(message, "\n")
+	output := []byte("\r\n" + strings.ReplaceAll(message, "\n", "\r\n") + "\r\n")
+
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	for _, entry := range s.sessions.entries {
+		if entry.terminal == nil {
+			continue
+		}
+
+		go func(entry *sessionEntry) {
+			if _, err := entry.terminal.Write(output); err != nil {
+				logSessionError(log.WarnLevel, err, "Unable to broadcast to session %s: %v", entry.info.ID, err)
+			}
+		}(entry)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestBroadcast(t *testing.T) {
+	s := newTestServer(t)
+	client := dialTestServer(t, startTestServer(t, s))
+
+	var terminals []*attachedOutput
+	for i := 0; i < 2; i++ {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = session.Close()
+		})
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+		// An open stdin keeps the shell running.
+		_, err = session.StdinPipe()
+		require.NoError(t, err)
+		output := &attachedOutput{}
+		session.Stdout = output
+		require.NoError(t, session.Shell())
+		terminals = append(terminals, output)
+	}
+
+	command, err := client.NewSession()
+	require.NoError(t, err)
+	defer command.Close()
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	require.NoError(t, command.Start("sleep 1"))
+
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.Broadcast("Workspace will stop\nin 5 minutes\n")
+
+	for _, output := range terminals {
+		require.Eventually(t, func() bool {
+			return output.contains("\r\nWorkspace will stop\r\nin 5 minutes\r\n")
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// Sessions without a PTY aren't notified.
+	require.NoError(t, runWithTimeout(t, 10*time.Second, command.Wait))
+	require.Empty(t, stdout.String())
+	require.Empty(t, stderr.String())
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultAcceptEnv mirrors the AcceptEnv of common OpenSSH setups plus TZ, so
+// shells show times in the timezone of the user, and GIT_PROTOCOL, which git
+// sends to negotiate protocol version 2. Without them sessions keep the
+// locale and timezone of the host.
+var defaultAcceptEnv = []string{"LANG", "LC_*", "TZ", "GIT_PROTOCOL"}
+
+// clientEnv returns the variables the client set with env requests that
+// AcceptEnv accepts.
+func (s *Server) clientEnv(session ssh.Session) []string {
+	patterns := s.AcceptEnv
+	if patterns == nil {
+		patterns = defaultAcceptEnv
+	}
+
+	env := []string{}
+	for _, entry := range session.Environ() {
+		name, _, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			continue
+		}
+
+		if !acceptsEnv(patterns, name) {
+			log.Debugf("Ignoring variable %s sent by the client of session %s", name, session.Context().SessionID())
+			continue
+		}
+		env = append(env, entry)
+	}
+
+	return env
+}
+
+// daemonEnv returns the environment of the daemon that sessions inherit,
+// without the variables StripEnv matches.
+func (s *Server) daemonEnv() []string {
+	env := os.Environ()
+	if len(s.StripEnv) == 0 {
+		return env
+	}
+
+	kept := env[:0]
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if !acceptsEnv(s.StripEnv, name) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+func acceptsEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestClientEnv(t *testing.T) {
+	for name, tc := range map[string]struct {
+		acceptEnv []string
+		expected  string
+	}{
+		"default":  {expected: "version=2,en_US.UTF-8,,"},
+		"accepted": {acceptEnv: []string{"GIT_*", "CUSTOM"}, expected: "version=2,,1,yes"},
+		"none":     {acceptEnv: []string{}, expected: ",,,"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.AcceptEnv = tc.acceptEnv
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			require.NoError(t, session.Setenv("GIT_PROTOCOL", "version=2"))
+			require.NoError(t, session.Setenv("LANG", "en_US.UTF-8"))
+			require.NoError(t, session.Setenv("GIT_TRACE", "1"))
+			require.NoError(t, session.Setenv("CUSTOM", "yes"))
+
+			output, err := session.Output(`echo "$GIT_PROTOCOL,$LANG,$GIT_TRACE,$CUSTOM"`)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected+"\n", string(output))
+		})
+	}
+}
+
+func TestClientTimezone(t *testing.T) {
+	t.Setenv("TZ", "UTC")
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+	for name, tc := range map[string]struct {
+		tz       string
+		expected string
+	}{
+		"client timezone": {tz: "Asia/Tokyo", expected: "Asia/Tokyo"},
+		"host timezone":   {expected: "UTC"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			if tc.tz != "" {
+				require.NoError(t, session.Setenv("TZ", tc.tz))
+			}
+
+			output, err := session.Output("echo $TZ")
+			require.NoError(t, err)
+			require.Equal(t, tc.expected+"\n", string(output))
+		})
+	}
+}
+
+func TestStripEnv(t *testing.T) {
+	t.Setenv("AGENT_TOKEN", "secret")
+	t.Setenv("AGENT_API_KEY", "secret")
+	t.Setenv("KEPT", "kept")
+
+	s := newTestServer(t)
+	s.StripEnv = []string{"AGENT_*"}
+	s.WelcomeCommand = `echo "welcome=$AGENT_TOKEN,$KEPT"`
+	client := dialTestServer(t, startTestServer(t, s))
+
+	t.Run("command", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output(`echo "$AGENT_TOKEN,$AGENT_API_KEY,$KEPT"`)
+		require.NoError(t, err)
+		require.Equal(t, ",,kept\n", string(output))
+	})
+
+	t.Run("shell", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+		var output bytes.Buffer
+		session.Stdout = &output
+		session.Stdin = strings.NewReader(`echo "shell=$AGENT_TOKEN,$AGENT_API_KEY,$KEPT"; exit` + "\n")
+		require.NoError(t, session.Shell())
+		require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+		require.Contains(t, output.String(), "welcome=,kept\r\n")
+		require.Contains(t, output.String(), "shell=,,kept\r\n")
+	})
+}
+
+func TestGitOverSSH(t *testing.T) {
+	for _, name := range []string{"git", "ssh"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is not installed", name)
+		}
+	}
+
+	addr := startTestServer(t, newTestServer(t))
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	// Git quotes the repository path in the command it runs over SSH.
+	remote := filepath.Join(dir, "repo with 'quotes' and spaces.git")
+	runGit(t, dir, "init", "--bare", "--initial-branch=main", remote)
+
+	url := "ssh://daytona@" + net.JoinHostPort(host, port) + remote
+	work := filepath.Join(dir, "work")
+	runGit(t, dir, "clone", url, work)
+
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README"), []byte("hello\n"), 0o644))
+	runGit(t, work, "add", "README")
+	runGit(t, work, "commit", "-m", "Add README")
+	runGit(t, work, "push", "origin", "HEAD:main")
+
+	require.Equal(t, "Add README", runGit(t, remote, "log", "-1", "--format=%s", "main"))
+
+	clone := filepath.Join(dir, "clone")
+	runGit(t, dir, "-c", "protocol.version=2", "clone", url, clone)
+	content, err := os.ReadFile(filepath.Join(clone, "README"))
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", string(content))
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_SSH_COMMAND=ssh -F /dev/null -o BatchMode=yes -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o LogLevel=ERROR",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Test",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+)
+
+const defaultCommandOutputFlushInterval = 20 * time.Millisecond
+
+// bufferedOutput collects small writes into writes of up to the size of its
+// buffer. Buffered output is written at the latest after interval, so the
+// output of commands printing progress doesn't stall.
+type bufferedOutput struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	w     *bufio.Writer
+	timer *time.Timer
+}
+
+func newBufferedOutput(w io.Writer, size int, interval time.Duration) *bufferedOutput {
+	if interval <= 0 {
+		interval = defaultCommandOutputFlushInterval
+	}
+
+	return &bufferedOutput{
+		interval: interval,
+		w:        bufio.NewWriterSize(w, size),
+	}
+}
+
+func (b *bufferedOutput) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n, err := b.w.Write(p)
+	if b.w.Buffered() > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flushPending)
+	}
+	return n, err
+}
+
+func (b *bufferedOutput) flushPending() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.timer = nil
+	_ = b.w.Flush()
+}
+
+// Flush writes the buffered output and stops the pending flush.
+func (b *bufferedOutput) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return b.w.Flush()
+}
+
+// commandOutput returns the writer the stdout of a non-PTY command is copied
+// to and a function flushing it once the command exited.
+func (s *Server) commandOutput(session ssh.Session) (io.Writer, func()) {
+	if s.CommandOutputBuffer <= 0 {
+		return session, func() {}
+	}
+
+	output := newBufferedOutput(session, s.CommandOutputBuffer, s.CommandOutputFlushInterval)
+	return output, func() {
+		_ = output.Flush()
+	}
+}
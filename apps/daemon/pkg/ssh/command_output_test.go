@@ -0,0 +1,96 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandOutputBuffer(t *testing.T) {
+	var expected strings.Builder
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(&expected, "line %d\n", i)
+	}
+
+	s := newTestServer(t)
+	s.CommandOutputBuffer = 4096
+	client := dialTestServer(t, startTestServer(t, s))
+
+	for name, command := range map[string]string{
+		"exits right away": `i=1; while [ $i -le 2000 ]; do echo "line $i"; i=$((i+1)); done`,
+		"pauses":           `i=1; while [ $i -le 2000 ]; do echo "line $i"; [ $i = 1000 ] && sleep 0.1; i=$((i+1)); done`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			output, err := session.Output(command)
+			require.NoError(t, err)
+			require.Equal(t, expected.String(), string(output))
+		})
+	}
+}
+
+func TestCommandOutputFlushInterval(t *testing.T) {
+	s := newTestServer(t)
+	s.CommandOutputBuffer = 64 << 10
+	s.CommandOutputFlushInterval = 10 * time.Millisecond
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("echo progress; sleep 30"))
+
+	// The line is sent long before the command exits or fills the buffer.
+	line := make([]byte, len("progress\n"))
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		_, err := stdout.Read(line)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, "progress\n", string(line))
+}
+
+// BenchmarkCommandOutput measures a command writing many short lines, e.g.
+// go test -run '^$' -bench CommandOutput ./pkg/ssh
+func BenchmarkCommandOutput(b *testing.B) {
+	const lines = 20000
+	command := fmt.Sprintf(`i=0; while [ $i -lt %d ]; do echo "output line $i"; i=$((i+1)); done`, lines)
+
+	for _, bc := range []struct {
+		name       string
+		bufferSize int
+	}{
+		{name: "unbuffered"},
+		{name: "buffered", bufferSize: 32 << 10},
+	} {
+		s := newTestServer(b)
+		s.CommandOutputBuffer = bc.bufferSize
+		client := dialTestServer(b, startTestServer(b, s))
+
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				session, err := client.NewSession()
+				require.NoError(b, err)
+
+				var stdout bytes.Buffer
+				session.Stdout = &stdout
+				require.NoError(b, session.Run(command))
+				require.Equal(b, lines, bytes.Count(stdout.Bytes(), []byte("\n")))
+				session.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logConfig logs the effective configuration in a single line, so operators
+// can confirm which of the mostly opt-in options are active. Only paths and
+// whether callbacks are set are logged, never file contents or commands that
+// may carry secrets.
+func (s *Server) logConfig(addr net.Addr) {
+	auth := s.authenticator()
+	authenticator := "none"
+	authMethods := []string{"none"}
+	// Keys of authorized_keys files can restrict forwarding individually.
+	forwarding := "enabled"
+	switch auth.(type) {
+	case nil:
+	case *AuthorizedKeysAuthenticator:
+		authenticator = "authorized_keys"
+		authMethods = []string{"publickey"}
+		forwarding = "per key"
+	default:
+		// Which methods a custom Authenticator accepts isn't known here.
+		authenticator = "custom"
+		authMethods = nil
+	}
+	if s.UnlockFunc != nil {
+		if auth == nil {
+			authMethods = nil
+		}
+		authMethods = append(authMethods, "keyboard-interactive")
+	}
+
+	listeners := make([]string, 0, len(s.Listeners))
+	for _, l := range s.Listeners {
+		listeners = append(listeners, l.Addr)
+	}
+
+	log.WithFields(log.Fields{
+		"addr":                 addr.String(),
+		"listeners":            listeners,
+		"serveRetries":         s.ServeRetries,
+		"authenticator":        authenticator,
+		"authMethods":          authMethods,
+		"authorizedKeys":       s.AuthorizedKeysFile,
+		"maxAuthTries":         s.MaxAuthTries,
+		"sessionAuthorizer":    s.SessionAuthorizer != nil,
+		"agentForwarding":      forwarding,
+		"requireAgent":         s.RequireAgentForwarding,
+		"portForwarding":       forwarding,
+		"globalReverseForward": s.AllowGlobalReverseForward,
+		"rejectedRequests":     s.RejectedRequests,
+		"pty":                  !s.DisablePty,
+		"fallbackToShell":      s.FallbackToShell,
+		"readOnly":             s.ReadOnly,
+		"allowedSignals":       s.AllowedSignals,
+		"sessionAttach":        s.SessionAttach,
+		"sftpRoot":             s.SFTPRoot,
+		"sftpUploadOnly":       s.SFTPUploadOnly,
+		"sftpDownloadOnly":     s.SFTPDownloadOnly,
+		"sftpDeniedPaths":      s.SFTPDeniedPaths,
+		"customSFTP":           s.SFTPHandlers != nil,
+		"envFile":              s.EnvFile,
+		"allowStdinFile":       s.AllowStdinFile,
+		"commandWrapper":       len(s.CommandWrapper) > 0,
+		"maxConnectionRate":    s.MaxConnectionRate,
+		"maxSessions":          s.MaxSessions,
+		"maxSFTPSessions":      s.MaxSFTPSessions,
+		"maxCommandLength":     s.MaxCommandLength,
+		"idleTimeout":          s.IdleTimeout,
+		"maxDuration":          s.MaxDuration,
+		"commandTimeout":       s.CommandTimeout,
+		"commandNamespaces":    s.CommandNamespaces,
+		"commandOutputBuffer":  s.CommandOutputBuffer,
+		"sessionLogDir":        s.SessionLogDir,
+		"auditLog":             s.AuditLog != nil,
+		"stderrTailLines":      s.StderrTailLines,
+		"maxOutputLines":       s.MaxOutputLines,
+		"transcriptSize":       s.TranscriptSize,
+	}).Info("SSH server configuration")
+}
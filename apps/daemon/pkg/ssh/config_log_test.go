@@ -0,0 +1,66 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogConfig(t *testing.T) {
+	hook := logtest.NewGlobal()
+
+	s := newTestServer(t)
+	s.AuthorizedKeysFile = filepath.Join(t.TempDir(), "authorized_keys")
+	s.EnvFile = filepath.Join(t.TempDir(), "env")
+	require.NoError(t, os.WriteFile(s.EnvFile, []byte("TOKEN=secret-value\n"), 0o600))
+	s.MaxSessions = 4
+	s.IdleTimeout = time.Minute
+	s.ReadOnly = true
+
+	s.logConfig(&net.TCPAddr{IP: net.IPv4zero, Port: 2222})
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	require.Equal(t, "SSH server configuration", entry.Message)
+	require.Equal(t, "0.0.0.0:2222", entry.Data["addr"])
+	require.Equal(t, "authorized_keys", entry.Data["authenticator"])
+	require.Equal(t, []string{"publickey"}, entry.Data["authMethods"])
+	require.Equal(t, "per key", entry.Data["portForwarding"])
+	require.Equal(t, false, entry.Data["globalReverseForward"])
+	require.Equal(t, 4, entry.Data["maxSessions"])
+	require.Equal(t, time.Minute, entry.Data["idleTimeout"])
+	require.Equal(t, true, entry.Data["readOnly"])
+	require.Equal(t, s.EnvFile, entry.Data["envFile"])
+
+	line, err := entry.String()
+	require.NoError(t, err)
+	require.NotContains(t, line, "secret-value")
+}
+
+func TestLogConfigCustomAuthenticator(t *testing.T) {
+	hook := logtest.NewGlobal()
+
+	s := newTestServer(t)
+	s.Authenticator = &mockAuthenticator{}
+	s.AllowGlobalReverseForward = true
+
+	s.logConfig(&net.TCPAddr{IP: net.IPv4zero, Port: 2222})
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	require.Equal(t, "custom", entry.Data["authenticator"])
+	// The methods it accepts aren't guessed.
+	require.Empty(t, entry.Data["authMethods"])
+	// Only authorized_keys files restrict forwarding per key.
+	require.Equal(t, "enabled", entry.Data["portForwarding"])
+	require.Equal(t, true, entry.Data["globalReverseForward"])
+}
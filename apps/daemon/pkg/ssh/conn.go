@@ -0,0 +1,176 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"go.opentelemetry.io/otel/trace"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var contextKeyConnState = &contextKey{"conn-state"}
+
+// trackedConn records why the underlying connection was closed. gliderlabs/ssh
+// enforces IdleTimeout and MaxTimeout with deadlines on the wrapped
+// connection, so expired deadlines surface as read errors here.
+type trackedConn struct {
+	net.Conn
+
+	server  *Server
+	started time.Time
+	// listener is set for connections accepted on one of Server.Listeners.
+	listener *Listener
+
+	mu     sync.Mutex
+	reason CloseReason
+
+	// tenant is set once the client authenticated with a tagged identity.
+	tenant     atomic.Pointer[tenantCounters]
+	tenantName string
+	closed     bool
+
+	span         trace.Span
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	// sessions and forwards count the session channels and port forwards
+	// opened on the connection. Clients running `ssh -N` only open forwards.
+	sessions atomic.Int64
+	forwards atomic.Int64
+	// authFailures counts the failed authentication attempts, see
+	// Server.MaxAuthTries.
+	authFailures atomic.Int64
+	// hostKeysOnce advertises the host keys once per connection.
+	hostKeysOnce sync.Once
+}
+
+func (s *Server) connCallback(ctx ssh.Context, conn net.Conn) net.Conn {
+	if !s.admitConn(conn) {
+		return nil
+	}
+
+	tracked := &trackedConn{
+		Conn:    conn,
+		server:  s,
+		started: time.Now(),
+	}
+	if rc, ok := conn.(*restrictedConn); ok {
+		tracked.listener = rc.config
+	}
+	tracked.span = s.startConnSpan(tracked)
+	ctx.SetValue(contextKeyConnState, tracked)
+
+	return tracked
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.bytesRead.Add(int64(n))
+	if t := c.tenant.Load(); t != nil {
+		t.bytesRead.Add(int64(n))
+	}
+	if err != nil {
+		c.setReason(c.classify(err))
+	}
+	return n, err
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.bytesWritten.Add(int64(n))
+	if t := c.tenant.Load(); t != nil {
+		t.bytesWritten.Add(int64(n))
+	}
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.setReason(c.classify(net.ErrClosed))
+
+	c.mu.Lock()
+	if t := c.tenant.Load(); t != nil && !c.closed {
+		t.activeConnections.Add(-1)
+	}
+	wasClosed := c.closed
+	c.closed = true
+	tenant := c.tenantName
+	c.mu.Unlock()
+
+	if !wasClosed {
+		c.endSpan(tenant)
+	}
+
+	log.WithFields(log.Fields{
+		"remoteIP": addrIP(c.RemoteAddr()).String(),
+		"tenant":   tenant,
+		"reason":   c.closeReason(),
+		"sessions": c.sessions.Load(),
+		"forwards": c.forwards.Load(),
+	}).Debug("SSH connection closed")
+
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) tag(name string, counters *tenantCounters) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tenant.Load() != nil || c.closed {
+		return
+	}
+
+	c.tenantName = name
+	c.tenant.Store(counters)
+	counters.connections.Add(1)
+	counters.activeConnections.Add(1)
+}
+
+func (c *trackedConn) classify(err error) CloseReason {
+	switch {
+	case c.server.closing.Load():
+		return CloseReasonServerShutdown
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		if c.server.MaxDuration > 0 && time.Since(c.started) >= c.server.MaxDuration {
+			return CloseReasonMaxDuration
+		}
+		return CloseReasonIdleTimeout
+	case isDisconnectError(err):
+		return CloseReasonClientDisconnect
+	default:
+		return CloseReasonError
+	}
+}
+
+// setReason keeps the first reason, later errors are consequences of it.
+func (c *trackedConn) setReason(reason CloseReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reason == "" {
+		c.reason = reason
+	}
+}
+
+func (c *trackedConn) closeReason() CloseReason {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.reason
+}
+
+// connCloseReason returns the reason the connection of ctx was closed for, or
+// an empty string if it is still open.
+func connCloseReason(ctx ssh.Context) CloseReason {
+	if c, ok := ctx.Value(contextKeyConnState).(*trackedConn); ok {
+		return c.closeReason()
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// connShedReportInterval bounds how often shed connections are logged, so a
+// connection storm doesn't flood the log as well.
+const connShedReportInterval = time.Second
+
+// connRateLimiter is a token bucket admitting up to burst connections at once
+// and rate connections per second on average.
+type connRateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	shed     int
+	reported time.Time
+}
+
+func newConnRateLimiter(rate, burst int) *connRateLimiter {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &connRateLimiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// allow takes a token if one is left. If it isn't, it returns the number of
+// connections shed since the last report once a report is due, zero
+// otherwise.
+func (l *connRateLimiter) allow(now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+
+	l.shed++
+	if now.Sub(l.reported) < connShedReportInterval {
+		return false, 0
+	}
+	shed := l.shed
+	l.shed = 0
+	l.reported = now
+	return false, shed
+}
+
+// admitConn reports whether conn is within MaxConnectionRate. Connections
+// beyond it are closed before the SSH handshake, the costly part of a
+// connection.
+func (s *Server) admitConn(conn net.Conn) bool {
+	if s.connRate == nil {
+		return true
+	}
+
+	ok, shed := s.connRate.allow(time.Now())
+	if !ok && shed > 0 {
+		log.WithFields(log.Fields{
+			"remoteIP": addrIP(conn.RemoteAddr()).String(),
+			"shed":     shed,
+			"rate":     s.MaxConnectionRate,
+			"burst":    s.connRate.burst,
+		}).Warn("Shedding SSH connections above the connection rate")
+	}
+	return ok
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestConnRateLimiter(t *testing.T) {
+	l := newConnRateLimiter(1, 3)
+	now := l.last
+
+	for range 3 {
+		ok, _ := l.allow(now)
+		require.True(t, ok)
+	}
+
+	// The first shed connection is reported right away, later ones with the
+	// next report.
+	ok, shed := l.allow(now)
+	require.False(t, ok)
+	require.Equal(t, 1, shed)
+	ok, shed = l.allow(now.Add(100 * time.Millisecond))
+	require.False(t, ok)
+	require.Zero(t, shed)
+
+	// Tokens refill at the rate.
+	ok, _ = l.allow(now.Add(time.Second))
+	require.True(t, ok)
+	ok, shed = l.allow(now.Add(time.Second))
+	require.False(t, ok)
+	require.Equal(t, 2, shed)
+}
+
+func TestConnectionStorm(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxConnectionRate = 1
+	s.ConnectionBurst = 3
+	addr := startTestServer(t, s)
+
+	accepted := 0
+	for range 10 {
+		client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+			User:            "daytona",
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			Timeout:         5 * time.Second,
+		})
+		if err == nil {
+			accepted++
+			defer client.Close()
+		}
+	}
+	require.Equal(t, 3, accepted)
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// contextKey is a value for use with ssh.Context.SetValue. It's used as a
+// pointer so it fits in an interface{} without allocation.
+type contextKey struct {
+	name string
+}
+
+var (
+	// contextKeyKeyOptions holds the keyOptions of the authorized key used to
+	// authenticate the connection.
+	contextKeyKeyOptions = &contextKey{"key-options"}
+)
+
+var contextKeyProvided = &contextKey{"context-provided"}
+
+// provideContext completes the context of a connection once, before the
+// first channel or global request of the connection is handled, which is the
+// first point after authentication: it commits the identity of the client and
+// runs the ContextProvider.
+func (s *Server) provideContext(ctx ssh.Context) {
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	if ctx.Value(contextKeyProvided) != nil {
+		return
+	}
+	ctx.SetValue(contextKeyProvided, true)
+
+	s.commitIdentity(ctx)
+	if s.ContextProvider != nil {
+		s.ContextProvider(ctx)
+	}
+}
+
+func (s *Server) withContextProvider(sshServer *ssh.Server) {
+	for name, handler := range sshServer.ChannelHandlers {
+		sshServer.ChannelHandlers[name] = func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+			s.provideContext(ctx)
+			handler(srv, conn, newChan, ctx)
+		}
+	}
+
+	for name, handler := range sshServer.RequestHandlers {
+		sshServer.RequestHandlers[name] = func(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+			s.provideContext(ctx)
+			return handler(ctx, srv, req)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build linux
+
+package ssh
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestCPUAffinity(t *testing.T) {
+	var allowed unix.CPUSet
+	require.NoError(t, unix.SchedGetaffinity(0, &allowed))
+	// Pin to the last CPU available, which differs from the default mask on
+	// hosts with more than one.
+	cpu := -1
+	for i := 0; i < len(allowed)*64; i++ {
+		if allowed.IsSet(i) {
+			cpu = i
+		}
+	}
+	require.GreaterOrEqual(t, cpu, 0)
+
+	s := newTestServer(t)
+	s.CPUAffinity = []int{cpu}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	output, err := session.Output("grep Cpus_allowed_list /proc/self/status")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("Cpus_allowed_list:\t%d\n", cpu), string(output))
+
+	var current unix.CPUSet
+	require.NoError(t, unix.SchedGetaffinity(0, &current))
+	require.Equal(t, allowed, current, "the affinity of the server is unchanged")
+}
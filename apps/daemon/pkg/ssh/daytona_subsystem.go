@@ -0,0 +1,188 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The daytona subsystem exchanges frames over the session channel. Each frame
+// is a 4 byte big-endian payload length followed by a JSON encoded payload.
+// The client sends a daytonaRequest and the server answers every request with
+// exactly one daytonaResponse, in order.
+const (
+	daytonaSubsystem = "daytona"
+
+	daytonaMaxFrameSize = 1 << 20
+)
+
+const (
+	daytonaCommandPing          = "ping"
+	daytonaCommandGetInfo       = "get-info"
+	daytonaCommandListProcesses = "list-processes"
+)
+
+var errFrameTooLarge = errors.New("frame exceeds maximum size")
+
+type daytonaRequest struct {
+	Command string `json:"command"`
+}
+
+type daytonaResponse struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type daytonaInfo struct {
+	Hostname   string `json:"hostname"`
+	ProjectDir string `json:"projectDir"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	Pid        int    `json:"pid"`
+}
+
+type daytonaProcess struct {
+	Pid     int    `json:"pid"`
+	Command string `json:"command"`
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > daytonaMaxFrameSize {
+		return nil, errFrameTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func writeFrame(w io.Writer, payload []byte) error {
+	if len(payload) > daytonaMaxFrameSize {
+		return errFrameTooLarge
+	}
+
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+func (s *Server) daytonaSubsystemHandler(session ssh.Session) {
+	for {
+		payload, err := readFrame(session)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logSessionError(log.WarnLevel, err, "daytona subsystem read error: %v", err)
+				_ = session.Exit(1)
+			}
+			return
+		}
+
+		response := s.handleDaytonaRequest(payload)
+
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			encoded, _ = json.Marshal(daytonaResponse{Error: err.Error()})
+		}
+
+		if err := writeFrame(session, encoded); err != nil {
+			logSessionError(log.WarnLevel, err, "daytona subsystem write error: %v", err)
+			_ = session.Exit(1)
+			return
+		}
+	}
+}
+
+func (s *Server) handleDaytonaRequest(payload []byte) daytonaResponse {
+	var req daytonaRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return daytonaResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+
+	switch req.Command {
+	case daytonaCommandPing:
+		return daytonaResponse{Result: "pong"}
+	case daytonaCommandGetInfo:
+		hostname, _ := os.Hostname()
+		return daytonaResponse{Result: daytonaInfo{
+			Hostname:   hostname,
+			ProjectDir: s.ProjectDir,
+			OS:         runtime.GOOS,
+			Arch:       runtime.GOARCH,
+			Pid:        os.Getpid(),
+		}}
+	case daytonaCommandListProcesses:
+		processes, err := listProcesses()
+		if err != nil {
+			return daytonaResponse{Error: err.Error()}
+		}
+		return daytonaResponse{Result: processes}
+	default:
+		return daytonaResponse{Error: fmt.Sprintf("unknown command %q", req.Command)}
+	}
+}
+
+func listProcesses() ([]daytonaProcess, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	processes := []daytonaProcess{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes may exit while we're iterating, skip them silently.
+		cmdline, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+
+		command := strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		if command == "" {
+			comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+			if err != nil {
+				continue
+			}
+			command = "[" + strings.TrimSpace(string(comm)) + "]"
+		}
+
+		processes = append(processes, daytonaProcess{
+			Pid:     pid,
+			Command: command,
+		})
+	}
+
+	return processes, nil
+}
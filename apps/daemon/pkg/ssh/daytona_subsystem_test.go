@@ -0,0 +1,66 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaytonaSubsystemRoundTrip(t *testing.T) {
+	s := newTestServer(t)
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+
+	require.NoError(t, session.RequestSubsystem(daytonaSubsystem))
+
+	call := func(command string) map[string]json.RawMessage {
+		payload, err := json.Marshal(daytonaRequest{Command: command})
+		require.NoError(t, err)
+		require.NoError(t, writeFrame(stdin, payload))
+
+		response, err := readFrame(stdout)
+		require.NoError(t, err)
+
+		var decoded map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(response, &decoded))
+		return decoded
+	}
+
+	require.JSONEq(t, `"pong"`, string(call(daytonaCommandPing)["result"]))
+
+	var info daytonaInfo
+	require.NoError(t, json.Unmarshal(call(daytonaCommandGetInfo)["result"], &info))
+	require.Equal(t, s.ProjectDir, info.ProjectDir)
+
+	var processes []daytonaProcess
+	require.NoError(t, json.Unmarshal(call(daytonaCommandListProcesses)["result"], &processes))
+	require.NotEmpty(t, processes)
+
+	require.Contains(t, string(call("unknown")["error"]), "unknown command")
+
+	require.NoError(t, stdin.Close())
+}
+
+func TestDaytonaFrameTooLarge(t *testing.T) {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], daytonaMaxFrameSize+1)
+
+	_, err := readFrame(bytes.NewReader(header[:]))
+	require.ErrorIs(t, err, errFrameTooLarge)
+}
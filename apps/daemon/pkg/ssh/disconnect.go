@@ -0,0 +1,40 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// isDisconnectError reports whether err is caused by the client going away,
+// e.g. a write to the channel of a closed connection.
+func isDisconnectError(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// connClosed reports whether the connection of ctx is gone.
+func connClosed(ctx ssh.Context) bool {
+	return connCloseReason(ctx) != ""
+}
+
+// logSessionError logs err at the given level, or at debug level if it is
+// caused by a disconnecting client, which is a normal way for sessions to end.
+func logSessionError(level log.Level, err error, format string, args ...any) {
+	if isDisconnectError(err) {
+		level = log.DebugLevel
+	}
+
+	log.StandardLogger().Logf(level, format, args...)
+}
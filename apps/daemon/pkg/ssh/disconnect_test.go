@@ -0,0 +1,73 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDisconnectError(t *testing.T) {
+	for _, err := range []error{
+		io.EOF,
+		io.ErrClosedPipe,
+		net.ErrClosed,
+		syscall.EPIPE,
+		fmt.Errorf("write: %w", syscall.ECONNRESET),
+	} {
+		require.True(t, isDisconnectError(err), err)
+	}
+
+	require.False(t, isDisconnectError(syscall.ENOSPC))
+	require.False(t, isDisconnectError(nil))
+}
+
+func TestClientDisconnectMidOutput(t *testing.T) {
+	hooks := logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	defer logrus.StandardLogger().ReplaceHooks(hooks)
+	hook := logtest.NewGlobal()
+
+	ends := make(chan SessionEnd, 1)
+	s := newTestServer(t)
+	s.OnSessionEnd = func(end SessionEnd) {
+		ends <- end
+	}
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("yes"))
+
+	_, err = io.ReadFull(stdout, make([]byte, 1<<10))
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+
+	select {
+	case end := <-ends:
+		require.Equal(t, CloseReasonClientDisconnect, end.Reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't end")
+	}
+
+	// Apart from the summary of the session, the disconnect is only logged at
+	// debug level.
+	for _, entry := range hook.AllEntries() {
+		if entry.Message != "SSH session closed" {
+			require.Equal(t, logrus.DebugLevel, entry.Level, "unexpected log entry: %s", entry.Message)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	content := `
+# comment
+PLAIN=value
+export EXPORTED=1
+SPACED = spaced value  # trailing comment
+HASH=a#b
+EMPTY=
+SINGLE='literal \n $HOME # not a comment'
+DOUBLE="line\nbreak \"quoted\" \\ # kept" # comment
+EQUALS=a=b
+`
+
+	env, err := parseEnvFile(strings.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"PLAIN=value",
+		"EXPORTED=1",
+		"SPACED=spaced value",
+		"HASH=a#b",
+		"EMPTY=",
+		`SINGLE=literal \n $HOME # not a comment`,
+		"DOUBLE=line\nbreak \"quoted\" \\ # kept",
+		"EQUALS=a=b",
+	}, env)
+}
+
+func TestParseEnvFileErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing separator":   "KEY",
+		"invalid key":         "1KEY=value",
+		"unterminated single": "KEY='value",
+		"unterminated double": `KEY="value`,
+		"trailing characters": `KEY="value" rest`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseEnvFile(strings.NewReader("OK=1\n" + content))
+			require.ErrorContains(t, err, "line 2")
+		})
+	}
+}
+
+func TestEnvFileIsAppliedToSessions(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+
+	s := newTestServer(t)
+	s.EnvFile = envFile
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	run := func() string {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("echo \"$DAYTONA_TEST_VALUE\"")
+		require.NoError(t, err)
+		return strings.TrimSpace(string(output))
+	}
+
+	// A missing file is ignored.
+	require.Equal(t, "", run())
+
+	require.NoError(t, os.WriteFile(envFile, []byte("DAYTONA_TEST_VALUE=first\n"), 0600))
+	require.Equal(t, "first", run())
+
+	require.NoError(t, os.WriteFile(envFile, []byte("DAYTONA_TEST_VALUE='second value'\n"), 0600))
+	require.Equal(t, "second value", run())
+}
+
+func TestEnvFileIsOverriddenByClient(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	require.NoError(t, os.WriteFile(envFile, []byte("LANG=C\nDAYTONA_TEST_VALUE=file\n"), 0600))
+
+	s := newTestServer(t)
+	s.EnvFile = envFile
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, session.Setenv("LANG", "en_US.UTF-8"))
+
+	output, err := session.Output("echo \"$LANG $DAYTONA_TEST_VALUE\"")
+	require.NoError(t, err)
+	require.Equal(t, "en_US.UTF-8 file\n", string(output))
+}
@@ -0,0 +1,411 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrForwardNotFound is returned by CloseForward for unknown or already closed
+// forwards.
+var ErrForwardNotFound = errors.New("forward not found")
+
+// ForwardDirection tells local forwards, connections a client opens through
+// the server, from remote forwards, listeners the server opens for a client.
+type ForwardDirection string
+
+const (
+	ForwardLocal  ForwardDirection = "local"
+	ForwardRemote ForwardDirection = "remote"
+)
+
+// ForwardInfo describes an open port forward.
+type ForwardInfo struct {
+	ID        string
+	Direction ForwardDirection
+	// Network is "tcp" or "unix".
+	Network string
+	// Addr is the destination of local forwards and the address or socket
+	// path remote forwards listen on.
+	Addr      string
+	SessionID string
+	User      string
+	Started   time.Time
+}
+
+// ForwardEnd is passed to the OnForwardEnd callback once a local forward
+// closed.
+type ForwardEnd struct {
+	ForwardInfo
+	// BytesIn counts the bytes the client sent through the forward, BytesOut
+	// the bytes it received.
+	BytesIn  int64
+	BytesOut int64
+	Duration time.Duration
+}
+
+type forward struct {
+	info  ForwardInfo
+	close func()
+	// requested is the address remote forwards were requested for, which
+	// differs from Addr if the client let the server pick the port.
+	requested string
+}
+
+type forwards struct {
+	mu      sync.Mutex
+	nextID  uint64
+	entries map[string]*forward
+}
+
+// Forwards lists the open port forwards of all connections, oldest first.
+func (s *Server) Forwards() []ForwardInfo {
+	s.forwards.mu.Lock()
+	defer s.forwards.mu.Unlock()
+
+	infos := make([]ForwardInfo, 0, len(s.forwards.entries))
+	for _, f := range s.forwards.entries {
+		infos = append(infos, f.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		a, _ := strconv.ParseUint(infos[i].ID, 10, 64)
+		b, _ := strconv.ParseUint(infos[j].ID, 10, 64)
+		return a < b
+	})
+
+	return infos
+}
+
+// CloseForward closes a port forward. Remote forwards stop listening, like
+// when the client cancels them, but connections already forwarded stay open.
+func (s *Server) CloseForward(id string) error {
+	s.forwards.mu.Lock()
+	f, ok := s.forwards.entries[id]
+	delete(s.forwards.entries, id)
+	s.forwards.mu.Unlock()
+
+	if !ok {
+		return ErrForwardNotFound
+	}
+
+	s.sessionEnded()
+	f.close()
+	return nil
+}
+
+// addForward lists f in Forwards until it is removed. Open forwards keep the
+// server from going idle like sessions do, so connections of clients that
+// only forward ports, e.g. with `ssh -N`, count as activity.
+func (s *Server) addForward(ctx ssh.Context, f *forward) string {
+	s.forwards.mu.Lock()
+	if s.forwards.entries == nil {
+		s.forwards.entries = map[string]*forward{}
+	}
+
+	s.forwards.nextID++
+	f.info.ID = strconv.FormatUint(s.forwards.nextID, 10)
+	f.info.SessionID = ctx.SessionID()
+	f.info.User = ctx.User()
+	f.info.Started = time.Now()
+	s.forwards.entries[f.info.ID] = f
+	s.forwards.mu.Unlock()
+
+	if c, ok := ctx.Value(contextKeyConnState).(*trackedConn); ok {
+		c.forwards.Add(1)
+	}
+	s.sessionStarted()
+
+	return f.info.ID
+}
+
+func (s *Server) removeForward(id string) {
+	s.forwards.mu.Lock()
+	_, ok := s.forwards.entries[id]
+	delete(s.forwards.entries, id)
+	s.forwards.mu.Unlock()
+
+	if ok {
+		s.sessionEnded()
+	}
+}
+
+// removeRemoteForward removes the remote forward of a connection the client
+// canceled. Clients cancel forwards by the requested or the assigned address.
+func (s *Server) removeRemoteForward(sessionID, addr string) {
+	s.forwards.mu.Lock()
+	removed := 0
+	for id, f := range s.forwards.entries {
+		if f.info.Direction == ForwardRemote && f.info.SessionID == sessionID && (f.requested == addr || f.info.Addr == addr) {
+			delete(s.forwards.entries, id)
+			removed++
+		}
+	}
+	s.forwards.mu.Unlock()
+
+	for range removed {
+		s.sessionEnded()
+	}
+}
+
+// tcpipForwardRequest is the payload of "tcpip-forward" and
+// "cancel-tcpip-forward" requests, RFC 4254 section 7.1.
+type tcpipForwardRequest struct {
+	BindAddr string
+	BindPort uint32
+}
+
+type tcpipForwardSuccess struct {
+	BindPort uint32
+}
+
+// trackRemoteForwards records the listeners handler opens for TCP and Unix
+// socket forwarding requests. Closing them goes through handler as if the
+// client canceled the forward.
+func (s *Server) trackRemoteForwards(handler ssh.RequestHandler) ssh.RequestHandler {
+	return func(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+		ok, reply := handler(ctx, srv, req)
+		if !ok {
+			return ok, reply
+		}
+
+		var f *forward
+		var cancelType string
+		switch req.Type {
+		case "tcpip-forward":
+			var payload tcpipForwardRequest
+			if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+				return ok, reply
+			}
+
+			port := payload.BindPort
+			var success tcpipForwardSuccess
+			if port == 0 && gossh.Unmarshal(reply, &success) == nil {
+				port = success.BindPort
+			}
+
+			f = &forward{
+				info: ForwardInfo{
+					Direction: ForwardRemote,
+					Network:   "tcp",
+					Addr:      tcpAddr(payload.BindAddr, port),
+				},
+				requested: tcpAddr(payload.BindAddr, payload.BindPort),
+			}
+			cancelType = "cancel-tcpip-forward"
+		case "streamlocal-forward@openssh.com":
+			var payload streamLocalForwardPayload
+			if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+				return ok, reply
+			}
+
+			f = &forward{
+				info: ForwardInfo{
+					Direction: ForwardRemote,
+					Network:   "unix",
+					Addr:      payload.SocketPath,
+				},
+				requested: payload.SocketPath,
+			}
+			cancelType = "cancel-streamlocal-forward@openssh.com"
+		case "cancel-tcpip-forward":
+			var payload tcpipForwardRequest
+			if err := gossh.Unmarshal(req.Payload, &payload); err == nil {
+				s.removeRemoteForward(ctx.SessionID(), tcpAddr(payload.BindAddr, payload.BindPort))
+			}
+			return ok, reply
+		case "cancel-streamlocal-forward@openssh.com":
+			var payload streamLocalForwardPayload
+			if err := gossh.Unmarshal(req.Payload, &payload); err == nil {
+				s.removeRemoteForward(ctx.SessionID(), payload.SocketPath)
+			}
+			return ok, reply
+		default:
+			return ok, reply
+		}
+
+		cancel := &gossh.Request{Type: cancelType, Payload: req.Payload}
+		f.close = func() {
+			_, _ = handler(ctx, srv, cancel)
+		}
+		id := s.addForward(ctx, f)
+
+		go func() {
+			<-ctx.Done()
+			s.removeForward(id)
+		}()
+
+		return ok, reply
+	}
+}
+
+// directTCPIPPayload is the extra data of "direct-tcpip" channels, RFC 4254
+// section 7.2.
+type directTCPIPPayload struct {
+	DestAddr   string
+	DestPort   uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// trackLocalForwards records the channels handler accepts for local TCP or
+// Unix socket forwarding until they are closed, which they are at the latest
+// once the connection ends.
+func (s *Server) trackLocalForwards(network string, handler ssh.ChannelHandler) ssh.ChannelHandler {
+	return func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+		var addr string
+		switch network {
+		case "tcp":
+			var payload directTCPIPPayload
+			if err := gossh.Unmarshal(newChan.ExtraData(), &payload); err == nil {
+				addr = tcpAddr(payload.DestAddr, payload.DestPort)
+			}
+		case "unix":
+			var payload directStreamLocalPayload
+			if err := gossh.Unmarshal(newChan.ExtraData(), &payload); err == nil {
+				addr = payload.SocketPath
+			}
+		}
+
+		handler(srv, conn, &forwardNewChannel{
+			NewChannel: newChan,
+			server:     s,
+			ctx:        ctx,
+			info: ForwardInfo{
+				Direction: ForwardLocal,
+				Network:   network,
+				Addr:      addr,
+			},
+		}, ctx)
+	}
+}
+
+type forwardNewChannel struct {
+	gossh.NewChannel
+	server *Server
+	ctx    ssh.Context
+	info   ForwardInfo
+}
+
+func (c *forwardNewChannel) Accept() (gossh.Channel, <-chan *gossh.Request, error) {
+	ch, reqs, err := c.NewChannel.Accept()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel := &forwardChannel{
+		Channel: ch,
+		server:  c.server,
+		forward: &forward{
+			info: c.info,
+			close: func() {
+				_ = ch.Close()
+			},
+		},
+		counted: c.server.OnForwardEnd != nil,
+		closed:  make(chan struct{}),
+	}
+	c.server.addForward(c.ctx, channel.forward)
+
+	// The handler only ends the forward once either side stopped copying, it
+	// doesn't watch the connection itself.
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			_ = channel.Close()
+		case <-channel.closed:
+		}
+	}()
+
+	return channel, reqs, nil
+}
+
+type forwardChannel struct {
+	gossh.Channel
+	server  *Server
+	forward *forward
+	once    sync.Once
+	closed  chan struct{}
+
+	// counted is set if the traffic is reported to OnForwardEnd.
+	counted  bool
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+}
+
+func (c *forwardChannel) Read(p []byte) (int, error) {
+	n, err := c.Channel.Read(p)
+	if c.counted {
+		c.bytesIn.Add(int64(n))
+	}
+	return n, err
+}
+
+func (c *forwardChannel) Write(p []byte) (int, error) {
+	n, err := c.Channel.Write(p)
+	if c.counted {
+		c.bytesOut.Add(int64(n))
+	}
+	return n, err
+}
+
+func (c *forwardChannel) Close() error {
+	c.once.Do(func() {
+		c.server.removeForward(c.forward.info.ID)
+		close(c.closed)
+
+		if c.counted {
+			c.server.OnForwardEnd(ForwardEnd{
+				ForwardInfo: c.forward.info,
+				BytesIn:     c.bytesIn.Load(),
+				BytesOut:    c.bytesOut.Load(),
+				Duration:    time.Since(c.forward.info.Started),
+			})
+		}
+	})
+
+	return c.Channel.Close()
+}
+
+func tcpAddr(host string, port uint32) string {
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+}
+
+// reverseForwardAllowed decides on remote forward requests. Forwards binding
+// to all interfaces, e.g. for an empty host or "0.0.0.0", or to an external
+// address need AllowGlobalReverseForward.
+func (s *Server) reverseForwardAllowed(ctx ssh.Context, host string, port uint32) bool {
+	if keyOptionsFromContext(ctx).NoPortForwarding {
+		return false
+	}
+
+	if !s.AllowGlobalReverseForward && !isLoopbackHost(host) {
+		log.Warnf("Rejecting remote forward of session %s on %s, only loopback addresses are allowed", ctx.SessionID(), net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)))
+		return false
+	}
+
+	return true
+}
+
+// isLoopbackHost reports whether listening on host only binds to loopback
+// addresses. Host names other than localhost aren't resolved, they could
+// resolve differently when the listener is opened.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,343 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteForwards(t *testing.T) {
+	s := newTestServer(t)
+	client := dialTestServer(t, startTestServer(t, s))
+
+	t.Run("closed by the server", func(t *testing.T) {
+		l, err := client.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		forwards := s.Forwards()
+		require.Len(t, forwards, 1)
+		require.Equal(t, ForwardRemote, forwards[0].Direction)
+		require.Equal(t, "tcp", forwards[0].Network)
+		require.Equal(t, l.Addr().String(), forwards[0].Addr)
+		require.Equal(t, "daytona", forwards[0].User)
+
+		require.NoError(t, s.CloseForward(forwards[0].ID))
+		require.Empty(t, s.Forwards())
+		require.ErrorIs(t, s.CloseForward(forwards[0].ID), ErrForwardNotFound)
+
+		require.Eventually(t, func() bool {
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err == nil {
+				conn.Close()
+			}
+			return err != nil
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("canceled by the client", func(t *testing.T) {
+		l, err := client.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		require.Len(t, s.Forwards(), 1)
+
+		require.NoError(t, l.Close())
+		require.Empty(t, s.Forwards())
+	})
+}
+
+func TestLocalForwards(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	s := newTestServer(t)
+	client := dialTestServer(t, startTestServer(t, s))
+
+	conn, err := client.Dial("tcp", echo.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, make([]byte, 4))
+	require.NoError(t, err)
+
+	forwards := s.Forwards()
+	require.Len(t, forwards, 1)
+	require.Equal(t, ForwardLocal, forwards[0].Direction)
+	require.Equal(t, "tcp", forwards[0].Network)
+	require.Equal(t, echo.Addr().String(), forwards[0].Addr)
+
+	require.NoError(t, s.CloseForward(forwards[0].ID))
+	require.Empty(t, s.Forwards())
+
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err, "the forwarded connection ends")
+}
+
+func TestForwardTraffic(t *testing.T) {
+	const sent, received = 100000, 3000
+
+	dest, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer dest.Close()
+	go func() {
+		conn, err := dest.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// The reply follows the request, so the forward carried all of the
+		// request before the destination closes it.
+		if _, err := io.ReadFull(conn, make([]byte, sent)); err == nil {
+			_, _ = conn.Write(make([]byte, received))
+		}
+	}()
+
+	ends := make(chan ForwardEnd, 1)
+	s := newTestServer(t)
+	s.OnForwardEnd = func(end ForwardEnd) { ends <- end }
+	client := dialTestServer(t, startTestServer(t, s))
+
+	conn, err := client.Dial("tcp", dest.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write(make([]byte, sent))
+	require.NoError(t, err)
+	reply, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Len(t, reply, received)
+
+	select {
+	case end := <-ends:
+		require.Equal(t, ForwardLocal, end.Direction)
+		require.Equal(t, dest.Addr().String(), end.Addr)
+		require.Equal(t, "daytona", end.User)
+		require.Equal(t, int64(sent), end.BytesIn)
+		require.Equal(t, int64(received), end.BytesOut)
+		require.Positive(t, end.Duration)
+	case <-time.After(5 * time.Second):
+		t.Fatal("forward end wasn't reported")
+	}
+}
+
+func TestForwardsEndWithConnection(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer upstream.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := upstream.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	// The client connects through a proxy, which drops the connection to the
+	// server without the client closing it.
+	s := newTestServer(t)
+	addr := startTestServer(t, s)
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer proxy.Close()
+	proxied := make(chan [2]net.Conn, 1)
+	go func() {
+		clientConn, err := proxy.Accept()
+		if err != nil {
+			return
+		}
+		serverConn, err := net.Dial("tcp", addr)
+		if err != nil {
+			clientConn.Close()
+			return
+		}
+		proxied <- [2]net.Conn{clientConn, serverConn}
+		go func() { _, _ = io.Copy(serverConn, clientConn) }()
+		_, _ = io.Copy(clientConn, serverConn)
+	}()
+	client := dialTestServer(t, proxy.Addr().String())
+
+	local, err := client.Dial("tcp", upstream.Addr().String())
+	require.NoError(t, err)
+	defer local.Close()
+	var dest net.Conn
+	select {
+	case dest = <-accepted:
+		defer dest.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("local forward didn't connect")
+	}
+
+	remote, err := client.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.Len(t, s.Forwards(), 2)
+
+	conns := <-proxied
+	require.NoError(t, conns[1].(*net.TCPConn).SetLinger(0))
+	require.NoError(t, conns[1].Close())
+	defer conns[0].Close()
+
+	require.NoError(t, dest.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.ReadAll(dest)
+	require.NoError(t, err, "the local forward is closed")
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", remote.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond, "the remote forward stops listening")
+	require.Eventually(t, func() bool { return len(s.Forwards()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReverseForwardBindAddress(t *testing.T) {
+	for name, tc := range map[string]struct {
+		allowGlobal bool
+		addr        string
+		allowed     bool
+	}{
+		"loopback":               {addr: "127.0.0.1:0", allowed: true},
+		"localhost":              {addr: "localhost:0", allowed: true},
+		"all interfaces":         {addr: "0.0.0.0:0"},
+		"all interfaces allowed": {allowGlobal: true, addr: "0.0.0.0:0", allowed: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.AllowGlobalReverseForward = tc.allowGlobal
+			client := dialTestServer(t, startTestServer(t, s))
+
+			l, err := client.Listen("tcp", tc.addr)
+			if !tc.allowed {
+				require.Error(t, err)
+				require.Empty(t, s.Forwards())
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, l.Close())
+		})
+	}
+}
+
+func TestReverseForwardOriginator(t *testing.T) {
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+	l, err := client.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	origin, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer origin.Close()
+
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+		require.Equal(t, origin.LocalAddr().String(), conn.RemoteAddr().String())
+		require.Equal(t, l.Addr().String(), conn.LocalAddr().String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("forwarded connection wasn't accepted")
+	}
+}
+
+func TestForwardOnlyConnection(t *testing.T) {
+	idle := make(chan struct{}, 1)
+	s := newTestServer(t)
+	s.IdleTimeout = 500 * time.Millisecond
+	s.OnIdle = func() { idle <- struct{}{} }
+	client := dialTestServer(t, startTestServer(t, s))
+
+	// Like `ssh -N -R`, the client opens no session and only sends keepalives.
+	l, err := client.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	keepalives := time.NewTicker(100 * time.Millisecond)
+	for range 10 {
+		<-keepalives.C
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		require.NoError(t, err)
+	}
+	keepalives.Stop()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	reply := make([]byte, 4)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(reply))
+	require.NoError(t, conn.Close())
+
+	require.Empty(t, s.Sessions())
+	require.Len(t, s.Forwards(), 1)
+	select {
+	case <-idle:
+		t.Fatal("server went idle while a forward was open")
+	default:
+	}
+
+	// Without keepalives the connection is closed by IdleTimeout, ending the
+	// forward.
+	_ = runWithTimeout(t, 5*time.Second, client.Wait)
+	require.Eventually(t, func() bool { return len(s.Forwards()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-idle:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnIdle wasn't called")
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	for host, expected := range map[string]bool{
+		"localhost":   true,
+		"127.0.0.1":   true,
+		"127.1.2.3":   true,
+		"::1":         true,
+		"":            false,
+		"0.0.0.0":     false,
+		"::":          false,
+		"*":           false,
+		"192.168.1.1": false,
+		"example.com": false,
+	} {
+		require.Equal(t, expected, isLoopbackHost(host), host)
+	}
+}
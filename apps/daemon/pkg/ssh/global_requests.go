@@ -0,0 +1,33 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// defaultRequestType is the key of the handler gliderlabs/ssh calls for
+// global requests without a handler of their own.
+const defaultRequestType = "default"
+
+// unknownRequestHandler declines global requests the server has no handler
+// for, e.g. keepalive@openssh.com, logging them to find out what clients send.
+func unknownRequestHandler(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+	log.Debugf("Declining unknown global request %.64q of user %s from %s", req.Type, ctx.User(), ctx.RemoteAddr())
+	return false, nil
+}
+
+// withRejectedRequests replaces the handlers of RejectedRequests, so they are
+// declined even if the server supports them.
+func (s *Server) withRejectedRequests(handlers map[string]ssh.RequestHandler) {
+	for _, requestType := range s.RejectedRequests {
+		handlers[requestType] = func(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+			log.Infof("Rejecting global request %q of user %s from %s", req.Type, ctx.User(), ctx.RemoteAddr())
+			return false, nil
+		}
+	}
+}
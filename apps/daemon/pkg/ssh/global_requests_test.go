@@ -0,0 +1,71 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestGlobalRequests(t *testing.T) {
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.DebugLevel)
+	defer logrus.SetLevel(level)
+	hook := logtest.NewGlobal()
+
+	s := newTestServer(t)
+	s.RejectedRequests = []string{"tcpip-forward"}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	logged := func(level logrus.Level, message string) bool {
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == level && strings.Contains(entry.Message, message) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for name, tc := range map[string]struct {
+		requestType string
+		payload     []byte
+		level       logrus.Level
+		message     string
+	}{
+		"unknown": {
+			requestType: "unknown@example.com",
+			level:       logrus.DebugLevel,
+			message:     `Declining unknown global request "unknown@example.com"`,
+		},
+		"rejected": {
+			requestType: "tcpip-forward",
+			payload: gossh.Marshal(struct {
+				Host string
+				Port uint32
+			}{"127.0.0.1", 0}),
+			level:   logrus.InfoLevel,
+			message: `Rejecting global request "tcpip-forward"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ok, _, err := client.SendRequest(tc.requestType, true, tc.payload)
+			require.NoError(t, err)
+			require.False(t, ok)
+			require.True(t, logged(tc.level, tc.message), "%q wasn't logged", tc.message)
+		})
+	}
+
+	// Supported requests are still handled.
+	_, _, err := client.SendRequest("cancel-tcpip-forward", true, gossh.Marshal(struct {
+		Host string
+		Port uint32
+	}{"127.0.0.1", 0}))
+	require.NoError(t, err)
+	require.False(t, logged(logrus.DebugLevel, `"cancel-tcpip-forward"`))
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The OpenSSH host key rotation extension, see PROTOCOL in the OpenSSH
+// sources. The server advertises all of its host keys, and clients with
+// UpdateHostKeys ask it to prove it holds those they don't know yet.
+const (
+	hostKeysRequest      = "hostkeys-00@openssh.com"
+	hostKeysProveRequest = "hostkeys-prove-00@openssh.com"
+)
+
+// withHostKeys advertises the host keys of the server to clients, so they can
+// learn a new key before the one they know is removed. The keys are sent with
+// the first channel or global request of a connection, there is no hook right
+// after authentication.
+func (s *Server) withHostKeys(sshServer *ssh.Server) {
+	if len(s.HostKeys) > 0 {
+		sshServer.HostSigners = append([]ssh.Signer(nil), s.HostKeys...)
+	}
+
+	for name, handler := range sshServer.ChannelHandlers {
+		sshServer.ChannelHandlers[name] = func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+			advertiseHostKeys(ctx, srv, conn)
+			handler(srv, conn, newChan, ctx)
+		}
+	}
+
+	// Listeners don't restrict proofs, they aren't forwarding requests, but
+	// RejectedRequests may.
+	if _, ok := sshServer.RequestHandlers[hostKeysProveRequest]; !ok {
+		sshServer.RequestHandlers[hostKeysProveRequest] = proveHostKeys
+	}
+	for name, handler := range sshServer.RequestHandlers {
+		sshServer.RequestHandlers[name] = func(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+			if conn, ok := ctx.Value(ssh.ContextKeyConn).(*gossh.ServerConn); ok {
+				advertiseHostKeys(ctx, srv, conn)
+			}
+			return handler(ctx, srv, req)
+		}
+	}
+}
+
+// advertiseHostKeys sends the host keys of srv to the client of conn once.
+func advertiseHostKeys(ctx ssh.Context, srv *ssh.Server, conn *gossh.ServerConn) {
+	c, ok := ctx.Value(contextKeyConnState).(*trackedConn)
+	if !ok {
+		return
+	}
+
+	c.hostKeysOnce.Do(func() {
+		var payload []byte
+		for _, signer := range srv.HostSigners {
+			payload = appendSSHString(payload, signer.PublicKey().Marshal())
+		}
+		if _, _, err := conn.SendRequest(hostKeysRequest, false, payload); err != nil {
+			log.Debugf("Failed to advertise host keys to %s: %v", ctx.RemoteAddr(), err)
+		}
+	})
+}
+
+// proveHostKeys signs the session ID with each host key the client asks for,
+// declining if it asks for a key the server doesn't have. RSA keys sign with
+// rsa-sha2-512, the algorithm OpenSSH clients prefer for the key exchange and
+// therefore expect.
+func proveHostKeys(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+	conn, ok := ctx.Value(ssh.ContextKeyConn).(*gossh.ServerConn)
+	if !ok {
+		return false, nil
+	}
+
+	signers := make(map[string]gossh.Signer, len(srv.HostSigners))
+	for _, signer := range srv.HostSigners {
+		signers[string(signer.PublicKey().Marshal())] = signer
+	}
+
+	var reply []byte
+	for rest := req.Payload; len(rest) > 0; {
+		var key []byte
+		key, rest, ok = parseSSHString(rest)
+		if !ok {
+			return false, nil
+		}
+		signer, ok := signers[string(key)]
+		if !ok {
+			log.Debugf("Declining to prove unknown host key for %s", ctx.RemoteAddr())
+			return false, nil
+		}
+
+		data := appendSSHString(nil, []byte(hostKeysProveRequest))
+		data = appendSSHString(data, conn.SessionID())
+		data = appendSSHString(data, key)
+		sig, err := signHostKeyProof(signer, data)
+		if err != nil {
+			log.Warnf("Failed to prove host key for %s: %v", ctx.RemoteAddr(), err)
+			return false, nil
+		}
+		reply = appendSSHString(reply, gossh.Marshal(sig))
+	}
+
+	return true, reply
+}
+
+func signHostKeyProof(signer gossh.Signer, data []byte) (*gossh.Signature, error) {
+	if algSigner, ok := signer.(gossh.AlgorithmSigner); ok && signer.PublicKey().Type() == gossh.KeyAlgoRSA {
+		return algSigner.SignWithAlgorithm(rand.Reader, data, gossh.KeyAlgoRSASHA512)
+	}
+	return signer.Sign(rand.Reader, data)
+}
+
+func appendSSHString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func parseSSHString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
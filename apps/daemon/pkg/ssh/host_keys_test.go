@@ -0,0 +1,91 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestHostKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaSigner, err := gossh.NewSignerFromKey(rsaKey)
+	require.NoError(t, err)
+	keys := []gossh.Signer{newTestSigner(t), rsaSigner}
+
+	s := newTestServer(t)
+	s.HostKeys = []ssh.Signer{keys[0], keys[1]}
+	addr := startTestServer(t, s)
+
+	tcp, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	conn, chans, reqs, err := gossh.NewClientConn(tcp, addr, &gossh.ClientConfig{
+		User:            "daytona",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		for ch := range chans {
+			_ = ch.Reject(gossh.Prohibited, "")
+		}
+	}()
+
+	// The keys are advertised with the first channel.
+	ch, chReqs, err := conn.OpenChannel("session", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+	go gossh.DiscardRequests(chReqs)
+
+	var advertised []byte
+	select {
+	case req := <-reqs:
+		require.Equal(t, hostKeysRequest, req.Type)
+		require.False(t, req.WantReply)
+		advertised = req.Payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("host keys weren't advertised")
+	}
+	go gossh.DiscardRequests(reqs)
+
+	var expected []byte
+	for _, key := range keys {
+		expected = appendSSHString(expected, key.PublicKey().Marshal())
+	}
+	require.Equal(t, expected, advertised)
+
+	ok, reply, err := conn.SendRequest(hostKeysProveRequest, true, advertised)
+	require.NoError(t, err)
+	require.True(t, ok)
+	for _, key := range keys {
+		var blob []byte
+		blob, reply, ok = parseSSHString(reply)
+		require.True(t, ok)
+		var sig gossh.Signature
+		require.NoError(t, gossh.Unmarshal(blob, &sig))
+		if key.PublicKey().Type() == gossh.KeyAlgoRSA {
+			require.Equal(t, gossh.KeyAlgoRSASHA512, sig.Format)
+		}
+
+		data := appendSSHString(nil, []byte(hostKeysProveRequest))
+		data = appendSSHString(data, conn.SessionID())
+		data = appendSSHString(data, key.PublicKey().Marshal())
+		require.NoError(t, key.PublicKey().Verify(data, &sig))
+	}
+	require.Empty(t, reply)
+
+	unknown := appendSSHString(nil, newTestSigner(t).PublicKey().Marshal())
+	ok, _, err = conn.SendRequest(hostKeysProveRequest, true, unknown)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
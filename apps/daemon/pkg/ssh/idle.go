@@ -0,0 +1,54 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// idleTracker counts the active sessions and port forwards of the server and
+// reports when the last one ended.
+type idleTracker struct {
+	mu     sync.Mutex
+	active int
+	// generation invalidates pending idle notifications once a new session
+	// starts before they fire.
+	generation uint64
+	timer      *time.Timer
+}
+
+func (s *Server) sessionStarted() {
+	s.idle.mu.Lock()
+	defer s.idle.mu.Unlock()
+
+	s.idle.active++
+	s.idle.generation++
+	if s.idle.timer != nil {
+		s.idle.timer.Stop()
+		s.idle.timer = nil
+	}
+}
+
+func (s *Server) sessionEnded() {
+	s.idle.mu.Lock()
+	defer s.idle.mu.Unlock()
+
+	s.idle.active--
+	if s.idle.active > 0 || s.OnIdle == nil {
+		return
+	}
+
+	generation := s.idle.generation
+	s.idle.timer = time.AfterFunc(s.OnIdleDelay, func() {
+		s.idle.mu.Lock()
+		stale := s.idle.generation != generation || s.idle.active > 0
+		s.idle.timer = nil
+		s.idle.mu.Unlock()
+
+		if !stale {
+			s.OnIdle()
+		}
+	})
+}
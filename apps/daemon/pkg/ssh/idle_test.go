@@ -0,0 +1,70 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnIdleDebounce(t *testing.T) {
+	var calls atomic.Int32
+
+	s := newTestServer(t)
+	s.OnIdleDelay = 200 * time.Millisecond
+	s.OnIdle = func() {
+		calls.Add(1)
+	}
+
+	// Sessions overlapping or reconnecting within the delay don't count as
+	// idle.
+	s.sessionStarted()
+	s.sessionStarted()
+	s.sessionEnded()
+	s.sessionEnded()
+	time.Sleep(100 * time.Millisecond)
+	s.sessionStarted()
+	s.sessionEnded()
+
+	time.Sleep(100 * time.Millisecond)
+	require.EqualValues(t, 0, calls.Load())
+
+	require.Eventually(t, func() bool {
+		return calls.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// A session that is still running keeps the server busy.
+	s.sessionStarted()
+	time.Sleep(300 * time.Millisecond)
+	require.EqualValues(t, 1, calls.Load())
+
+	s.sessionEnded()
+	require.Eventually(t, func() bool {
+		return calls.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOnIdleAfterLastSession(t *testing.T) {
+	idle := make(chan struct{}, 1)
+
+	s := newTestServer(t)
+	s.OnIdle = func() {
+		idle <- struct{}{}
+	}
+
+	client := dialTestServer(t, startTestServer(t, s))
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, session.Run("true"))
+
+	select {
+	case <-idle:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnIdle wasn't called")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"io"
+)
+
+// crlfReader translates CRLF line endings to LF. A lone CR is passed through.
+type crlfReader struct {
+	r *bufio.Reader
+}
+
+func newCRLFReader(r io.Reader) *crlfReader {
+	return &crlfReader{r: bufio.NewReader(r)}
+}
+
+func (c *crlfReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		// Return what is translated so far instead of blocking on the client.
+		if n > 0 && c.r.Buffered() == 0 {
+			break
+		}
+
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+
+		// A CR at the end of the buffered input waits for the next byte to
+		// tell whether it starts a CRLF.
+		if b == '\r' {
+			if next, err := c.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+
+		p[n] = b
+		n++
+	}
+
+	return n, nil
+}
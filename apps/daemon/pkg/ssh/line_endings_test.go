@@ -0,0 +1,34 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRLFReader(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{input: "a\r\nb\r\n", want: "a\nb\n"},
+		{input: "a\rb\r", want: "a\rb\r"},
+		{input: "a\r\r\n\n", want: "a\r\n\n"},
+		{input: "\x00\xff\r\n\x01", want: "\x00\xff\n\x01"},
+	} {
+		output, err := io.ReadAll(newCRLFReader(strings.NewReader(tc.input)))
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(output), "input %q", tc.input)
+
+		// CRLFs split across reads are translated, too.
+		output, err = io.ReadAll(newCRLFReader(iotest.OneByteReader(strings.NewReader(tc.input))))
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(output), "input %q read byte by byte", tc.input)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"golang.org/x/sys/unix"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultServeRetryBackoff = time.Second
+	maxServeRetryBackoff     = 30 * time.Second
+	// serveRetryReset is how long the server has to serve after a restart
+	// until failures count from zero again.
+	serveRetryReset = time.Minute
+)
+
+// listen opens the TCP listener of the server. Go sets SO_REUSEADDR on
+// listeners on its own, so a restarted server can bind its port while
+// connections of the previous one are still in TIME_WAIT.
+func (s *Server) listen(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if s.ReusePort {
+		lc.Control = func(network, address string, c syscall.RawConn) error {
+			var err error
+			ctrlErr := c.Control(func(fd uintptr) {
+				err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			})
+			if ctrlErr != nil {
+				return ctrlErr
+			}
+			return err
+		}
+	}
+
+	l, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ListenBacklog > 0 {
+		if err := setListenBacklog(l, s.ListenBacklog); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to set listen backlog: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+// setListenBacklog changes the backlog of l. net.Listen always uses the
+// system maximum, but calling listen(2) again on a listening socket updates
+// its backlog.
+func setListenBacklog(l net.Listener, backlog int) error {
+	tcp, ok := l.(*net.TCPListener)
+	if !ok {
+		return fmt.Errorf("unsupported listener %T", l)
+	}
+
+	conn, err := tcp.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var listenErr error
+	err = conn.Control(func(fd uintptr) {
+		listenErr = unix.Listen(int(fd), backlog)
+	})
+	if err != nil {
+		return err
+	}
+	return listenErr
+}
+
+// serve serves l until the server is closed. If accepting connections fails,
+// like on errors of the network stack ssh.Server doesn't retry itself, it
+// listens on addr again up to ServeRetries times in a row, waiting
+// ServeRetryBackoff, doubled on every attempt. Failing to listen again is
+// fatal.
+func (s *Server) serve(l net.Listener, addr string) error {
+	backoff := s.ServeRetryBackoff
+	if backoff <= 0 {
+		backoff = defaultServeRetryBackoff
+	}
+
+	failures := 0
+	for {
+		started := time.Now()
+		err := s.sshServer.Serve(l)
+		if errors.Is(err, ssh.ErrServerClosed) || s.closing.Load() {
+			return ssh.ErrServerClosed
+		}
+
+		if time.Since(started) >= serveRetryReset {
+			failures = 0
+		}
+		if failures++; failures > s.ServeRetries {
+			return err
+		}
+
+		delay := min(backoff<<(failures-1), maxServeRetryBackoff)
+		log.Warnf("SSH server failed: %v, listening again in %s (attempt %d of %d)", err, delay, failures, s.ServeRetries)
+		time.Sleep(delay)
+
+		if l, err = s.listen(addr); err != nil {
+			return fmt.Errorf("failed to listen again: %w", err)
+		}
+		if !s.setListener(l) {
+			l.Close()
+			return ssh.ErrServerClosed
+		}
+	}
+}
+
+// setListener records l as the listener Close closes, as ssh.Server only
+// closes those it already serves. It returns false if the server is closing.
+func (s *Server) setListener(l net.Listener) bool {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+
+	if s.closing.Load() {
+		return false
+	}
+	s.listener = l
+	return true
+}
+
+func (s *Server) closeListener() {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+)
+
+// serveOn serves s on addr, returning a func stopping it.
+func serveOn(t *testing.T, s *Server, addr string) (string, func()) {
+	t.Helper()
+
+	l, err := s.listen(addr)
+	require.NoError(t, err)
+
+	sshServer := s.newSSHServer()
+	go func() {
+		_ = sshServer.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = sshServer.Close()
+	})
+
+	return l.Addr().String(), func() {
+		_ = sshServer.Close()
+	}
+}
+
+func TestListenAfterRestart(t *testing.T) {
+	s := newTestServer(t)
+	s.ListenBacklog = 16
+	addr, stop := serveOn(t, s, "127.0.0.1:0")
+
+	client := dialTestServer(t, addr)
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, session.Run("true"))
+
+	// Closing the server first leaves its side of the connection in
+	// TIME_WAIT, which must not keep the restarted server from binding.
+	stop()
+
+	restarted := newTestServer(t)
+	restarted.ListenBacklog = 16
+	_, _ = serveOn(t, restarted, addr)
+
+	client = dialTestServer(t, addr)
+	session, err = client.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, session.Run("true"))
+}
+
+func TestListenReusePort(t *testing.T) {
+	s := newTestServer(t)
+	s.ReusePort = true
+	addr, _ := serveOn(t, s, "127.0.0.1:0")
+
+	_, err := newTestServer(t).listen(addr)
+	require.Error(t, err, "the port is taken without SO_REUSEPORT")
+
+	takeover := newTestServer(t)
+	takeover.ReusePort = true
+	_, _ = serveOn(t, takeover, addr)
+
+	client := dialTestServer(t, addr)
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, session.Run("true"))
+}
+
+// failingListener fails to accept connections, like a broken network stack.
+type failingListener struct {
+	net.Listener
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	return nil, errors.New("accept failed")
+}
+
+func TestServeRetries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		retries int
+		// occupied keeps the port from being listened on again.
+		occupied bool
+		err      string
+	}{
+		"listens again": {retries: 2},
+		"no retries":    {err: "accept failed"},
+		"listen fails":  {retries: 2, occupied: true, err: "failed to listen again"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			addr := l.Addr().String()
+			if tc.occupied {
+				defer l.Close()
+				l, err = net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+			}
+
+			s := newTestServer(t)
+			s.ServeRetries = tc.retries
+			s.ServeRetryBackoff = 10 * time.Millisecond
+			s.sshServer = s.newSSHServer()
+			served := make(chan error, 1)
+			go func() {
+				served <- s.serve(&failingListener{Listener: l}, addr)
+			}()
+
+			if tc.err != "" {
+				select {
+				case err := <-served:
+					require.ErrorContains(t, err, tc.err)
+				case <-time.After(5 * time.Second):
+					t.Fatal("server didn't fail")
+				}
+				return
+			}
+
+			// Connections to the failing listener queue up until it is closed.
+			require.Eventually(t, func() bool {
+				s.listenerMu.Lock()
+				defer s.listenerMu.Unlock()
+
+				return s.listener != nil
+			}, 5*time.Second, 10*time.Millisecond)
+			client := dialTestServer(t, addr)
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			require.NoError(t, session.Run("true"))
+
+			require.NoError(t, s.Close())
+			select {
+			case err := <-served:
+				require.ErrorIs(t, err, ssh.ErrServerClosed)
+			case <-time.After(5 * time.Second):
+				t.Fatal("server didn't stop")
+			}
+		})
+	}
+}
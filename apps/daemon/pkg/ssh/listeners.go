@@ -0,0 +1,148 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Listener is an address the server accepts connections on next to the SSH
+// port, restricted to part of what the server offers, e.g. an SFTP-only
+// endpoint that is firewalled apart from interactive access. Authentication
+// and all other settings are shared with the SSH port.
+type Listener struct {
+	Addr string
+	// Channels lists the channel types clients may open, e.g. "session" or
+	// "direct-tcpip". Nil allows all. Unless it is nil, global requests, i.e.
+	// remote forwards, are declined, too.
+	Channels []string
+	// Subsystems lists the subsystems sessions may request, e.g. "sftp". Nil
+	// allows all, an empty list none.
+	Subsystems []string
+	// NoShell rejects shells and commands, for SFTP-only endpoints.
+	NoShell bool
+}
+
+func (l *Listener) allowsChannel(channelType string) bool {
+	return l == nil || l.Channels == nil || slices.Contains(l.Channels, channelType)
+}
+
+func (l *Listener) allowsGlobalRequests() bool {
+	return l == nil || l.Channels == nil
+}
+
+// allowsSessionRequest reports whether a session may start a shell, command
+// or subsystem, as named by requestType.
+func (l *Listener) allowsSessionRequest(session ssh.Session, requestType string) bool {
+	if l == nil {
+		return true
+	}
+
+	switch requestType {
+	case "shell", "exec":
+		return !l.NoShell
+	case "subsystem":
+		return l.Subsystems == nil || slices.Contains(l.Subsystems, session.Subsystem())
+	default:
+		return true
+	}
+}
+
+// restrictedListener marks the connections it accepts with the Listener they
+// came in on, for connCallback to find.
+type restrictedListener struct {
+	net.Listener
+	config *Listener
+}
+
+type restrictedConn struct {
+	net.Conn
+	config *Listener
+}
+
+func (l *restrictedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &restrictedConn{Conn: conn, config: l.config}, nil
+}
+
+// listenerFromContext returns the Listener of the connection, or nil for the
+// SSH port.
+func listenerFromContext(ctx ssh.Context) *Listener {
+	if c, ok := ctx.Value(contextKeyConnState).(*trackedConn); ok {
+		return c.listener
+	}
+	return nil
+}
+
+// listenExtra opens the listeners of Listeners. On failure, the ones opened
+// before are closed again.
+func (s *Server) listenExtra() ([]net.Listener, error) {
+	var listeners []net.Listener
+	for i := range s.Listeners {
+		l, err := s.listen(s.Listeners[i].Addr)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", s.Listeners[i].Addr, err)
+		}
+		listeners = append(listeners, &restrictedListener{Listener: l, config: &s.Listeners[i]})
+	}
+	return listeners, nil
+}
+
+// withListeners rejects the channels and global requests Listeners don't
+// allow. Sessions are restricted by SessionRequestCallback.
+func (s *Server) withListeners(sshServer *ssh.Server) {
+	for name, handler := range sshServer.ChannelHandlers {
+		sshServer.ChannelHandlers[name] = func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+			if !listenerFromContext(ctx).allowsChannel(newChan.ChannelType()) {
+				log.Warnf("Rejecting %s channel of user %s on %s, the endpoint doesn't allow it", newChan.ChannelType(), ctx.User(), ctx.LocalAddr())
+				_ = newChan.Reject(gossh.Prohibited, fmt.Sprintf("%s channels are not allowed on this endpoint", newChan.ChannelType()))
+				return
+			}
+			handler(srv, conn, newChan, ctx)
+		}
+	}
+
+	for name, handler := range sshServer.RequestHandlers {
+		if name == defaultRequestType {
+			continue
+		}
+		sshServer.RequestHandlers[name] = func(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+			if !listenerFromContext(ctx).allowsGlobalRequests() {
+				log.Warnf("Rejecting global request %q of user %s on %s, the endpoint doesn't allow it", req.Type, ctx.User(), ctx.LocalAddr())
+				return false, nil
+			}
+			return handler(ctx, srv, req)
+		}
+	}
+}
+
+func (s *Server) sessionRequestAllowed(session ssh.Session, requestType string) bool {
+	// Subsystems would bypass the command a key is restricted to.
+	if requestType == "subsystem" && keyOptionsFromContext(session.Context()).Command != "" {
+		log.Warnf("Rejecting %.64q subsystem of session %s, the key forces a command", session.Subsystem(), session.Context().SessionID())
+		return false
+	}
+	if !listenerFromContext(session.Context()).allowsSessionRequest(session, requestType) {
+		what := requestType
+		if requestType == "subsystem" {
+			what = fmt.Sprintf("%.64q subsystem", session.Subsystem())
+		}
+		log.Warnf("Rejecting %s of session %s on %s, the endpoint doesn't allow it", what, session.Context().SessionID(), session.LocalAddr())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestListeners(t *testing.T) {
+	s := newTestServer(t)
+	s.Listeners = []Listener{
+		{Addr: "127.0.0.1:0", Channels: []string{"session"}, Subsystems: []string{"sftp"}, NoShell: true},
+		{Addr: "127.0.0.1:0", Subsystems: []string{}},
+	}
+	sshServer := s.newSSHServer()
+	addr := serveTestServer(t, sshServer)
+
+	listeners, err := s.listenExtra()
+	require.NoError(t, err)
+	for _, l := range listeners {
+		go func() {
+			_ = sshServer.Serve(l)
+		}()
+	}
+	sftpAddr, shellAddr := listeners[0].Addr().String(), listeners[1].Addr().String()
+
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer upstream.Close()
+
+	for name, tc := range map[string]struct {
+		addr    string
+		shell   bool
+		sftp    bool
+		forward bool
+	}{
+		"ssh port":  {addr: addr, shell: true, sftp: true, forward: true},
+		"sftp only": {addr: sftpAddr, sftp: true},
+		"shell":     {addr: shellAddr, shell: true, forward: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := dialTestServer(t, tc.addr)
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			output, err := session.Output("echo shell")
+			if tc.shell {
+				require.NoError(t, err)
+				require.Equal(t, "shell\n", string(output))
+			} else {
+				require.Error(t, err)
+			}
+
+			sftpClient, err := sftp.NewClient(client)
+			if tc.sftp {
+				require.NoError(t, err)
+				_, err = sftpClient.Getwd()
+				require.NoError(t, err)
+				require.NoError(t, sftpClient.Close())
+			} else {
+				require.Error(t, err)
+			}
+
+			conn, err := client.Dial("tcp", upstream.Addr().String())
+			if tc.forward {
+				require.NoError(t, err)
+				require.NoError(t, conn.Close())
+			} else {
+				var openErr *gossh.OpenChannelError
+				require.ErrorAs(t, err, &openErr)
+				require.Equal(t, gossh.Prohibited, openErr.Reason)
+			}
+
+			remote, err := client.Listen("tcp", "127.0.0.1:0")
+			if tc.forward {
+				require.NoError(t, err)
+				require.NoError(t, remote.Close())
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
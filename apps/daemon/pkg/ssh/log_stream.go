@@ -0,0 +1,239 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logsSubsystem streams the recent and then the new lines of Server.Logs as
+// plain text until the client closes the channel. It is only available to
+// identities with Admin set.
+const logsSubsystem = "daytona-logs"
+
+const (
+	defaultLogBufferSize      = 1 << 20
+	defaultLogStreamRate      = 64 << 10
+	defaultLogStreamMaxBytes  = 16 << 20
+	logSubscriberBacklogLines = 1024
+
+	logsDeniedMessage = "Permission denied: streaming logs requires admin access."
+)
+
+var errLogsDisabled = errors.New("log streaming is not enabled")
+
+// LogBuffer is a logrus hook keeping the most recent log lines of the process
+// for the daytona-logs subsystem, e.g. `log.AddHook(buffer)`. Lines are
+// formatted as plain text, independent of the formatter of the logger.
+type LogBuffer struct {
+	// Size bounds the total bytes of the lines kept. Defaults to 1 MiB.
+	Size int
+
+	mu          sync.Mutex
+	lines       [][]byte
+	bytes       int
+	subscribers map[*logSubscriber]struct{}
+}
+
+type logSubscriber struct {
+	lines   chan []byte
+	dropped int
+}
+
+var logBufferFormatter = &log.TextFormatter{DisableColors: true, FullTimestamp: true}
+
+func (b *LogBuffer) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (b *LogBuffer) Fire(entry *log.Entry) error {
+	line, err := logBufferFormatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := b.Size
+	if size <= 0 {
+		size = defaultLogBufferSize
+	}
+
+	b.lines = append(b.lines, line)
+	b.bytes += len(line)
+	for b.bytes > size && len(b.lines) > 0 {
+		b.bytes -= len(b.lines[0])
+		b.lines = b.lines[1:]
+	}
+
+	// Logging must never wait for slow streams, so lines are dropped for
+	// subscribers that fall behind.
+	for sub := range b.subscribers {
+		select {
+		case sub.lines <- line:
+		default:
+			sub.dropped++
+		}
+	}
+
+	return nil
+}
+
+// subscribe returns the lines kept so far and a subscriber receiving every
+// line logged from then on.
+func (b *LogBuffer) subscribe() ([][]byte, *logSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = map[*logSubscriber]struct{}{}
+	}
+
+	sub := &logSubscriber{lines: make(chan []byte, logSubscriberBacklogLines)}
+	b.subscribers[sub] = struct{}{}
+
+	return append([][]byte(nil), b.lines...), sub
+}
+
+func (b *LogBuffer) unsubscribe(sub *logSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, sub)
+}
+
+// takeDropped returns the number of lines dropped for sub since the last call.
+func (b *LogBuffer) takeDropped(sub *logSubscriber) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := sub.dropped
+	sub.dropped = 0
+	return dropped
+}
+
+func (s *Server) logsHandler(session ssh.Session) error {
+	identity, _ := IdentityFromContext(session.Context())
+	if !identity.Admin {
+		log.Warnf("Denying %s subsystem to user %s of session %s", logsSubsystem, session.User(), session.Context().SessionID())
+		_, _ = fmt.Fprintln(session.Stderr(), logsDeniedMessage)
+		setCloseReason(session, CloseReasonPolicyDenied)
+		_ = session.Exit(1)
+		return nil
+	}
+
+	if s.Logs == nil {
+		return errLogsDisabled
+	}
+
+	// The session context only ends with the connection, so the stream stops
+	// as soon as the client closes its side of the channel.
+	ctx, cancel := context.WithCancel(session.Context())
+	defer cancel()
+	go func() {
+		_, _ = io.Copy(io.Discard, session)
+		cancel()
+	}()
+
+	backlog, sub := s.Logs.subscribe()
+	defer s.Logs.unsubscribe(sub)
+
+	w := &rateLimitedWriter{
+		w:        session,
+		ctx:      ctx,
+		rate:     s.LogStreamRate,
+		maxBytes: s.LogStreamMaxBytes,
+	}
+
+	err := w.writeLines(backlog)
+	for err == nil {
+		select {
+		case line := <-sub.lines:
+			if dropped := s.Logs.takeDropped(sub); dropped > 0 {
+				line = append([]byte(fmt.Sprintf("... %d lines dropped\n", dropped)), line...)
+			}
+			err = w.writeLines([][]byte{line})
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if errors.Is(err, errLogStreamLimit) {
+		_, _ = fmt.Fprintf(session.Stderr(), "Log stream limit of %d bytes reached.\n", w.limit())
+		_ = session.Exit(0)
+		return nil
+	}
+	if !errors.Is(err, context.Canceled) {
+		logSessionError(log.WarnLevel, err, "Failed to stream logs to session %s: %v", session.Context().SessionID(), err)
+	}
+
+	return nil
+}
+
+var errLogStreamLimit = errors.New("log stream limit reached")
+
+// rateLimitedWriter bounds both the rate of a log stream in bytes per second
+// and its total volume.
+type rateLimitedWriter struct {
+	w        io.Writer
+	ctx      context.Context
+	rate     int
+	maxBytes int64
+
+	start   time.Time
+	written int64
+}
+
+func (w *rateLimitedWriter) limit() int64 {
+	if w.maxBytes <= 0 {
+		return defaultLogStreamMaxBytes
+	}
+	return w.maxBytes
+}
+
+func (w *rateLimitedWriter) writeLines(lines [][]byte) error {
+	if w.start.IsZero() {
+		w.start = time.Now()
+	}
+
+	rate := w.rate
+	if rate <= 0 {
+		rate = defaultLogStreamRate
+	}
+
+	for _, line := range lines {
+		if w.written+int64(len(line)) > w.limit() {
+			return errLogStreamLimit
+		}
+
+		if _, err := w.w.Write(line); err != nil {
+			return err
+		}
+		w.written += int64(len(line))
+
+		// Wait until the bytes written so far are within the rate.
+		due := w.start.Add(time.Duration(w.written) * time.Second / time.Duration(rate))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-w.ctx.Done():
+				timer.Stop()
+				return w.ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
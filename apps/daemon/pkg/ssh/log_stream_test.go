@@ -0,0 +1,195 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newTestLogger(buffer *LogBuffer) *log.Logger {
+	logger := log.New()
+	logger.Out = io.Discard
+	logger.AddHook(buffer)
+	return logger
+}
+
+// openLogsSubsystem starts the daytona-logs subsystem on a raw channel, since
+// gossh.Session doesn't collect stderr and exit status of subsystems.
+func openLogsSubsystem(t *testing.T, client *gossh.Client) (gossh.Channel, <-chan *gossh.Request) {
+	t.Helper()
+
+	ch, reqs, err := client.OpenChannel("session", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ch.Close()
+	})
+
+	ok, err := ch.SendRequest("subsystem", true, gossh.Marshal(struct{ Name string }{logsSubsystem}))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	return ch, reqs
+}
+
+func waitExitStatus(t *testing.T, reqs <-chan *gossh.Request) uint32 {
+	t.Helper()
+
+	var exitStatus struct{ Status uint32 }
+	err := runWithTimeout(t, 5*time.Second, func() error {
+		for req := range reqs {
+			if req.Type == "exit-status" {
+				return gossh.Unmarshal(req.Payload, &exitStatus)
+			}
+		}
+		return io.EOF
+	})
+	require.NoError(t, err)
+
+	return exitStatus.Status
+}
+
+func TestLogsSubsystem(t *testing.T) {
+	buffer := &LogBuffer{}
+	logger := newTestLogger(buffer)
+	logger.Info("secret log line")
+
+	s := newTestServer(t)
+	s.Logs = buffer
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{
+			"admin": {ID: "admin", Admin: true},
+			"user":  {ID: "user"},
+		},
+	}
+	addr := startTestServer(t, s)
+
+	t.Run("denied", func(t *testing.T) {
+		ch, reqs := openLogsSubsystem(t, dialTestServer(t, addr, gossh.Password("user")))
+
+		require.Equal(t, uint32(1), waitExitStatus(t, reqs))
+
+		stdout, err := io.ReadAll(ch)
+		require.NoError(t, err)
+		require.Empty(t, stdout)
+
+		stderr, err := io.ReadAll(ch.Stderr())
+		require.NoError(t, err)
+		require.Equal(t, logsDeniedMessage+"\n", string(stderr))
+	})
+
+	t.Run("permitted", func(t *testing.T) {
+		ch, _ := openLogsSubsystem(t, dialTestServer(t, addr, gossh.Password("admin")))
+		lines := bufio.NewScanner(ch)
+
+		readLine := func() string {
+			var line string
+			err := runWithTimeout(t, 5*time.Second, func() error {
+				if !lines.Scan() {
+					return io.ErrUnexpectedEOF
+				}
+				line = lines.Text()
+				return nil
+			})
+			require.NoError(t, err)
+			return line
+		}
+
+		require.Contains(t, readLine(), "secret log line")
+
+		logger.Warn("new log line")
+		line := readLine()
+		require.Contains(t, line, "level=warning")
+		require.Contains(t, line, "new log line")
+
+		// Closing the channel ends the stream.
+		require.NoError(t, ch.Close())
+		require.Eventually(t, func() bool {
+			buffer.mu.Lock()
+			defer buffer.mu.Unlock()
+			return len(buffer.subscribers) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestLogsSubsystemDisabled(t *testing.T) {
+	s := newTestServer(t)
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{"admin": {ID: "admin", Admin: true}},
+	}
+	ch, reqs := openLogsSubsystem(t, dialTestServer(t, startTestServer(t, s), gossh.Password("admin")))
+
+	require.Equal(t, uint32(1), waitExitStatus(t, reqs))
+
+	stderr, err := io.ReadAll(ch.Stderr())
+	require.NoError(t, err)
+	require.Contains(t, string(stderr), errLogsDisabled.Error())
+}
+
+func TestLogsSubsystemMaxBytes(t *testing.T) {
+	buffer := &LogBuffer{}
+	logger := newTestLogger(buffer)
+	for i := 0; i < 100; i++ {
+		logger.Info(strings.Repeat("x", 100))
+	}
+
+	s := newTestServer(t)
+	s.Logs = buffer
+	s.LogStreamMaxBytes = 1000
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{"admin": {ID: "admin", Admin: true}},
+	}
+	ch, reqs := openLogsSubsystem(t, dialTestServer(t, startTestServer(t, s), gossh.Password("admin")))
+
+	require.Equal(t, uint32(0), waitExitStatus(t, reqs))
+
+	stdout, err := io.ReadAll(ch)
+	require.NoError(t, err)
+	require.NotEmpty(t, stdout)
+	require.LessOrEqual(t, len(stdout), 1000)
+
+	stderr, err := io.ReadAll(ch.Stderr())
+	require.NoError(t, err)
+	require.Equal(t, "Log stream limit of 1000 bytes reached.\n", string(stderr))
+}
+
+func TestLogBufferSize(t *testing.T) {
+	buffer := &LogBuffer{Size: 500}
+	logger := newTestLogger(buffer)
+	for i := 0; i < 100; i++ {
+		logger.Infof("line %d", i)
+	}
+
+	lines, sub := buffer.subscribe()
+	buffer.unsubscribe(sub)
+
+	require.LessOrEqual(t, buffer.bytes, 500)
+	require.NotEmpty(t, lines)
+	require.Contains(t, string(lines[len(lines)-1]), "line 99")
+	require.NotContains(t, string(bytes.Join(lines, nil)), "line 0\"")
+}
+
+func TestRateLimitedWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &rateLimitedWriter{w: &out, ctx: context.Background(), rate: 10000, maxBytes: 1 << 20}
+
+	start := time.Now()
+	require.NoError(t, w.writeLines([][]byte{make([]byte, 2500), make([]byte, 2500)}))
+	require.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+	require.Equal(t, 5000, out.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = &rateLimitedWriter{w: &out, ctx: ctx, rate: 1, maxBytes: 1 << 20}
+	require.ErrorIs(t, w.writeLines([][]byte{[]byte("x")}), context.Canceled)
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build linux
+
+package ssh
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// namespaceFlags maps the names of CommandNamespaces to clone flags.
+var namespaceFlags = map[string]uintptr{
+	"mount": syscall.CLONE_NEWNS,
+	"pid":   syscall.CLONE_NEWPID,
+	"ipc":   syscall.CLONE_NEWIPC,
+	"uts":   syscall.CLONE_NEWUTS,
+	"net":   syscall.CLONE_NEWNET,
+}
+
+// commandCloneflags returns the clone flags creating the namespaces of names.
+func commandCloneflags(names []string) (uintptr, error) {
+	var flags uintptr
+	for _, name := range names {
+		flag, ok := namespaceFlags[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown namespace %q", name)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// setCommandNamespaces makes the process of attr start in new namespaces of
+// names.
+func setCommandNamespaces(attr *syscall.SysProcAttr, names []string) error {
+	flags, err := commandCloneflags(names)
+	if err != nil {
+		return err
+	}
+	attr.Cloneflags = flags
+	return nil
+}
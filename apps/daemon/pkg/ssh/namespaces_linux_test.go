@@ -0,0 +1,39 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build linux
+
+package ssh
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandNamespaces(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces requires root")
+	}
+
+	s := newTestServer(t)
+	s.CommandNamespaces = []string{"pid", "uts"}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	// The shell is the first process of its PID namespace, and changing the
+	// host name only affects its UTS namespace.
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	output, err := session.CombinedOutput("echo $$; hostname sandboxed && hostname")
+	require.NoError(t, err, string(output))
+	require.Equal(t, "1\nsandboxed\n", string(output))
+
+	after, err := os.Hostname()
+	require.NoError(t, err)
+	require.Equal(t, host, after)
+}
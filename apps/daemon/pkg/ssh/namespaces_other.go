@@ -0,0 +1,23 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build !linux
+
+package ssh
+
+import (
+	"errors"
+	"syscall"
+)
+
+func commandCloneflags(names []string) (uintptr, error) {
+	if len(names) == 0 {
+		return 0, nil
+	}
+	return 0, errors.New("command namespaces are only supported on Linux")
+}
+
+func setCommandNamespaces(attr *syscall.SysProcAttr, names []string) error {
+	_, err := commandCloneflags(names)
+	return err
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// outputLines counts the lines written to stdout and stderr of a session,
+// truncating the output once it exceeds max lines if max is set.
+type outputLines struct {
+	max int64
+
+	mu        sync.Mutex
+	lines     int64
+	truncated bool
+}
+
+// admit counts the lines of p and returns the part of it that may be written.
+// truncated is true for the write that exceeded the limit, which is the only
+// one returning it.
+func (o *outputLines) admit(p []byte) (admitted []byte, truncated bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.truncated {
+		return nil, false
+	}
+
+	if o.max <= 0 {
+		o.lines += int64(bytes.Count(p, []byte("\n")))
+		return p, false
+	}
+
+	for i, c := range p {
+		if o.lines == o.max {
+			o.truncated = true
+			return p[:i], true
+		}
+		if c == '\n' {
+			o.lines++
+		}
+	}
+	return p, false
+}
+
+func (o *outputLines) count() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.lines
+}
+
+// writeLimited writes p with write as far as MaxOutputLines allows. Dropped
+// output is reported as written, so commands aren't stopped by a failing copy.
+func (t *trackedSession) writeLimited(write func([]byte) (int, error), p []byte) (int, error) {
+	if t.output == nil {
+		return write(p)
+	}
+
+	admitted, truncated := t.output.admit(p)
+	if len(admitted) > 0 {
+		if n, err := write(admitted); err != nil {
+			return n, err
+		}
+	}
+	if truncated {
+		t.showTruncated()
+	}
+	return len(p), nil
+}
+
+// showTruncated tells the client that further output is discarded.
+func (t *trackedSession) showTruncated() {
+	newline := "\n"
+	if _, _, isPty := t.Session.Pty(); isPty {
+		newline = "\r\n"
+	}
+
+	if _, err := fmt.Fprintf(t.Session.Stderr(), "output truncated after %d lines%s", t.output.max, newline); err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to write output truncation notice: %v", err)
+	}
+}
+
+// limitedWriter applies MaxOutputLines to the stderr of a session.
+type limitedWriter struct {
+	io.ReadWriter
+	session *trackedSession
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	return w.session.writeLimited(w.ReadWriter.Write, p)
+}
@@ -0,0 +1,93 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputLines(t *testing.T) {
+	for name, tc := range map[string]struct {
+		maxLines int
+		command  string
+		stdout   string
+		stderr   string
+		lines    int64
+		bytes    int64
+	}{
+		"counted": {
+			command: "printf 'a\\nb\\n'; printf 'c\\n' >&2",
+			stdout:  "a\nb\n",
+			stderr:  "c\n",
+			lines:   3,
+			bytes:   6,
+		},
+		"below limit": {
+			maxLines: 3,
+			command:  "printf '1\\n2\\n3\\n'",
+			stdout:   "1\n2\n3\n",
+			lines:    3,
+			bytes:    6,
+		},
+		"truncated": {
+			maxLines: 3,
+			command:  "printf '1\\n2\\n3\\n4\\n5\\n'; sleep 0.1; echo dropped >&2",
+			stdout:   "1\n2\n3\n",
+			stderr:   "output truncated after 3 lines\n",
+			lines:    3,
+			bytes:    6,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ends := make(chan SessionEnd, 1)
+
+			s := newTestServer(t)
+			s.MaxOutputLines = tc.maxLines
+			s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			var stdout, stderr bytes.Buffer
+			session.Stdout = &stdout
+			session.Stderr = &stderr
+			// Commands keep running once their output is truncated.
+			require.NoError(t, session.Run(tc.command))
+
+			var end SessionEnd
+			select {
+			case end = <-ends:
+			case <-time.After(5 * time.Second):
+				t.Fatal("session didn't end")
+			}
+			require.Equal(t, tc.stdout, stdout.String())
+			require.Equal(t, tc.stderr, stderr.String())
+			require.Equal(t, tc.lines, end.LinesOut)
+			require.Equal(t, tc.bytes, end.BytesOut)
+		})
+	}
+}
+
+func TestOutputLinesAdmit(t *testing.T) {
+	output := &outputLines{max: 3}
+
+	admitted, truncated := output.admit([]byte("a\nb\n"))
+	require.Equal(t, "a\nb\n", string(admitted))
+	require.False(t, truncated)
+
+	// A write completing the last line is truncated right after it.
+	admitted, truncated = output.admit([]byte("c\nd\n"))
+	require.Equal(t, "c\n", string(admitted))
+	require.True(t, truncated)
+
+	admitted, truncated = output.admit([]byte("e\n"))
+	require.Empty(t, admitted)
+	require.False(t, truncated)
+	require.Equal(t, int64(3), output.count())
+}
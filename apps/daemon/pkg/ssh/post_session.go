@@ -0,0 +1,59 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultPostSessionTimeout = 30 * time.Second
+
+// runPostSessionCommands runs PostSessionCommands one after another once the
+// shell or command of a session ended, in its directory and with its
+// environment. The client may be gone by then, so output is only logged with
+// failures. Commands are skipped once the server shuts down.
+func (s *Server) runPostSessionCommands(dir string, env []string) {
+	timeout := s.PostSessionTimeout
+	if timeout <= 0 {
+		timeout = defaultPostSessionTimeout
+	}
+
+	for _, command := range s.PostSessionCommands {
+		if s.closing.Load() {
+			log.Debugf("Skipping post-session command %q, the server is shutting down", command)
+			return
+		}
+
+		s.runPostSessionCommand(command, dir, env, timeout)
+	}
+}
+
+func (s *Server) runPostSessionCommand(command, dir string, env []string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	group := &processGroup{grace: s.terminationGracePeriod()}
+	defer group.stop()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = env
+	cmd.Dir = dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Cancel = group.cancel(cmd)
+	cmd.WaitDelay = group.grace
+
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Warnf("Post-session command %q timed out after %s", command, timeout)
+	} else if err != nil {
+		log.Warnf("Post-session command %q failed: %v: %s", command, err, bytes.TrimSpace(output))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestPostSessionCommands(t *testing.T) {
+	for name, tc := range map[string]struct {
+		pty        bool
+		disconnect bool
+	}{
+		"command":    {},
+		"shell":      {pty: true},
+		"disconnect": {pty: true, disconnect: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			ends := make(chan SessionEnd, 1)
+
+			s := newTestServer(t)
+			s.PostSessionCommands = []string{
+				fmt.Sprintf(`echo "first $LANG $(pwd)" >> %s`, out),
+				"exit 1",
+				fmt.Sprintf("echo second >> %s", out),
+			}
+			s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			require.NoError(t, session.Setenv("LANG", "C.UTF-8"))
+
+			switch {
+			case tc.disconnect:
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+				stdin, err := session.StdinPipe()
+				require.NoError(t, err)
+				require.NoError(t, session.Shell())
+				_, err = stdin.Write([]byte(fmt.Sprintf("touch %s.started; read line\n", out)))
+				require.NoError(t, err)
+				require.Eventually(t, func() bool {
+					_, err := os.Stat(out + ".started")
+					return err == nil
+				}, 5*time.Second, 10*time.Millisecond)
+				require.NoError(t, client.Close())
+			case tc.pty:
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+				session.Stdin = strings.NewReader("exit 0\n")
+				require.NoError(t, session.Shell())
+				require.NoError(t, runWithTimeout(t, 5*time.Second, session.Wait))
+			default:
+				require.NoError(t, session.Run("true"))
+			}
+
+			select {
+			case <-ends:
+			case <-time.After(10 * time.Second):
+				t.Fatal("session didn't end")
+			}
+
+			// The commands ran in order, with the environment and in the
+			// directory of the session, despite the failing one.
+			data, err := os.ReadFile(out)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("first C.UTF-8 %s\nsecond\n", s.ProjectDir), string(data))
+		})
+	}
+}
+
+func TestPostSessionCommandsSkippedOnShutdown(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	s := newTestServer(t)
+	s.PostSessionCommands = []string{fmt.Sprintf("echo ran >> %s", out)}
+	s.closing.Store(true)
+	s.runPostSessionCommands(t.TempDir(), os.Environ())
+
+	_, err := os.Stat(out)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPostSessionCommandTermination(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	s := newTestServer(t)
+	s.PostSessionTimeout = 100 * time.Millisecond
+	s.TerminationGracePeriod = 500 * time.Millisecond
+	// The command ignores SIGTERM apart from noting it.
+	s.PostSessionCommands = []string{fmt.Sprintf("trap 'echo term >> %s' TERM; while :; do sleep 0.05; done", out)}
+
+	started := time.Now()
+	require.NoError(t, runWithTimeout(t, 5*time.Second, func() error {
+		s.runPostSessionCommands(t.TempDir(), os.Environ())
+		return nil
+	}))
+	elapsed := time.Since(started)
+
+	// SIGTERM came first, SIGKILL after the grace period.
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "term\n", string(data))
+	require.GreaterOrEqual(t, elapsed, s.PostSessionTimeout+s.TerminationGracePeriod)
+	require.Less(t, elapsed, 3*time.Second)
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// processGroup terminates the process group of a command started with
+// Setsid or Setpgid once its context is done: SIGTERM first, SIGKILL after
+// the grace period.
+type processGroup struct {
+	grace time.Duration
+
+	mu     sync.Mutex
+	pgid   int
+	kill   *time.Timer
+	killed chan struct{}
+}
+
+// groupPollInterval is how often stop checks whether a terminated process
+// group exited.
+const groupPollInterval = 50 * time.Millisecond
+
+// cancel returns the exec.Cmd.Cancel func of cmd.
+func (g *processGroup) cancel(cmd *exec.Cmd) func() error {
+	return func() error {
+		pgid := cmd.Process.Pid
+
+		g.mu.Lock()
+		g.pgid = pgid
+		g.killed = make(chan struct{})
+		killed := g.killed
+		g.kill = time.AfterFunc(g.grace, func() {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+			close(killed)
+		})
+		g.mu.Unlock()
+
+		err := syscall.Kill(-pgid, syscall.SIGTERM)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+}
+
+// stop cancels a pending SIGKILL once the command was waited for and the
+// process group exited, after which its ID may be reused. Processes ignoring
+// SIGTERM keep the group alive after the command exited, so they still get
+// the SIGKILL.
+func (g *processGroup) stop() {
+	g.mu.Lock()
+	pgid, kill, killed := g.pgid, g.kill, g.killed
+	g.mu.Unlock()
+
+	if kill == nil {
+		return
+	}
+
+	go func() {
+		for !errors.Is(syscall.Kill(-pgid, 0), syscall.ESRCH) {
+			select {
+			case <-killed:
+				return
+			case <-time.After(groupPollInterval):
+			}
+		}
+		kill.Stop()
+	}()
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/gliderlabs/ssh"
+	"golang.org/x/sys/unix"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errProjectDirUnavailable = errors.New("project directory is unavailable")
+
+// resolveProjectDir returns the workspace directory of the session if any, or
+// else the project directory, or the default project directory if neither can
+// be opened as a directory.
+func (s *Server) resolveProjectDir(workspaceDir string) (string, error) {
+	var lastErr error
+	for _, dir := range []string{workspaceDir, s.ProjectDir, s.DefaultProjectDir} {
+		if dir == "" {
+			continue
+		}
+
+		f, err := os.OpenFile(dir, os.O_RDONLY|unix.O_DIRECTORY, 0)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_ = f.Close()
+
+		return dir, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no directory configured")
+	}
+
+	return "", fmt.Errorf("%w: %v", errProjectDirUnavailable, lastErr)
+}
+
+// workDirEnv returns DAYTONA_WORKDIR, the directory a shell or command started
+// in, so users can tell when it isn't the workspace or project directory.
+func workDirEnv(dir string) string {
+	return "DAYTONA_WORKDIR=" + dir
+}
+
+// showWorkDirFallback tells users of interactive shells starting in dir that
+// their workspace or project directory couldn't be opened.
+func (s *Server) showWorkDirFallback(session ssh.Session, workspaceDir, dir string) {
+	expected := workspaceDir
+	if expected == "" {
+		expected = s.ProjectDir
+	}
+	if expected == "" || expected == dir {
+		return
+	}
+
+	if _, err := fmt.Fprintf(session, "%s is unavailable, starting in %s\r\n", expected, dir); err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to write working directory notice: %v", err)
+	}
+}
+
+// startInProjectDir calls start with the resolved project directory. The
+// directory can still disappear before the child process changes into it, in
+// which case start is retried once with the default project directory.
+func (s *Server) startInProjectDir(workspaceDir string, start func(dir string) error) error {
+	dir, err := s.resolveProjectDir(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	err = start(dir)
+	if !isMissingDirError(err, dir) {
+		return err
+	}
+
+	if dir != s.DefaultProjectDir && s.DefaultProjectDir != "" {
+		log.Warnf("Project directory %s disappeared before start, falling back to %s", dir, s.DefaultProjectDir)
+
+		dir = s.DefaultProjectDir
+		err = start(dir)
+		if !isMissingDirError(err, dir) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s was removed", errProjectDirUnavailable, dir)
+}
+
+// isMissingDirError reports whether err was caused by dir not existing. exec
+// reports a failed chdir the same way as a missing binary, so the directory
+// itself is checked again.
+func isMissingDirError(err error, dir string) bool {
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+
+	_, statErr := os.Stat(dir)
+	return errors.Is(statErr, fs.ErrNotExist)
+}
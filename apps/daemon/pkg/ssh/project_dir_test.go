@@ -0,0 +1,114 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestStartInProjectDirFallsBackWhenDirRemoved(t *testing.T) {
+	s := newTestServer(t)
+
+	var dirs []string
+	err := s.startInProjectDir("", func(dir string) error {
+		dirs = append(dirs, dir)
+		if dir == s.ProjectDir {
+			// Simulate the mount disappearing between resolution and exec.
+			require.NoError(t, os.RemoveAll(dir))
+		}
+
+		cmd := exec.Command("true")
+		cmd.Dir = dir
+		return cmd.Run()
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{s.ProjectDir, s.DefaultProjectDir}, dirs)
+}
+
+func TestStartInProjectDirReportsUnavailableDir(t *testing.T) {
+	s := newTestServer(t)
+	s.DefaultProjectDir = filepath.Join(t.TempDir(), "missing")
+
+	err := s.startInProjectDir("", func(dir string) error {
+		require.NoError(t, os.RemoveAll(dir))
+
+		cmd := exec.Command("true")
+		cmd.Dir = dir
+		return cmd.Run()
+	})
+	require.ErrorIs(t, err, errProjectDirUnavailable)
+}
+
+func TestNonPtyReportsUnavailableProjectDir(t *testing.T) {
+	s := newTestServer(t)
+	s.ProjectDir = filepath.Join(t.TempDir(), "missing")
+	s.DefaultProjectDir = filepath.Join(t.TempDir(), "missing")
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		return session.Run("true")
+	})
+
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitStatus())
+	require.Contains(t, stderr.String(), errProjectDirUnavailable.Error())
+}
+
+func TestWorkDirEnv(t *testing.T) {
+	for name, fallback := range map[string]bool{"project dir": false, "fallback": true} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			dir := s.ProjectDir
+			if fallback {
+				s.ProjectDir = filepath.Join(t.TempDir(), "missing")
+				dir = s.DefaultProjectDir
+			}
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			output, err := session.Output(`echo "$DAYTONA_WORKDIR"; pwd`)
+			require.NoError(t, err)
+			require.Equal(t, dir+"\n"+dir+"\n", string(output))
+
+			session, err = client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+			var shell bytes.Buffer
+			session.Stdout = &shell
+			session.Stdin = bytes.NewBufferString(`echo "workdir=$DAYTONA_WORKDIR"; exit` + "\n")
+			require.NoError(t, session.Shell())
+			require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+			require.Contains(t, shell.String(), "workdir="+dir+"\r\n")
+			notice := s.ProjectDir + " is unavailable, starting in " + dir + "\r\n"
+			if fallback {
+				require.Contains(t, shell.String(), notice)
+			} else {
+				require.NotContains(t, shell.String(), "is unavailable")
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPTYBackpressureTimeout = 10 * time.Second
+
+	ptyBufferChunkSize = 32 << 10
+)
+
+// ptyOutputBuffer decouples reading the PTY from writing to the client. Writes
+// queue up to size bytes and block only once the buffer is full, accepting as
+// much of p as fits. The output staying above highWater for timeout means the
+// client doesn't keep up, which calls onStuck once per episode.
+type ptyOutputBuffer struct {
+	w         io.Writer
+	size      int
+	highWater int
+	timeout   time.Duration
+	onStuck   func(buffered int)
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	buf        []byte
+	closed     bool
+	err        error
+	aboveSince time.Time
+	reported   bool
+	timer      *time.Timer
+	done       chan struct{}
+}
+
+func newPTYOutputBuffer(w io.Writer, size, highWater int, timeout time.Duration, onStuck func(buffered int)) *ptyOutputBuffer {
+	if highWater <= 0 || highWater >= size {
+		highWater = size / 2
+	}
+	if timeout <= 0 {
+		timeout = defaultPTYBackpressureTimeout
+	}
+
+	b := &ptyOutputBuffer{
+		w:         w,
+		size:      size,
+		highWater: highWater,
+		timeout:   timeout,
+		onStuck:   onStuck,
+		done:      make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	go b.drain()
+
+	return b
+}
+
+func (b *ptyOutputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	written := 0
+	for written < len(p) {
+		for len(b.buf) >= b.size && b.err == nil {
+			b.cond.Wait()
+		}
+		if b.err != nil {
+			return written, b.err
+		}
+
+		n := min(len(p)-written, b.size-len(b.buf))
+		b.buf = append(b.buf, p[written:written+n]...)
+		written += n
+		b.updateLocked()
+		b.cond.Broadcast()
+	}
+
+	return written, nil
+}
+
+// Close waits until the buffered output is written to the client and stops
+// the buffer.
+func (b *ptyOutputBuffer) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+
+	<-b.done
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	return b.err
+}
+
+func (b *ptyOutputBuffer) drain() {
+	defer close(b.done)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		for len(b.buf) == 0 && !b.closed && b.err == nil {
+			b.cond.Wait()
+		}
+		if len(b.buf) == 0 || b.err != nil {
+			return
+		}
+
+		chunk := append([]byte(nil), b.buf[:min(len(b.buf), ptyBufferChunkSize)]...)
+		b.mu.Unlock()
+		n, err := b.w.Write(chunk)
+		b.mu.Lock()
+
+		b.buf = b.buf[:copy(b.buf, b.buf[n:])]
+		if err != nil {
+			b.err = err
+		}
+		b.updateLocked()
+		b.cond.Broadcast()
+	}
+}
+
+// updateLocked tracks since when the buffer is above its high-water mark.
+func (b *ptyOutputBuffer) updateLocked() {
+	if len(b.buf) <= b.highWater {
+		b.aboveSince = time.Time{}
+		b.reported = false
+		return
+	}
+
+	if b.aboveSince.IsZero() {
+		b.aboveSince = time.Now()
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.timeout, b.check)
+		}
+	}
+}
+
+func (b *ptyOutputBuffer) check() {
+	b.mu.Lock()
+	b.timer = nil
+	if b.aboveSince.IsZero() || b.reported {
+		b.mu.Unlock()
+		return
+	}
+
+	// The buffer dropped below the mark in between and filled up again, so
+	// the current episode didn't last long enough yet.
+	if remaining := b.timeout - time.Since(b.aboveSince); remaining > 0 {
+		b.timer = time.AfterFunc(remaining, b.check)
+		b.mu.Unlock()
+		return
+	}
+
+	// The next episode starts once the buffer dropped below the mark.
+	b.reported = true
+	buffered := len(b.buf)
+	b.mu.Unlock()
+
+	b.onStuck(buffered)
+}
+
+// ptyOutput returns the writer the PTY output of session is copied to and a
+// function flushing it once the shell exited.
+func (s *Server) ptyOutput(session ssh.Session) (io.Writer, func()) {
+	if s.PTYOutputBuffer <= 0 {
+		return session, func() {}
+	}
+
+	buffer := newPTYOutputBuffer(session, s.PTYOutputBuffer, s.PTYOutputHighWater, s.PTYBackpressureTimeout, func(buffered int) {
+		if !s.PTYDisconnectStuck {
+			log.Warnf("Client of session %s doesn't keep up with the shell output, %d bytes are buffered", session.Context().SessionID(), buffered)
+			return
+		}
+
+		log.Warnf("Disconnecting client of session %s that doesn't keep up with the shell output, %d bytes are buffered", session.Context().SessionID(), buffered)
+		disconnectStuckClient(session)
+	})
+
+	return buffer, func() {
+		_ = buffer.Close()
+	}
+}
+
+func disconnectStuckClient(session ssh.Session) {
+	setCloseReason(session, CloseReasonBackpressure)
+
+	if conn, ok := session.Context().Value(contextKeyConnState).(*trackedConn); ok {
+		conn.setReason(CloseReasonBackpressure)
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// slowConsumer is a client that doesn't read output until released.
+type slowConsumer struct {
+	release chan struct{}
+
+	mu  sync.Mutex
+	out bytes.Buffer
+}
+
+func (c *slowConsumer) Write(p []byte) (int, error) {
+	<-c.release
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.Write(p)
+}
+
+func TestPTYOutputBuffer(t *testing.T) {
+	consumer := &slowConsumer{release: make(chan struct{})}
+	stuck := make(chan int, 1)
+	buffer := newPTYOutputBuffer(consumer, 64, 16, 50*time.Millisecond, func(buffered int) {
+		stuck <- buffered
+	})
+
+	// Writes beyond the size of the buffer block until the client catches up.
+	input := bytes.Repeat([]byte("0123456789"), 20)
+	written := make(chan error, 1)
+	go func() {
+		_, err := buffer.Write(input)
+		written <- err
+	}()
+
+	select {
+	case buffered := <-stuck:
+		require.Greater(t, buffered, 16)
+	case <-time.After(5 * time.Second):
+		t.Fatal("backpressure was not detected")
+	}
+
+	select {
+	case <-written:
+		t.Fatal("write didn't block on a full buffer")
+	default:
+	}
+
+	close(consumer.release)
+	require.NoError(t, runWithTimeout(t, 5*time.Second, func() error { return <-written }))
+	require.NoError(t, buffer.Close())
+	require.Equal(t, string(input), consumer.out.String())
+
+	// Output clients keep up with is never reported.
+	select {
+	case <-stuck:
+		t.Fatal("backpressure was reported twice")
+	default:
+	}
+}
+
+func TestPTYDisconnectStuck(t *testing.T) {
+	ends := make(chan SessionEnd, 1)
+	s := newTestServer(t)
+	s.PTYOutputBuffer = 64 << 10
+	s.PTYBackpressureTimeout = 200 * time.Millisecond
+	s.PTYDisconnectStuck = true
+	s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+	// The output is never read, so the channel window fills up.
+	_, err = session.StdoutPipe()
+	require.NoError(t, err)
+	session.Stdin = strings.NewReader("exec yes\n")
+	require.NoError(t, session.Shell())
+
+	select {
+	case end := <-ends:
+		require.Equal(t, CloseReasonBackpressure, end.Reason)
+	case <-time.After(10 * time.Second):
+		t.Fatal("stuck client was not disconnected")
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const readOnlyNotice = "This workspace is read-only. File changes are refused over SFTP, please don't modify files in the shell either."
+
+// readOnlyEnv returns DAYTONA_READ_ONLY for sessions of read-only workspaces,
+// letting tools and prompts of the shell pick up the mode.
+func (s *Server) readOnlyEnv() []string {
+	if !s.ReadOnly {
+		return nil
+	}
+
+	return []string{"DAYTONA_READ_ONLY=1"}
+}
+
+// showReadOnlyNotice tells users of interactive shells that the workspace is
+// read-only.
+func (s *Server) showReadOnlyNotice(session ssh.Session) {
+	if !s.ReadOnly {
+		return
+	}
+
+	if _, err := fmt.Fprintf(session, "%s\r\n", readOnlyNotice); err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to write read-only notice: %v", err)
+	}
+}
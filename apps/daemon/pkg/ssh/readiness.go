@@ -0,0 +1,87 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const readinessProgressInterval = time.Second
+
+// readinessContext is the ssh.Context of a session bounded by
+// ReadinessTimeout.
+type readinessContext struct {
+	ssh.Context
+	ctx context.Context
+}
+
+func (c *readinessContext) Deadline() (time.Time, bool) { return c.ctx.Deadline() }
+func (c *readinessContext) Done() <-chan struct{}       { return c.ctx.Done() }
+func (c *readinessContext) Err() error                  { return c.ctx.Err() }
+
+// awaitReadiness runs ReadinessCheck before a shell or command starts,
+// printing ReadinessMessage followed by a dot per second to stderr while it
+// runs. It rejects the session and returns false if the check fails.
+func (s *Server) awaitReadiness(session ssh.Session) bool {
+	if s.ReadinessCheck == nil {
+		return true
+	}
+
+	ctx := context.Context(session.Context())
+	cancel := context.CancelFunc(func() {})
+	if s.ReadinessTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.ReadinessTimeout)
+	}
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.ReadinessCheck(&readinessContext{Context: session.Context(), ctx: ctx})
+	}()
+
+	var progress <-chan time.Time
+	if s.ReadinessMessage != "" {
+		_, _ = fmt.Fprint(session.Stderr(), s.ReadinessMessage)
+		ticker := time.NewTicker(readinessProgressInterval)
+		defer ticker.Stop()
+		progress = ticker.C
+	}
+
+	var err error
+	for waiting := true; waiting; {
+		select {
+		case err = <-result:
+			waiting = false
+		case <-ctx.Done():
+			err = ctx.Err()
+			waiting = false
+		case <-progress:
+			_, _ = fmt.Fprint(session.Stderr(), ".")
+		}
+	}
+	if s.ReadinessMessage != "" {
+		_, _ = fmt.Fprintln(session.Stderr())
+	}
+
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("not ready after %s", s.ReadinessTimeout)
+	}
+	log.Warnf("Rejecting session %s of user %s, workspace is not ready: %v", session.Context().SessionID(), session.User(), err)
+	_, _ = fmt.Fprintf(session.Stderr(), "Workspace is not ready: %v\n", err)
+	setCloseReason(session, CloseReasonNotReady)
+	_ = session.Exit(1)
+
+	return false
+}
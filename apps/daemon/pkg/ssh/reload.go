@@ -0,0 +1,88 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reload re-reads BannerFile and checks AuthorizedKeysFile, logging what was
+// reloaded. Established connections are not affected. On errors the previous
+// banner is kept.
+func (s *Server) Reload() error {
+	var errs []error
+
+	if s.BannerFile != "" {
+		if err := s.loadBannerFile(); err != nil {
+			errs = append(errs, err)
+		} else {
+			log.Infof("Reloaded banner from %s", s.BannerFile)
+		}
+	}
+
+	// Authorized keys are read on every authentication attempt, so loading
+	// them only reports problems before a client runs into them.
+	if s.Authenticator == nil && s.AuthorizedKeysFile != "" {
+		keys, err := loadAuthorizedKeys(s.AuthorizedKeysFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load authorized keys: %w", err))
+		} else {
+			log.Infof("Reloaded %d authorized keys from %s", len(keys), s.AuthorizedKeysFile)
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		log.Errorf("Failed to reload ssh server: %v", err)
+	}
+
+	return err
+}
+
+func (s *Server) loadBannerFile() error {
+	data, err := os.ReadFile(s.BannerFile)
+	if err != nil {
+		return fmt.Errorf("failed to load banner: %w", err)
+	}
+
+	banner := string(data)
+	s.banner.Store(&banner)
+	return nil
+}
+
+// bannerFileHandler serves the banner last loaded from BannerFile.
+func (s *Server) bannerFileHandler(ctx ssh.Context) string {
+	if banner := s.banner.Load(); banner != nil {
+		return *banner
+	}
+
+	return s.Banner
+}
+
+// reloadOnSIGHUP calls Reload whenever the process receives SIGHUP, until the
+// returned func is called.
+func (s *Server) reloadOnSIGHUP() func() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		for range sighup {
+			log.Info("Received SIGHUP, reloading ssh server")
+			_ = s.Reload()
+		}
+	}()
+
+	return func() {
+		signal.Stop(sighup)
+		close(sighup)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func dialBanner(t *testing.T, addr string) string {
+	t.Helper()
+
+	// The banner is sent before authentication, which fails without keys.
+	var banner string
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "daytona",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		BannerCallback: func(message string) error {
+			banner = message
+			return nil
+		},
+		Timeout: 5 * time.Second,
+	})
+	if err == nil {
+		client.Close()
+	}
+
+	return banner
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	hook := logtest.NewGlobal()
+	dir := t.TempDir()
+
+	s := newTestServer(t)
+	s.BannerFile = filepath.Join(dir, "banner")
+	s.AuthorizedKeysFile = filepath.Join(dir, "authorized_keys")
+	require.NoError(t, os.WriteFile(s.BannerFile, []byte("before\n"), 0o644))
+	require.NoError(t, os.WriteFile(s.AuthorizedKeysFile, nil, 0o600))
+
+	addr := startTestServer(t, s)
+	require.Equal(t, "before\n", dialBanner(t, addr))
+
+	stop := s.reloadOnSIGHUP()
+	defer stop()
+
+	require.NoError(t, os.WriteFile(s.BannerFile, []byte("after\n"), 0o644))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	require.Eventually(t, func() bool {
+		return dialBanner(t, addr) == "after\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	require.Contains(t, messages, "Reloaded banner from "+s.BannerFile)
+	require.Contains(t, messages, "Reloaded 0 authorized keys from "+s.AuthorizedKeysFile)
+
+	// A failing reload keeps the previous banner.
+	require.NoError(t, os.Remove(s.BannerFile))
+	require.Error(t, s.Reload())
+	require.Equal(t, "after\n", dialBanner(t, addr))
+	require.True(t, strings.HasPrefix(hook.LastEntry().Message, "Failed to reload ssh server"))
+}
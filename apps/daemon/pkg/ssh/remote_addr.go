@@ -0,0 +1,63 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// remoteIP returns the IP address of the client of ctx, or nil if the
+// connection isn't an IP connection.
+func remoteIP(ctx ssh.Context) net.IP {
+	return addrIP(ctx.RemoteAddr())
+}
+
+// addrIP extracts the IP address of addr. Addresses aren't split on the last
+// colon by hand, which would break for IPv6 addresses like [::1]:2222.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case nil:
+		return nil
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+
+	// Drop the zone of link-local addresses like fe80::1%eth0.
+	host, _, _ = strings.Cut(host, "%")
+
+	return net.ParseIP(host)
+}
+
+// connectionEnv returns the SSH_CONNECTION and SSH_CLIENT variables OpenSSH
+// sets, "client-ip client-port server-ip server-port" and "client-ip
+// client-port server-port". They are left out for connections that aren't
+// TCP connections.
+func connectionEnv(ctx ssh.Context) []string {
+	client, ok := ctx.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	server, ok := ctx.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf("SSH_CONNECTION=%s %d %s %d", client.IP, client.Port, server.IP, server.Port),
+		fmt.Sprintf("SSH_CLIENT=%s %d %d", client.IP, client.Port, server.Port),
+	}
+}
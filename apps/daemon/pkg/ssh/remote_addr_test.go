@@ -0,0 +1,99 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type stringAddr string
+
+func (a stringAddr) Network() string { return "tcp" }
+func (a stringAddr) String() string  { return string(a) }
+
+func TestAddrIP(t *testing.T) {
+	for _, tc := range []struct {
+		addr net.Addr
+		want string
+	}{
+		{addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 22}, want: "2001:db8::1"},
+		{addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}, want: "192.0.2.1"},
+		{addr: stringAddr("[2001:db8::1]:2222"), want: "2001:db8::1"},
+		{addr: stringAddr("[fe80::1%eth0]:2222"), want: "fe80::1"},
+		{addr: stringAddr("192.0.2.1:2222"), want: "192.0.2.1"},
+		{addr: stringAddr("2001:db8::1"), want: "2001:db8::1"},
+	} {
+		t.Run(tc.addr.String(), func(t *testing.T) {
+			require.Equal(t, net.ParseIP(tc.want), addrIP(tc.addr))
+		})
+	}
+
+	require.Nil(t, addrIP(stringAddr("/run/daytona.sock")))
+	require.Nil(t, addrIP(nil))
+}
+
+func TestRemoteIPOverIPv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+
+	ends := make(chan SessionEnd, 1)
+	s := newTestServer(t)
+	s.OnSessionEnd = func(end SessionEnd) {
+		ends <- end
+	}
+
+	sshServer := s.newSSHServer()
+	go func() {
+		_ = sshServer.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = sshServer.Close()
+	})
+
+	client := dialTestServer(t, l.Addr().String())
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, session.Run("true"))
+
+	select {
+	case end := <-ends:
+		require.Equal(t, net.IPv6loopback, end.RemoteIP)
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't end")
+	}
+}
+
+func TestConnectionEnv(t *testing.T) {
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+	clientAddr := client.LocalAddr().(*net.TCPAddr)
+	serverAddr := client.RemoteAddr().(*net.TCPAddr)
+
+	for name, pty := range map[string]bool{"command": false, "pty": true} {
+		t.Run(name, func(t *testing.T) {
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			if pty {
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+			}
+			output, err := session.Output(`echo "$SSH_CONNECTION|$SSH_CLIENT"`)
+			require.NoError(t, err)
+
+			require.Equal(t, fmt.Sprintf("127.0.0.1 %d 127.0.0.1 %d|127.0.0.1 %d %d",
+				clientAddr.Port, serverAddr.Port, clientAddr.Port, serverAddr.Port),
+				strings.TrimSpace(string(output)))
+		})
+	}
+}
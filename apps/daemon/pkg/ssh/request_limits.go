@@ -0,0 +1,67 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Limits of session channel requests. golang.org/x/crypto/ssh already caps
+// packets at 256 KiB, but gliderlabs/ssh keeps every env request of a session
+// and silently ignores malformed payloads. Commands are bounded by the packet
+// size and optionally MaxCommandLength.
+const (
+	maxEnvNameLength       = 256
+	maxEnvValueLength      = 32 << 10
+	maxEnvVars             = 256
+	maxSubsystemNameLength = 64
+	maxTermLength          = 256
+)
+
+// checkRequest validates the payload of a session channel request before it
+// is passed on. envVars is the number of env requests the channel accepted
+// before.
+func checkRequest(req *gossh.Request, envVars int) error {
+	switch req.Type {
+	case "env":
+		var msg struct{ Name, Value string }
+		if err := gossh.Unmarshal(req.Payload, &msg); err != nil {
+			return fmt.Errorf("malformed payload: %w", err)
+		}
+		if envVars >= maxEnvVars {
+			return fmt.Errorf("more than %d variables", maxEnvVars)
+		}
+		if msg.Name == "" || len(msg.Name) > maxEnvNameLength {
+			return fmt.Errorf("variable name of %d bytes, at most %d are allowed", len(msg.Name), maxEnvNameLength)
+		}
+		if len(msg.Value) > maxEnvValueLength {
+			return fmt.Errorf("value of %d bytes for %.32s, at most %d are allowed", len(msg.Value), msg.Name, maxEnvValueLength)
+		}
+	case "exec":
+		var msg struct{ Command string }
+		if err := gossh.Unmarshal(req.Payload, &msg); err != nil {
+			return fmt.Errorf("malformed payload: %w", err)
+		}
+	case "subsystem":
+		var msg struct{ Name string }
+		if err := gossh.Unmarshal(req.Payload, &msg); err != nil {
+			return fmt.Errorf("malformed payload: %w", err)
+		}
+		if len(msg.Name) > maxSubsystemNameLength {
+			return fmt.Errorf("subsystem name of %d bytes, at most %d are allowed", len(msg.Name), maxSubsystemNameLength)
+		}
+	case "pty-req":
+		var msg ptyRequestMsg
+		if err := gossh.Unmarshal(req.Payload, &msg); err != nil {
+			return fmt.Errorf("malformed payload: %w", err)
+		}
+		if len(msg.Term) > maxTermLength {
+			return fmt.Errorf("terminal name of %d bytes, at most %d are allowed", len(msg.Term), maxTermLength)
+		}
+	}
+
+	return nil
+}
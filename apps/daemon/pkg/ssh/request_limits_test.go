@@ -0,0 +1,91 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestCheckRequest(t *testing.T) {
+	env := func(name, value string) []byte {
+		return gossh.Marshal(struct{ Name, Value string }{name, value})
+	}
+	value := func(v string) []byte {
+		return gossh.Marshal(struct{ Value string }{v})
+	}
+
+	for name, tc := range map[string]struct {
+		typ     string
+		payload []byte
+		envVars int
+		valid   bool
+	}{
+		"env":                   {typ: "env", payload: env("LANG", "C.UTF-8"), valid: true},
+		"env name too long":     {typ: "env", payload: env(strings.Repeat("A", maxEnvNameLength+1), "")},
+		"env name empty":        {typ: "env", payload: env("", "value")},
+		"env value too long":    {typ: "env", payload: env("LANG", strings.Repeat("a", maxEnvValueLength+1))},
+		"too many env vars":     {typ: "env", payload: env("LANG", "C"), envVars: maxEnvVars},
+		"env truncated":         {typ: "env", payload: env("LANG", "C.UTF-8")[:9]},
+		"env length overflow":   {typ: "env", payload: []byte{0xff, 0xff, 0xff, 0xff, 'A'}},
+		"exec":                  {typ: "exec", payload: value("echo hello"), valid: true},
+		"exec malformed":        {typ: "exec", payload: []byte{0, 0, 1}},
+		"subsystem":             {typ: "subsystem", payload: value("sftp"), valid: true},
+		"subsystem too long":    {typ: "subsystem", payload: value(strings.Repeat("s", maxSubsystemNameLength+1))},
+		"pty-req":               {typ: "pty-req", payload: gossh.Marshal(ptyRequestMsg{Term: "xterm", Columns: 80, Rows: 24}), valid: true},
+		"pty-req term too long": {typ: "pty-req", payload: gossh.Marshal(ptyRequestMsg{Term: strings.Repeat("x", maxTermLength+1)})},
+		"pty-req malformed":     {typ: "pty-req", payload: value("xterm")},
+		"unchecked request":     {typ: "window-change", payload: []byte{1, 2, 3}, valid: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := checkRequest(&gossh.Request{Type: tc.typ, Payload: tc.payload}, tc.envVars)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckRequestRandomPayloads(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		payload := make([]byte, random.Intn(64))
+		random.Read(payload)
+
+		for _, typ := range []string{"env", "exec", "subsystem", "pty-req"} {
+			require.NotPanics(t, func() {
+				_ = checkRequest(&gossh.Request{Type: typ, Payload: payload}, 0)
+			})
+		}
+	}
+}
+
+func TestOversizedRequestsRejected(t *testing.T) {
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.Setenv("LANG", "C"))
+	require.Error(t, session.Setenv("LANG", strings.Repeat("a", maxEnvValueLength+1)))
+	require.Error(t, session.Setenv(strings.Repeat("A", maxEnvNameLength+1), "value"))
+
+	// The session is still usable after rejected requests.
+	output, err := session.Output("echo $LANG")
+	require.NoError(t, err)
+	require.Equal(t, "C\n", string(output))
+
+	session, err = client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.Error(t, session.RequestSubsystem(strings.Repeat("s", maxSubsystemNameLength+1)))
+}
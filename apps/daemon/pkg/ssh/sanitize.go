@@ -0,0 +1,191 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+)
+
+// SanitizeMode selects how terminal control sequences are treated in
+// recorded output, i.e. transcripts and session logs.
+type SanitizeMode string
+
+const (
+	// SanitizeRaw records output as sent to the client.
+	SanitizeRaw SanitizeMode = ""
+	// SanitizeStrip removes escape sequences and control characters apart
+	// from newlines and tabs, leaving the text as a terminal would show it
+	// on a fresh line.
+	SanitizeStrip SanitizeMode = "strip"
+	// SanitizeEscape replaces control characters with visible escapes like
+	// \x1b, so nothing reaches the terminal of whoever reads the recording.
+	SanitizeEscape SanitizeMode = "escape"
+)
+
+func (m SanitizeMode) valid() bool {
+	switch m {
+	case SanitizeRaw, SanitizeStrip, SanitizeEscape:
+		return true
+	default:
+		return false
+	}
+}
+
+type sanitizeState int
+
+const (
+	sanitizeText sanitizeState = iota
+	// sanitizeC2 follows the first byte of a 2 byte UTF-8 sequence, which
+	// encodes a C1 control character if the second byte is below 0xa0.
+	sanitizeC2
+	sanitizeEsc
+	sanitizeCSI
+	// sanitizeString skips the payload of OSC, DCS, SOS, PM and APC strings
+	// up to their terminator, or CAN and SUB aborting them.
+	sanitizeString
+	sanitizeStringEsc
+	sanitizeStringC2
+)
+
+const (
+	asciiBEL = 0x07
+	asciiCAN = 0x18
+	asciiSUB = 0x1a
+	asciiESC = 0x1b
+	asciiDEL = 0x7f
+
+	c1CSI = 0x9b
+	c1ST  = 0x9c
+)
+
+// sanitizer removes or escapes terminal control sequences in a stream. It
+// keeps state between writes, so sequences split across them are caught.
+type sanitizer struct {
+	mode  SanitizeMode
+	state sanitizeState
+}
+
+func newSanitizer(mode SanitizeMode) *sanitizer {
+	if mode == SanitizeRaw {
+		return nil
+	}
+	return &sanitizer{mode: mode}
+}
+
+// sanitize returns the sanitized p. A nil sanitizer returns p unchanged.
+func (z *sanitizer) sanitize(p []byte) []byte {
+	if z == nil {
+		return p
+	}
+	if z.mode == SanitizeEscape {
+		return z.escape(p)
+	}
+	return z.strip(p)
+}
+
+func (z *sanitizer) escape(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		if z.state == sanitizeC2 {
+			z.state = sanitizeText
+			if b >= 0x80 && b < 0xa0 {
+				out = fmt.Appendf(out, `\u%04x`, b)
+				continue
+			}
+			out = append(out, 0xc2)
+		}
+
+		switch {
+		case b == 0xc2:
+			z.state = sanitizeC2
+		case b == '\n' || b == '\t':
+			out = append(out, b)
+		case b < 0x20 || b == asciiDEL:
+			out = fmt.Appendf(out, `\x%02x`, b)
+		case b == '\\':
+			out = append(out, `\\`...)
+		default:
+			out = append(out, b)
+		}
+	}
+
+	return out
+}
+
+func (z *sanitizer) strip(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch z.state {
+		case sanitizeText:
+			switch {
+			case b == asciiESC:
+				z.state = sanitizeEsc
+			case b == 0xc2:
+				z.state = sanitizeC2
+			case b == '\n' || b == '\t':
+				out = append(out, b)
+			case b < 0x20 || b == asciiDEL:
+			default:
+				out = append(out, b)
+			}
+		case sanitizeC2:
+			z.state = sanitizeText
+			switch {
+			case b == c1CSI:
+				z.state = sanitizeCSI
+			case b == 0x90 || b == 0x98 || b == 0x9d || b == 0x9e || b == 0x9f:
+				z.state = sanitizeString
+			case b >= 0x80 && b < 0xa0:
+			default:
+				out = append(out, 0xc2)
+				// The byte may start a sequence itself.
+				out = append(out, z.strip([]byte{b})...)
+			}
+		case sanitizeEsc:
+			switch b {
+			case asciiESC:
+			case '[':
+				z.state = sanitizeCSI
+			case ']', 'P', 'X', '^', '_':
+				z.state = sanitizeString
+			default:
+				// Intermediate bytes continue the sequence up to its
+				// final byte, e.g. ESC ( B.
+				if b < 0x20 || b > 0x2f {
+					z.state = sanitizeText
+				}
+			}
+		case sanitizeCSI:
+			// Parameter and intermediate bytes continue up to the final byte.
+			if b >= 0x40 && b <= 0x7e || b < 0x20 && b != asciiESC {
+				z.state = sanitizeText
+			} else if b == asciiESC {
+				z.state = sanitizeEsc
+			}
+		case sanitizeString:
+			switch b {
+			case asciiBEL, asciiCAN, asciiSUB:
+				z.state = sanitizeText
+			case asciiESC:
+				z.state = sanitizeStringEsc
+			case 0xc2:
+				z.state = sanitizeStringC2
+			}
+		case sanitizeStringEsc:
+			if b == '\\' {
+				z.state = sanitizeText
+			} else if b != asciiESC {
+				z.state = sanitizeString
+			}
+		case sanitizeStringC2:
+			if b == c1ST {
+				z.state = sanitizeText
+			} else if b != 0xc2 {
+				z.state = sanitizeString
+			}
+		}
+	}
+
+	return out
+}
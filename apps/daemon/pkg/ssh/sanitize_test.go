@@ -0,0 +1,127 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizer(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input    []string
+		stripped string
+		escaped  string
+	}{
+		"plain text": {
+			input:    []string{"hello\tworld\n", "päth\n"},
+			stripped: "hello\tworld\npäth\n",
+			escaped:  "hello\tworld\npäth\n",
+		},
+		"colors": {
+			input:    []string{"\x1b[1;31mred\x1b[0m\r\n"},
+			stripped: "red\n",
+			escaped:  `\x1b[1;31mred\x1b[0m\x0d` + "\n",
+		},
+		"window title": {
+			input:    []string{"\x1b]0;pwned\x07ok"},
+			stripped: "ok",
+			escaped:  `\x1b]0;pwned\x07ok`,
+		},
+		"clipboard write terminated by ST": {
+			input:    []string{"\x1b]52;c;ZWNobyBoaQ==\x1b\\ok"},
+			stripped: "ok",
+			escaped:  `\x1b]52;c;ZWNobyBoaQ==\x1b\\ok`,
+		},
+		"device control string": {
+			input:    []string{"\x1bP$q\"p\x1b\\ok"},
+			stripped: "ok",
+			escaped:  `\x1bP$q"p\x1b\\ok`,
+		},
+		"charset selection": {
+			input:    []string{"\x1b(Bok"},
+			stripped: "ok",
+			escaped:  `\x1b(Bok`,
+		},
+		"C1 control sequence introducer": {
+			input:    []string{"\u009b2Jok\u009d0;pwned\u009cdone"},
+			stripped: "okdone",
+			escaped:  `\u009b2Jok\u009d0;pwned\u009cdone`,
+		},
+		"split across writes": {
+			input:    []string{"a\x1b", "[2", "Jb\xc2", "\x9b1mc\x1b]0;", "pwned\x07d"},
+			stripped: "abcd",
+			escaped:  `a\x1b[2Jb\u009b1mc\x1b]0;pwned\x07d`,
+		},
+		"aborted string": {
+			input:    []string{"\x1b]0;pwned\x18ok"},
+			stripped: "ok",
+			escaped:  `\x1b]0;pwned\x18ok`,
+		},
+		"backspace and delete": {
+			input:    []string{"abc\b\b\x7fd\\"},
+			stripped: "abcd\\",
+			escaped:  `abc\x08\x08\x7fd\\`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for mode, expected := range map[SanitizeMode]string{
+				SanitizeStrip:  tc.stripped,
+				SanitizeEscape: tc.escaped,
+			} {
+				z := newSanitizer(mode)
+				var out []byte
+				for _, p := range tc.input {
+					out = append(out, z.sanitize([]byte(p))...)
+				}
+				require.Equal(t, expected, string(out), mode)
+			}
+
+			// Raw recordings keep the input unchanged.
+			raw := newSanitizer(SanitizeRaw)
+			for _, p := range tc.input {
+				require.Equal(t, p, string(raw.sanitize([]byte(p))))
+			}
+		})
+	}
+}
+
+func TestSanitizedRecordings(t *testing.T) {
+	s := newTestServer(t)
+	s.TranscriptSize = 1 << 10
+	s.SessionLogDir = t.TempDir()
+	s.SanitizeRecordings = SanitizeStrip
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	// The client still receives the output unchanged.
+	output, err := session.Output(`printf '\033]0;pwned\007\033[31mred\033[0m\n'`)
+	require.NoError(t, err)
+	require.Equal(t, "\x1b]0;pwned\x07\x1b[31mred\x1b[0m\n", string(output))
+
+	// The first session of the server has ID 1.
+	r, err := s.SessionTranscript("1")
+	require.NoError(t, err)
+	defer r.Close()
+	transcript, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "red\n", string(transcript))
+
+	require.Eventually(t, func() bool {
+		for _, content := range readSessionLogs(t, s.SessionLogDir) {
+			return content != ""
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, content := range readSessionLogs(t, s.SessionLogDir) {
+		require.Contains(t, content, ` out "red\n"`)
+		require.NotContains(t, content, "pwned")
+	}
+}
@@ -4,174 +4,966 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
 
 	"github.com/daytonaio/daemon/pkg/common"
 	"github.com/daytonaio/daemon/pkg/ssh/config"
 	"github.com/gliderlabs/ssh"
 	"github.com/pkg/sftp"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sys/unix"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const defaultShellDeniedMessage = "Interactive shells are disabled for this workspace."
+
+const defaultTerminationGracePeriod = 5 * time.Second
+
 type Server struct {
 	ProjectDir        string
 	DefaultProjectDir string
+	// WorkspaceResolver maps workspace names to directories so a single agent
+	// can serve multiple workspaces. The name is taken from the WorkspaceEnv
+	// variable sent by the client, or else the user name, so
+	// `ssh workspace-name@agent` opens workspace-name. Sessions of unknown
+	// workspaces start in ProjectDir. Clients whose Identity has a
+	// WorkspaceID are confined to that workspace.
+	WorkspaceResolver func(name string) (dir string, err error)
+	// WorkspaceEnv is the variable clients select a workspace with,
+	// DAYTONA_WORKSPACE if empty.
+	WorkspaceEnv string
+	// AcceptEnv lists the variables clients may set for their sessions with
+	// env requests, as patterns with * and ? wildcards like OpenSSH's
+	// AcceptEnv. Nil accepts the locale variables LANG and LC_*, TZ and
+	// GIT_PROTOCOL, which git uses to negotiate protocol version 2. Client
+	// variables override the ones of EnvFile.
+	AcceptEnv []string
+	// StripEnv lists variables of the daemon's own environment that sessions
+	// don't inherit, e.g. credentials of the agent, as patterns like
+	// AcceptEnv. It applies to shells, commands, the welcome command and
+	// post-session commands, not to variables of EnvFile or the client.
+	StripEnv []string
+
+	// ReadOnly serves snapshot or inspection workspaces. SFTP refuses all
+	// changes, while shells are only told about the mode with a notice and
+	// DAYTONA_READ_ONLY=1, since their writes can't be prevented.
+	ReadOnly bool
+	// IsolateTmp gives every shell and command session a temporary directory
+	// of its own in TMPDIR, which is removed with everything left in it once
+	// the session ends.
+	IsolateTmp bool
+	// AllowStdinFile lets clients of non-PTY commands set DAYTONA_STDIN_FILE
+	// to a file in the directory the command runs in, which is passed to the
+	// command as stdin instead of the input of the client, e.g. for batch
+	// jobs orchestrated by the agent.
+	AllowStdinFile bool
+	// UserShells maps the Identity IDs of authenticated clients, or the user
+	// names clients log in with, to the shell their sessions run in, e.g.
+	// rbash for restricted users. Commands are passed to it with "-c". Other
+	// users get the shell found in /etc/shells and /bin/sh for commands.
+	UserShells map[string]string
+	// DisablePty rejects interactive PTY shells. Commands are still executed.
+	DisablePty bool
+	// ShellDeniedMessage is shown to clients whose interactive shell is denied.
+	ShellDeniedMessage string
+	// FallbackToShell starts an interactive shell with a notice instead of
+	// exiting with status 127 if the command of a session with a PTY wasn't
+	// found, e.g. for mistyped `ssh -t` commands. Forced commands and
+	// sessions denied a shell by DisablePty or SessionAuthorizer still exit.
+	FallbackToShell bool
+	// SessionAuthorizer decides on every session channel of an authenticated
+	// connection, e.g. to allow SFTP but no shell for some users. subsystem
+	// and command are empty for shells. An error rejects the session with its
+	// message while the connection stays open.
+	SessionAuthorizer func(ctx ssh.Context, subsystem, command string) error
+	// UnlockFunc unlocks an encrypted workspace with the passphrase clients
+	// are prompted for with keyboard-interactive authentication, which the
+	// passphrase authenticates them with. Wrong passphrases are asked for
+	// again up to UnlockAttempts times, 3 by default, before the attempt is
+	// rejected. Sessions of connections authenticated otherwise are rejected
+	// while it is set, since the workspace isn't unlocked for them.
+	UnlockFunc     func(ctx ssh.Context, passphrase string) error
+	UnlockAttempts int
+	// AllowGlobalReverseForward lets remote forwards listen on all or
+	// external interfaces of the host. By default they may only bind to
+	// loopback addresses, so clients can't expose services to the network.
+	AllowGlobalReverseForward bool
+	// RejectedRequests lists global request types that are declined, even
+	// those the server supports, e.g. "tcpip-forward". Unknown types are
+	// always declined and logged at debug level.
+	RejectedRequests []string
+	// IdleTimeout closes connections and SFTP sessions without any activity
+	// for the given duration. Zero disables the timeout. Open port forwards
+	// don't count as activity, clients only forwarding ports with `ssh -N`
+	// keep idle connections open with keepalives, e.g. ServerAliveInterval.
+	IdleTimeout time.Duration
+	// AuthorizedKeysFile enables public key authentication against an
+	// OpenSSH authorized_keys file. Clients aren't authenticated if empty.
+	AuthorizedKeysFile string
+	// Authenticator validates client credentials and takes precedence over
+	// AuthorizedKeysFile.
+	Authenticator Authenticator
+	// MaxAuthTries disconnects clients failing authentication as many times
+	// within one connection, like the sshd option. Every rejected key,
+	// password or passphrase prompt counts. Zero uses the default of 6,
+	// negative values allow unlimited attempts.
+	MaxAuthTries int
+	// SFTPMaxFileSize limits the size of a single file written over SFTP.
+	// Zero means unlimited.
+	SFTPMaxFileSize int64
+	// SFTPBufferSize enables buffering of SFTP packets with buffers of the
+	// given size. Zero disables buffering.
+	SFTPBufferSize int
+	// SFTPMaxInFlight bounds the reads and writes of a single SFTP session
+	// running at the same time, so clients pipelining many requests can't
+	// overwhelm slow disks. Further requests wait for their turn. Zero keeps
+	// the default of pkg/sftp, which runs up to 8 in parallel.
+	SFTPMaxInFlight int
+	// SFTPAllocator makes SFTP sessions reuse packet buffers instead of
+	// allocating new ones for every request.
+	SFTPAllocator bool
+	// SFTPRoot confines SFTP sessions to the given directory, presented to the
+	// client as "/". Empty serves the whole filesystem.
+	SFTPRoot string
+	// SFTPUserRoot resolves the subdirectory of SFTPRoot that SFTP sessions
+	// of the authenticated user are confined to.
+	SFTPUserRoot func(ctx ssh.Context) (string, error)
+	// SFTPUploadOnly turns SFTP into a drop box: files can be written and
+	// directories created, but nothing can be downloaded, listed, removed or
+	// renamed. Clients get a permission error for the rest.
+	SFTPUploadOnly bool
+	// SFTPDownloadOnly only serves files over SFTP, refusing all changes like
+	// ReadOnly does, but without affecting shells.
+	SFTPDownloadOnly bool
+	// SFTPDeniedPaths refuses and logs SFTP requests for paths matching one
+	// of the patterns, e.g. "**/.ssh/**", "**/.env" or "**/id_*". "**"
+	// matches any number of path elements. Patterns are matched against local
+	// paths rather than the ones clients see inside SFTPRoot, both as
+	// requested and with symlinks resolved.
+	SFTPDeniedPaths []string
+	// SFTPHandlers serves SFTP sessions from a filesystem of its own instead
+	// of the local one, e.g. a virtual view of an overlay or remote-backed
+	// workspace. SFTPRoot, SFTPUserRoot, SFTPMaxFileSize, SFTPMaxInFlight,
+	// SFTPUploadOnly, SFTPDownloadOnly, SFTPDeniedPaths and ReadOnly don't
+	// apply to it.
+	SFTPHandlers *sftp.Handlers
+	// TranslateCRLF translates CRLF line endings in the stdin of non-PTY
+	// commands to LF for clients sending Windows line endings. By default
+	// stdin is passed through unchanged, so binary input is safe.
+	TranslateCRLF bool
+	// CommandOutputBuffer buffers the stdout of non-PTY commands in a buffer
+	// of the given size, so commands doing many small writes don't cost a
+	// channel write each. Buffered output is sent at the latest after
+	// CommandOutputFlushInterval, 20ms by default, and when the command
+	// exits. It may reorder stdout relative to stderr. Zero disables it.
+	CommandOutputBuffer        int
+	CommandOutputFlushInterval time.Duration
+	// PTYOutputBuffer puts a buffer of the given size between the PTY of
+	// shells and the client to detect clients that don't keep up with the
+	// output. Once more than PTYOutputHighWater bytes, half the buffer by
+	// default, stay buffered for PTYBackpressureTimeout, 10 seconds by
+	// default, a warning is logged, and the client is disconnected if
+	// PTYDisconnectStuck is set, ending its sessions with
+	// CloseReasonBackpressure. Zero disables the buffer.
+	PTYOutputBuffer        int
+	PTYOutputHighWater     int
+	PTYBackpressureTimeout time.Duration
+	PTYDisconnectStuck     bool
+	// PTYFactory allocates the PTYs of shell sessions as clients request them,
+	// common.DefaultPTYFactory if nil.
+	PTYFactory common.PTYFactory
+	// RequireAgentForwarding fails sessions whose client requested agent
+	// forwarding if it can't be set up, e.g. because no socket can be created
+	// in the temporary directory. By default they start without it.
+	RequireAgentForwarding bool
+	// BreakSignal is sent to the foreground process group of a PTY session
+	// when the client sends a break request, RFC 4335. Zero sends SIGINT, like
+	// a break on a serial console interrupts the running program.
+	BreakSignal syscall.Signal
+	// AllowedSignals lists the signals clients may send to their shells and
+	// commands, e.g. INT, TERM and HUP but not KILL, which skips the cleanup
+	// of the process. Other signals are dropped and logged. Nil allows all.
+	AllowedSignals []ssh.Signal
+	// InteractiveNice and BatchNice are the niceness of PTY shells and non-PTY
+	// commands, e.g. -5 and 10 keep shells responsive while batch commands
+	// load the host. Zero keeps the niceness of the server. Negative values
+	// require CAP_SYS_NICE, sessions fail to start without it.
+	InteractiveNice int
+	BatchNice       int
+	// CPUAffinity pins the processes of shells and commands to the given
+	// CPUs, e.g. to isolate noisy sessions. Empty doesn't pin them.
+	CPUAffinity []int
+	// MaxCommandLength rejects commands requested by clients longer than the
+	// given number of bytes. Zero means unlimited.
+	MaxCommandLength int
+	// SlowCommandThreshold logs non-PTY commands running longer than the
+	// threshold, from start until exit, and passes them to OnSlowCommand.
+	// Zero disables it.
+	SlowCommandThreshold time.Duration
+	OnSlowCommand        func(SlowCommand)
+	// CommandWrapper is prepended to the argv of every non-PTY command, e.g.
+	// ["nice", "-n", "10"] runs commands as `nice -n 10 /bin/sh -c <command>`.
+	CommandWrapper []string
+	// ContextProvider is called once per connection after authentication and
+	// may attach values, e.g. a tenant ID, for handlers and callbacks to read.
+	// Values must be stored under keys owned by the caller, the ssh.ContextKey*
+	// keys of github.com/gliderlabs/ssh are reserved.
+	ContextProvider func(ctx ssh.Context)
+	// MaxDuration closes connections after the given duration regardless of
+	// activity. Zero disables the limit.
+	MaxDuration time.Duration
+	// Logs enables the daytona-logs subsystem, which streams its recent and
+	// new lines to clients whose Identity has Admin set, at most LogStreamRate
+	// bytes per second, 64 KiB by default, and LogStreamMaxBytes per session,
+	// 16 MiB by default. The buffer must be added as hook to the logger.
+	Logs              *LogBuffer
+	LogStreamRate     int
+	LogStreamMaxBytes int64
+	// SessionAttach lets clients whose Identity has Admin set attach to the
+	// PTY sessions listed by Sessions with the daytona-attach subsystem, to
+	// view their output or, in control mode, type next to their user.
+	SessionAttach bool
+	// Tracer exports a span per connection and a child span per session,
+	// with the user, subsystem, command, exit code and bytes transferred.
+	// Sessions join the trace of clients that send its W3C trace context as
+	// TRACEPARENT variable. Nil disables tracing.
+	Tracer trace.Tracer
+	// OnSessionEnd is called after every session ended.
+	OnSessionEnd func(end SessionEnd)
+	// OnForwardEnd is called once a local forward closed, with the bytes it
+	// carried, e.g. to audit data leaving through tunnels. The traffic is
+	// only counted if it is set. Remote forwards aren't reported.
+	OnForwardEnd func(end ForwardEnd)
+	// StderrTailLines keeps the last lines of stderr of non-PTY commands, at
+	// most 4 KiB, to report them in SessionEnd and the audit log if the
+	// command fails. Clients still get all of stderr. Zero disables it.
+	StderrTailLines int
+	// MaxOutputLines truncates the output of shells and commands once they
+	// wrote the given number of lines to stdout and stderr together, with a
+	// notice on stderr. The command keeps running while its further output
+	// is discarded. Zero means unlimited. SessionEnd reports the lines
+	// either way.
+	MaxOutputLines int
+	// AuditLog receives a JSON line per session start and end, documented by
+	// AuditRecord, e.g. a file opened with OpenAuditLog or os.Stdout. Records
+	// include commands as sent by clients.
+	AuditLog io.Writer
+	// EnvFile is a dotenv-style file whose variables are added to the
+	// environment of every session, after the daemon's own and before the
+	// ones sent by the client. It is read at the start of each session.
+	EnvFile string
+	// VerboseCommands writes "+ <command>" to stderr before running a non-PTY
+	// command, similar to `set -x`. Forced commands are shown as executed.
+	VerboseCommands bool
+	// Banner is sent to clients before authentication. Clients show it apart
+	// from session output, so it never mixes with command stdout.
+	Banner string
+	// BannerFunc overrides Banner with a banner computed once per connection,
+	// e.g. to include the workspace status. It runs before authentication, so
+	// the context only carries connection metadata like the user name.
+	BannerFunc func(ctx ssh.Context) string
+	// WelcomeCommand runs at the start of PTY sessions, after authentication
+	// unlike the banner, and its output is shown before the shell, e.g. a
+	// workspace status script. It may run for WelcomeTimeout, 5 seconds by
+	// default, before it is killed and the shell starts anyway.
+	WelcomeCommand string
+	WelcomeTimeout time.Duration
+	// PostSessionCommands run in order after the shell or command of a
+	// session exited, e.g. to commit workspace state, with the environment
+	// and in the directory of the session but without the client attached.
+	// Each may run for PostSessionTimeout, 30 seconds by default. Failures
+	// are logged. They don't run for sessions ended by a server shutdown.
+	PostSessionCommands []string
+	PostSessionTimeout  time.Duration
+	// BannerFile replaces Banner with the contents of the file, read at start
+	// and again on Reload.
+	BannerFile string
+	// BannerTemplate replaces Banner and BannerFile with a text/template
+	// rendered per connection with TemplateData. Workspaces aren't resolved
+	// before authentication, so the banner shows the host name as the
+	// workspace. BannerFunc still overrides it.
+	BannerTemplate string
+	// WelcomeTemplate is a text/template rendered with TemplateData and shown
+	// at the start of PTY sessions, before the output of WelcomeCommand.
+	WelcomeTemplate string
+	// ReloadOnSIGHUP calls Reload when the process receives SIGHUP, like sshd
+	// does. It is off by default to leave signal handling to the embedding
+	// process.
+	ReloadOnSIGHUP bool
+	// MaxSessions limits the number of concurrently running sessions, shells,
+	// commands and subsystems alike. Zero means unlimited. Shells and
+	// commands see the load as DAYTONA_SESSION_LOAD, the number of running
+	// sessions including their own and MaxSessions, e.g. "3/10".
+	MaxSessions int
+	// MaxSFTPSessions limits the concurrently running SFTP sessions on top of
+	// MaxSessions, since file transfers load the disk more than shells do.
+	// Excess SFTP sessions are rejected right away. Zero means unlimited.
+	MaxSFTPSessions int
+	// MaxConnectionRate sheds connections of all clients beyond the given
+	// number per second on average, closing them before the SSH handshake,
+	// as a safety valve against clients reconnecting in a tight loop. Up to
+	// ConnectionBurst connections, MaxConnectionRate if zero, are accepted at
+	// once. Zero means unlimited.
+	MaxConnectionRate int
+	ConnectionBurst   int
+	// MaxTenantSessions limits the concurrently running sessions of each
+	// tenant, see Identity.Tenant. Zero means unlimited.
+	MaxTenantSessions int
+	// SessionQueueTimeout makes sessions exceeding MaxSessions wait up to the
+	// given duration for a free slot before they are rejected. Zero rejects
+	// them immediately.
+	SessionQueueTimeout time.Duration
+	// TranscriptSize enables recording the last TranscriptSize bytes of output
+	// of shell and command sessions for SessionTranscript. Zero disables it.
+	TranscriptSize int
+	// TranscriptRetention is how long transcripts are kept after their
+	// session ended. Defaults to 10 minutes.
+	TranscriptRetention time.Duration
+	// SanitizeRecordings strips or escapes terminal control sequences in
+	// transcripts and session logs, so reading them in a terminal can't be
+	// used to inject escape sequences. Session logs quote the data anyway,
+	// stripping makes them readable. SanitizeRaw, the default, records
+	// output unchanged.
+	SanitizeRecordings SanitizeMode
+	// SessionLogDir enables writing the input and output of every shell and
+	// command session to a file in the directory, with timestamps and
+	// direction markers, for debugging client specific issues. Values of
+	// EnvFile variables are redacted.
+	SessionLogDir string
+	// SessionLogMaxSize rotates session logs once they exceed the given
+	// number of bytes, keeping one previous file. Defaults to 10 MiB.
+	SessionLogMaxSize int64
+	// CommandTimeout terminates non-PTY commands running longer than the
+	// given duration with SIGTERM and exit status 124. PTY sessions are only
+	// limited by IdleTimeout and MaxDuration. Zero disables the timeout.
+	CommandTimeout time.Duration
+	// TerminationGracePeriod is how long the processes of a session may take
+	// to exit once it is closed, e.g. on shutdown, a timeout or a disconnect,
+	// before their process group is killed with SIGKILL. Commands and
+	// post-session commands get SIGTERM first, shells SIGHUP, like from a
+	// closed terminal. Defaults to 5 seconds.
+	TerminationGracePeriod time.Duration
+	// CommandKillGrace overrides TerminationGracePeriod for non-PTY commands.
+	CommandKillGrace time.Duration
+	// CommandNamespaces runs non-PTY commands in new Linux namespaces, any
+	// of "mount", "pid", "ipc", "uts" and "net". It requires root or
+	// CAP_SYS_ADMIN, commands fail to start otherwise. In a pid namespace the
+	// command runs as PID 1, which only receives the signals it handles, so
+	// it may only end with SIGKILL after CommandKillGrace.
+	CommandNamespaces []string
+	// OnIdle is called once the last active session or port forward of the
+	// server ended, e.g. to let the workspace controller schedule a stop.
+	OnIdle func()
+	// OnIdleDelay debounces OnIdle, which is only called if no new session
+	// started within the delay, so reconnecting clients don't cause flapping.
+	OnIdleDelay time.Duration
+	// HostKeys are the keys the server identifies with, a generated RSA key
+	// if empty. All of them are advertised to clients after authentication
+	// with the hostkeys-00@openssh.com extension, so OpenSSH clients with
+	// UpdateHostKeys learn a new key while the old one is still served.
+	HostKeys []ssh.Signer
+	// KeyExchanges, Ciphers and MACs restrict the algorithms clients may
+	// negotiate. Empty lists use secure defaults without SHA-1 based
+	// algorithms. Clients unable to negotiate within the set are rejected.
+	KeyExchanges []string
+	Ciphers      []string
+	MACs         []string
+	// ReadinessCheck is called before shells and commands start, e.g. to wait
+	// for the mounts of a cold workspace. Sessions are rejected if it fails or
+	// doesn't return within ReadinessTimeout, unless that is zero.
+	ReadinessCheck   func(ctx ssh.Context) error
+	ReadinessTimeout time.Duration
+	// ReadinessMessage is shown on stderr while ReadinessCheck runs, followed
+	// by a dot per second of waiting.
+	ReadinessMessage string
+	// ListenBacklog is the size of the queue of connections not yet accepted,
+	// raised to absorb bursts of clients during mass workspace startup. Zero
+	// uses the system maximum, net.core.somaxconn.
+	ListenBacklog int
+	// ReusePort sets SO_REUSEPORT on the listener, so a new server can take
+	// over the port while the previous one still drains its connections.
+	ReusePort bool
+	// ServeRetries is how many times in a row the server listens on its port
+	// again when accepting connections fails, rather than Start returning the
+	// error. Attempts wait ServeRetryBackoff, a second if zero, doubled on
+	// every attempt up to 30 seconds. Zero never retries. Failing to listen
+	// again always ends the server.
+	ServeRetries      int
+	ServeRetryBackoff time.Duration
+	// Listeners are served next to the SSH port, each restricted to the
+	// channels and sessions it allows.
+	Listeners []Listener
+
+	sshServer *ssh.Server
+	closing   atomic.Bool
+	agentDirs sync.Map
+	banner    atomic.Pointer[string]
+	stopHUP   func()
+
+	// listener is the listener of the server on its port, replaced when the
+	// server listens again after a failure.
+	listenerMu sync.Mutex
+	listener   net.Listener
+
+	welcomeTemplate *template.Template
+
+	transcripts  transcripts
+	sessions     sessions
+	tenants      tenants
+	forwards     forwards
+	idle         idleTracker
+	sessionSlots chan struct{}
+	sftpSlots    chan struct{}
+	connRate     *connRateLimiter
+	auditMu      sync.Mutex
 }
 
 func (s *Server) Start() error {
+	if err := s.validateAlgorithms(); err != nil {
+		return err
+	}
+	if _, _, err := s.parseTemplates(); err != nil {
+		return err
+	}
+
+	removeStaleAgentSockets()
+
+	s.sshServer = s.newSSHServer()
+
+	l, err := s.listen(s.sshServer.Addr)
+	if err != nil {
+		return err
+	}
+	extra, err := s.listenExtra()
+	if err != nil {
+		l.Close()
+		return err
+	}
+
+	if s.ReloadOnSIGHUP {
+		s.stopHUP = s.reloadOnSIGHUP()
+	}
+
+	s.logConfig(l.Addr())
+	for _, restricted := range extra {
+		log.Printf("Starting restricted ssh listener on %s...\n", restricted.Addr())
+		go func() {
+			if err := s.sshServer.Serve(restricted); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+				log.Errorf("SSH listener on %s failed: %v", restricted.Addr(), err)
+			}
+		}()
+	}
+	log.Printf("Starting ssh server on port %d...\n", config.SSH_PORT)
+	s.setListener(l)
+	return s.serve(l, s.sshServer.Addr)
+}
+
+// Close stops the server and closes all active connections.
+func (s *Server) Close() error {
+	if s.sshServer == nil {
+		return nil
+	}
+
+	s.closing.Store(true)
+	defer s.removeAgentSockets()
+	if s.stopHUP != nil {
+		s.stopHUP()
+	}
+	s.closeListener()
+
+	return s.sshServer.Close()
+}
+
+func (s *Server) newSSHServer() *ssh.Server {
+	// forwarded-tcpip channels carry the address and port of the peer that
+	// connected to the forwarded port as originator, so services behind a
+	// reverse tunnel can log the actual client.
 	forwardedTCPHandler := &ssh.ForwardedTCPHandler{}
 	unixForwardHandler := newForwardedUnixHandler()
 
-	sshServer := ssh.Server{
-		Addr: fmt.Sprintf(":%d", config.SSH_PORT),
-		Handler: func(session ssh.Session) {
-			switch ss := session.Subsystem(); ss {
-			case "":
-			case "sftp":
-				s.sftpHandler(session)
-				return
-			default:
-				log.Errorf("Subsystem %s not supported\n", ss)
-				session.Exit(1)
-				return
-			}
+	limitSessions := s.sessionLimiter()
+	// sessionHandler wraps the handlers of shell, command and subsystem
+	// sessions with what every session goes through. Panics are recovered
+	// around handler, so the end of the session is still tracked, and around
+	// the whole chain, so callbacks like SessionAuthorizer or OnSessionEnd
+	// can't crash the daemon either.
+	sessionHandler := func(handler func(ssh.Session)) func(ssh.Session) {
+		return recoverSession(s.trackSession(s.authorizeSession(s.limitTenantSessions(limitSessions(s.registerSession(recoverSession(handler)))))))
+	}
+	if s.MaxSFTPSessions > 0 {
+		s.sftpSlots = make(chan struct{}, s.MaxSFTPSessions)
+	}
+	if s.MaxConnectionRate > 0 {
+		s.connRate = newConnRateLimiter(s.MaxConnectionRate, s.ConnectionBurst)
+	}
 
-			ptyReq, winCh, isPty := session.Pty()
-			if session.RawCommand() == "" && isPty {
-				s.handlePty(session, ptyReq, winCh)
-			} else {
-				s.handleNonPty(session)
-			}
-		},
+	sshServer := &ssh.Server{
+		Addr:                 fmt.Sprintf(":%d", config.SSH_PORT),
+		IdleTimeout:          s.IdleTimeout,
+		MaxTimeout:           s.MaxDuration,
+		Banner:               s.Banner,
+		ConnCallback:         s.connCallback,
+		ServerConfigCallback: s.serverConfig,
+		Handler:              sessionHandler(s.handleSession),
 		ChannelHandlers: map[string]ssh.ChannelHandler{
-			"session":                        ssh.DefaultSessionHandler,
-			"direct-tcpip":                   ssh.DirectTCPIPHandler,
-			"direct-streamlocal@openssh.com": directStreamLocalHandler,
+			"session":                        withPtyRequests(s.ptyFactory(), ssh.DefaultSessionHandler),
+			"direct-tcpip":                   s.trackLocalForwards("tcp", ssh.DirectTCPIPHandler),
+			"direct-streamlocal@openssh.com": s.trackLocalForwards("unix", directStreamLocalHandler),
 		},
 		RequestHandlers: map[string]ssh.RequestHandler{
-			"tcpip-forward":                          forwardedTCPHandler.HandleSSHRequest,
-			"cancel-tcpip-forward":                   forwardedTCPHandler.HandleSSHRequest,
-			"streamlocal-forward@openssh.com":        unixForwardHandler.HandleSSHRequest,
-			"cancel-streamlocal-forward@openssh.com": unixForwardHandler.HandleSSHRequest,
+			"tcpip-forward":                          s.trackRemoteForwards(forwardedTCPHandler.HandleSSHRequest),
+			"cancel-tcpip-forward":                   s.trackRemoteForwards(forwardedTCPHandler.HandleSSHRequest),
+			"streamlocal-forward@openssh.com":        s.trackRemoteForwards(unixForwardHandler.HandleSSHRequest),
+			"cancel-streamlocal-forward@openssh.com": s.trackRemoteForwards(unixForwardHandler.HandleSSHRequest),
+			defaultRequestType:                       unknownRequestHandler,
 		},
 		SubsystemHandlers: map[string]ssh.SubsystemHandler{
-			"sftp": s.sftpHandler,
+			"sftp":           sessionHandler(subsystemHandler("sftp", s.sftpHandler)),
+			daytonaSubsystem: sessionHandler(s.daytonaSubsystemHandler),
+			logsSubsystem:    sessionHandler(subsystemHandler(logsSubsystem, s.logsHandler)),
+			attachSubsystem:  sessionHandler(subsystemHandler(attachSubsystem, s.attachHandler)),
+		},
+		PtyCallback: func(ctx ssh.Context, pty ssh.Pty) bool {
+			return !keyOptionsFromContext(ctx).NoPty
 		},
 		LocalPortForwardingCallback: ssh.LocalPortForwardingCallback(func(ctx ssh.Context, dhost string, dport uint32) bool {
-			return true
-		}),
-		ReversePortForwardingCallback: ssh.ReversePortForwardingCallback(func(ctx ssh.Context, host string, port uint32) bool {
-			return true
+			return !keyOptionsFromContext(ctx).NoPortForwarding
 		}),
-		SessionRequestCallback: func(sess ssh.Session, requestType string) bool {
-			return true
-		},
+		ReversePortForwardingCallback: ssh.ReversePortForwardingCallback(s.reverseForwardAllowed),
+		SessionRequestCallback:        s.sessionRequestAllowed,
 	}
 
-	log.Printf("Starting ssh server on port %d...\n", config.SSH_PORT)
-	return sshServer.ListenAndServe()
+	if s.BannerFile != "" {
+		if err := s.loadBannerFile(); err != nil {
+			log.Warn(err)
+		}
+		sshServer.BannerHandler = s.bannerFileHandler
+	}
+	bannerTemplate, welcomeTemplate, err := s.parseTemplates()
+	if err != nil {
+		log.Warn(err)
+	}
+	if bannerTemplate != nil {
+		sshServer.BannerHandler = s.bannerTemplateHandler(bannerTemplate, sshServer.BannerHandler)
+	}
+	s.welcomeTemplate = welcomeTemplate
+	if s.BannerFunc != nil {
+		sshServer.BannerHandler = s.BannerFunc
+	}
+
+	s.withRejectedRequests(sshServer.RequestHandlers)
+	s.withListeners(sshServer)
+	s.withHostKeys(sshServer)
+	s.withAuthenticator(sshServer)
+	s.withContextProvider(sshServer)
+
+	return sshServer
 }
 
-func (s *Server) handlePty(session ssh.Session, ptyReq ssh.Pty, winCh <-chan ssh.Window) {
-	dir := s.ProjectDir
+func (s *Server) handleSession(session ssh.Session) {
+	switch ss := session.Subsystem(); ss {
+	case "":
+	case "sftp":
+		subsystemHandler("sftp", s.sftpHandler)(session)
+		return
+	default:
+		log.Errorf("Subsystem %s not supported\n", ss)
+		session.Exit(1)
+		return
+	}
 
-	if _, err := os.Stat(s.ProjectDir); os.IsNotExist(err) {
-		dir = s.DefaultProjectDir
+	// Failing paths below may return without an exit status, which must not
+	// be reported as success.
+	defer exitOnFailure(session)
+
+	if s.MaxCommandLength > 0 && len(session.RawCommand()) > s.MaxCommandLength {
+		log.Warnf("Rejecting command of %d bytes in session %s", len(session.RawCommand()), session.Context().SessionID())
+		_, _ = fmt.Fprintf(session.Stderr(), "Command exceeds the maximum length of %d bytes\n", s.MaxCommandLength)
+		setCloseReason(session, CloseReasonPolicyDenied)
+		_ = session.Exit(1)
+		return
 	}
 
-	env := []string{}
+	if !s.awaitReadiness(session) {
+		return
+	}
 
-	if ssh.AgentRequested(session) {
-		l, err := ssh.NewAgentListener()
-		if err != nil {
-			log.Errorf("Failed to start agent listener: %v", err)
+	command := s.sessionCommand(session)
+
+	ptyReq, winCh, isPty := session.Pty()
+	// A blank command does nothing, so it gets a shell if there's a terminal
+	// for one. Unlike a shell request without a PTY, it doesn't run stdin.
+	if command != "" && strings.TrimSpace(command) == "" {
+		if !isPty {
+			_ = session.Exit(0)
 			return
 		}
-		defer l.Close()
-		go ssh.ForwardAgentConnections(l, session)
-		env = append(env, fmt.Sprintf("%s=%s", "SSH_AUTH_SOCK", l.Addr().String()))
+		command = ""
+	}
+
+	if command == "" && isPty {
+		if s.DisablePty {
+			s.denyShell(session)
+			return
+		}
+		s.handlePty(session, ptyReq, winCh, session)
+	} else {
+		s.handleNonPty(session, command)
+	}
+}
+
+// recoverSession keeps a panicking handler from crashing the daemon. The panic
+// is logged and the session is closed with a non-zero exit status.
+func recoverSession(handler func(ssh.Session)) func(ssh.Session) {
+	return func(session ssh.Session) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("Panic in session %s: %v\n%s", session.Context().SessionID(), r, debug.Stack())
+				setCloseReason(session, CloseReasonError)
+				_ = session.Exit(1)
+			}
+		}()
+
+		handler(session)
 	}
+}
+
+// sessionCommand returns the command to run for the session. A command forced
+// by the authorized key takes precedence over the one requested by the client.
+func (s *Server) sessionCommand(session ssh.Session) string {
+	if command := keyOptionsFromContext(session.Context()).Command; command != "" {
+		return command
+	}
+
+	return session.RawCommand()
+}
 
-	sizeCh := make(chan common.TTYSize)
+func agentForwardingAllowed(session ssh.Session) bool {
+	return ssh.AgentRequested(session) && !keyOptionsFromContext(session.Context()).NoAgentForwarding
+}
+
+func (s *Server) denyShell(session ssh.Session) {
+	message := s.ShellDeniedMessage
+	if message == "" {
+		message = defaultShellDeniedMessage
+	}
+
+	_, err := fmt.Fprintln(session, message)
+	if err != nil {
+		log.Debugf("Unable to write shell denied message: %v", err)
+	}
 
+	setCloseReason(session, CloseReasonPolicyDenied)
+
+	err = session.Exit(1)
+	if err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to exit session: %v", err)
+	}
+}
+
+// handlePty runs the shell of a PTY session, reading its input from stdin.
+func (s *Server) handlePty(session ssh.Session, ptyReq ssh.Pty, winCh <-chan ssh.Window, stdin io.Reader) {
+	env := append(s.sessionEnv(), s.clientEnv(session)...)
+	env = append(env, connectionEnv(session.Context())...)
+	env = append(env, ptyEnv(true))
+	env = append(env, s.sessionLoadEnv()...)
+	env = append(env, s.readOnlyEnv()...)
+
+	tmpEnv, cleanupTmp, err := s.sessionTmpDir()
+	if err != nil {
+		log.Errorf("Unable to start session: %v", err)
+		setCloseReason(session, CloseReasonError)
+		_ = session.Exit(1)
+		return
+	}
+	defer cleanupTmp()
+	env = append(env, tmpEnv...)
+
+	agent, ok := s.forwardAgent(session)
+	if !ok {
+		return
+	}
+	if agent != nil {
+		defer agent.Close()
+		env = append(env, fmt.Sprintf("%s=%s", "SSH_AUTH_SOCK", agent.Addr().String()))
+	}
+
+	sizeCh := ptySizes(session, winCh)
+
+	sigs := make(chan ssh.Signal, 1)
+	breaks := make(chan bool, 1)
+	signalCh := make(chan syscall.Signal)
+	done := make(chan struct{})
+	session.Signals(sigs)
+	session.Break(breaks)
+	defer func() {
+		session.Signals(nil)
+		session.Break(nil)
+		close(done)
+		close(sigs)
+	}()
 	go func() {
-		for win := range winCh {
-			sizeCh <- common.TTYSize{
-				Height: win.Height,
-				Width:  win.Width,
+		defer close(signalCh)
+		for {
+			var sig syscall.Signal
+			select {
+			case req, ok := <-sigs:
+				if !ok {
+					return
+				}
+				if !s.signalAllowed(session, req) {
+					continue
+				}
+				sig = s.osSignalFrom(req).(syscall.Signal)
+			case <-breaks:
+				sig = s.breakSignal()
+			}
+
+			select {
+			case signalCh <- sig:
+			case <-done:
 			}
 		}
 	}()
 
-	err := common.SpawnTTY(common.SpawnTTYOptions{
-		Dir:    dir,
-		StdIn:  session,
-		StdOut: session,
-		Term:   ptyReq.Term,
-		Env:    env,
-		SizeCh: sizeCh,
+	s.showReadOnlyNotice(session)
+	s.showWelcomeTemplate(session)
+	s.showWelcome(session, ptyReq.Term, env)
+
+	stdout, flush := s.ptyOutput(session)
+	stdin, stdout = ptyAttachIO(session, stdin, stdout)
+	// The shell is hung up once the client closes the session, too.
+	ctx, cancel := sessionContext(session)
+	defer cancel()
+	shellDir := ""
+	workspaceDir := s.workspaceDir(session)
+	err = s.startInProjectDir(workspaceDir, func(dir string) error {
+		shellDir = dir
+		s.showWorkDirFallback(session, workspaceDir, dir)
+		return common.SpawnTTY(common.SpawnTTYOptions{
+			Dir:        dir,
+			StdIn:      stdin,
+			StdOut:     stdout,
+			Term:       ptyReq.Term,
+			Shell:      s.userShell(session.Context(), ""),
+			BaseEnv:    s.daemonEnv(),
+			Env:        append(env, workDirEnv(dir)),
+			SizeCh:     sizeCh,
+			SignalCh:   signalCh,
+			Modes:      terminalModes(session),
+			PTY:        sessionPTY(session),
+			Ctx:        ctx,
+			PTYFactory: s.PTYFactory,
+			Sched:      common.SchedAttr{Nice: s.InteractiveNice, CPUAffinity: s.CPUAffinity},
+			KillGrace:  s.terminationGracePeriod(),
+		})
 	})
+	flush()
+	if shellDir != "" && len(s.PostSessionCommands) > 0 {
+		defer s.runPostSessionCommands(shellDir, append(s.daemonEnv(), env...))
+	}
+
+	if errors.Is(err, errProjectDirUnavailable) {
+		log.Errorf("Failed to spawn tty: %v", err)
+		_, _ = fmt.Fprintln(session, err)
+		setCloseReason(session, CloseReasonError)
+		_ = session.Exit(1)
+		return
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		_ = session.Exit(exitErr.ExitCode())
+		return
+	}
+
+	if err != nil && connClosed(session.Context()) {
+		// The shell was hung up on after the client went away.
+		log.Debugf("Shell of session %s ended after the connection closed: %v", session.Context().SessionID(), err)
+		return
+	}
 
 	if err != nil {
-		// Debug log here because this gets called on each ssh "exit"
-		// TODO: Find a better way to handle this
-		log.Debugf("Failed to spawn tty: %v", err)
+		log.Errorf("Failed to spawn tty: %v", err)
+		setCloseReason(session, CloseReasonError)
 		return
 	}
+
+	err = session.Exit(0)
+	if err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to exit session: %v", err)
+	}
 }
 
-func (s *Server) handleNonPty(session ssh.Session) {
+// ptyEnv returns DAYTONA_SSH_PTY, 1 for PTY sessions and 0 otherwise, so
+// scripts can tell interactive sessions apart without checking for a TTY.
+func ptyEnv(isPty bool) string {
+	if isPty {
+		return "DAYTONA_SSH_PTY=1"
+	}
+	return "DAYTONA_SSH_PTY=0"
+}
+
+func (s *Server) handleNonPty(session ssh.Session, command string) {
 	args := []string{}
-	if len(session.Command()) > 0 {
-		args = append([]string{"-c"}, session.RawCommand())
+	if command != "" {
+		args = append([]string{"-c"}, command)
 	}
 
-	cmd := exec.Command("/bin/sh", args...)
+	env := append(s.daemonEnv(), s.sessionEnv()...)
+	env = append(env, s.clientEnv(session)...)
+	env = append(env, connectionEnv(session.Context())...)
+	env = append(env, ptyEnv(false))
+	env = append(env, s.sessionLoadEnv()...)
+	env = append(env, s.readOnlyEnv()...)
 
-	cmd.Env = append(cmd.Env, os.Environ()...)
+	tmpEnv, cleanupTmp, err := s.sessionTmpDir()
+	if err != nil {
+		log.Errorf("Unable to start session: %v", err)
+		setCloseReason(session, CloseReasonError)
+		_ = session.Exit(1)
+		return
+	}
+	defer cleanupTmp()
+	env = append(env, tmpEnv...)
 
-	if ssh.AgentRequested(session) {
-		l, err := ssh.NewAgentListener()
-		if err != nil {
-			log.Errorf("Failed to start agent listener: %v", err)
-			return
-		}
-		defer l.Close()
-		go ssh.ForwardAgentConnections(l, session)
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", "SSH_AUTH_SOCK", l.Addr().String()))
+	if command != session.RawCommand() && session.RawCommand() != "" {
+		env = append(env, fmt.Sprintf("%s=%s", "SSH_ORIGINAL_COMMAND", session.RawCommand()))
+	}
+
+	agent, ok := s.forwardAgent(session)
+	if !ok {
+		return
+	}
+	if agent != nil {
+		defer agent.Close()
+		env = append(env, fmt.Sprintf("%s=%s", "SSH_AUTH_SOCK", agent.Addr().String()))
 	}
 
-	cmd.Dir = s.ProjectDir
-	if _, err := os.Stat(s.ProjectDir); os.IsNotExist(err) {
-		cmd.Dir = s.DefaultProjectDir
+	if s.VerboseCommands && command != "" {
+		_, _ = fmt.Fprintf(session.Stderr(), "+ %s\n", command)
 	}
 
-	cmd.Stdout = session
-	cmd.Stderr = session.Stderr()
-	stdinPipe, err := cmd.StdinPipe()
+	// The command is terminated once the client closes the session or
+	// disconnects, the server shuts down or the timeout expires.
+	ctx, cancel := sessionContext(session)
+	defer cancel()
+	if s.CommandTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, s.CommandTimeout)
+		defer cancelTimeout()
+	}
+
+	var cmd *exec.Cmd
+	var stdinPipe io.WriteCloser
+	var stdinFile *os.File
+	stdout, flushStdout := s.commandOutput(session)
+	group := &processGroup{grace: s.commandKillGrace()}
+	defer group.stop()
+	err = s.startInProjectDir(s.workspaceDir(session), func(dir string) error {
+		cmd = s.wrapCommand(ctx, s.userShell(session.Context(), "/bin/sh"), args...)
+		cmd.Env = append(env, workDirEnv(dir))
+		cmd.Dir = dir
+
+		if stdinFile != nil {
+			stdinFile.Close()
+		}
+		var err error
+		if stdinFile, err = s.openStdinFile(session, dir); err != nil {
+			return err
+		}
+
+		// The command leads its own session, so processes it starts are
+		// terminated with its process group, and it has no controlling
+		// terminal, even if the daemon was started from one.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		cmd.Cancel = group.cancel(cmd)
+		if err := setCommandNamespaces(cmd.SysProcAttr, s.CommandNamespaces); err != nil {
+			return err
+		}
+		if s.CommandTimeout > 0 {
+			// Output of background processes that outlive a timed out command
+			// isn't waited for either.
+			cmd.WaitDelay = s.commandKillGrace()
+		}
+
+		// Neither writer is an *os.File, so exec copies stdout and stderr from
+		// separate pipes in separate goroutines. A command writing heavily to
+		// both streams can't block one on the other.
+		cmd.Stdout = stdout
+		cmd.Stderr = s.teeStderrTail(session)
+
+		stdinPipe, err = cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+
+		return common.RunWithSchedAttr(common.SchedAttr{Nice: s.BatchNice, CPUAffinity: s.CPUAffinity}, cmd.Start)
+	})
 	if err != nil {
-		log.Errorf("Unable to setup stdin for session: %v", err)
+		log.Errorf("Unable to start command: %v", err)
+		setCloseReason(session, CloseReasonError)
+		if stdinFile != nil {
+			stdinFile.Close()
+		}
+		if errors.Is(err, errProjectDirUnavailable) || errors.Is(err, errInvalidStdinFile) {
+			_, _ = fmt.Fprintln(session.Stderr(), err)
+			_ = session.Exit(1)
+		}
 		return
 	}
+	started := time.Now()
+	fellBack := false
+	if len(s.PostSessionCommands) > 0 {
+		defer func() {
+			// The shell replacing a command that wasn't found runs them.
+			if !fellBack {
+				s.runPostSessionCommands(cmd.Dir, env)
+			}
+		}()
+	}
+
+	var stdin io.Reader = session
+	var input *sessionInput
+	stopInput := make(chan struct{})
+	if stdinFile != nil {
+		defer stdinFile.Close()
+		stdin = stdinFile
+	} else {
+		if s.shellFallbackAllowed(session, command) {
+			input = newSessionInput(session)
+			defer input.close()
+			stdin = input.reader(stopInput)
+		}
+		if s.TranslateCRLF {
+			stdin = newCRLFReader(stdin)
+		}
+	}
+
 	go func() {
-		_, err := io.Copy(stdinPipe, session)
+		_, err := io.Copy(stdinPipe, stdin)
 		if err != nil {
-			log.Errorf("Unable to read from session: %v", err)
+			logSessionError(log.ErrorLevel, err, "Unable to read from session: %v", err)
 			return
 		}
 		_ = stdinPipe.Close()
 	}()
 
-	err = cmd.Start()
-	if err != nil {
-		log.Errorf("Unable to start command: %v", err)
-		return
-	}
 	sigs := make(chan ssh.Signal, 1)
 	session.Signals(sigs)
 	defer func() {
@@ -180,6 +972,9 @@ func (s *Server) handleNonPty(session ssh.Session) {
 	}()
 	go func() {
 		for sig := range sigs {
+			if !s.signalAllowed(session, sig) {
+				continue
+			}
 			signal := s.osSignalFrom(sig)
 			err := cmd.Process.Signal(signal)
 			if err != nil {
@@ -188,70 +983,146 @@ func (s *Server) handleNonPty(session ssh.Session) {
 		}
 	}()
 	err = cmd.Wait()
+	close(stopInput)
+	flushStdout()
+	s.reportSlowCommand(session, command, time.Since(started))
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Debugf("Command %q timed out after %s: %v", command, s.CommandTimeout, err)
+		_, _ = fmt.Fprintf(session.Stderr(), "Command timed out after %s\n", s.CommandTimeout)
+		setCloseReason(session, CloseReasonCommandTimeout)
+		_ = session.Exit(124)
+		return
+	}
+
+	if err != nil && connClosed(session.Context()) {
+		// The client is gone, usually the command was killed by SIGPIPE
+		// writing to it. There is no one left to report the status to.
+		log.Debugf("Command %q ended after the connection closed: %v", command, err)
+		_ = session.Exit(127)
+		return
+	}
+
+	var exitErr *exec.ExitError
+	if input != nil && errors.As(err, &exitErr) && exitErr.ExitCode() == commandNotFoundStatus {
+		fellBack = true
+		s.showShellFallback(session, command)
+		ptyReq, winCh, _ := session.Pty()
+		s.handlePty(session, ptyReq, winCh, input.reader(nil))
+		return
+	}
 
 	if err != nil {
-		log.Println(session.RawCommand(), " ", err)
+		log.Println(command, " ", err)
+
+		if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+			session.Exit(exitErr.ExitCode())
+			return
+		}
+
 		session.Exit(127)
 		return
 	}
 
 	err = session.Exit(0)
 	if err != nil {
-		log.Warnf("Unable to exit session: %v", err)
+		logSessionError(log.WarnLevel, err, "Unable to exit session: %v", err)
 	}
 }
 
-func (s *Server) osSignalFrom(sig ssh.Signal) os.Signal {
-	switch sig {
-	case ssh.SIGABRT:
-		return unix.SIGABRT
-	case ssh.SIGALRM:
-		return unix.SIGALRM
-	case ssh.SIGFPE:
-		return unix.SIGFPE
-	case ssh.SIGHUP:
-		return unix.SIGHUP
-	case ssh.SIGILL:
-		return unix.SIGILL
-	case ssh.SIGINT:
-		return unix.SIGINT
-	case ssh.SIGKILL:
-		return unix.SIGKILL
-	case ssh.SIGPIPE:
-		return unix.SIGPIPE
-	case ssh.SIGQUIT:
-		return unix.SIGQUIT
-	case ssh.SIGSEGV:
-		return unix.SIGSEGV
-	case ssh.SIGTERM:
-		return unix.SIGTERM
-	case ssh.SIGUSR1:
-		return unix.SIGUSR1
-	case ssh.SIGUSR2:
-		return unix.SIGUSR2
+func (s *Server) commandKillGrace() time.Duration {
+	if s.CommandKillGrace <= 0 {
+		return s.terminationGracePeriod()
+	}
 
-	// Unhandled, use sane fallback.
-	default:
-		return unix.SIGKILL
+	return s.CommandKillGrace
+}
+
+func (s *Server) terminationGracePeriod() time.Duration {
+	if s.TerminationGracePeriod <= 0 {
+		return defaultTerminationGracePeriod
 	}
+
+	return s.TerminationGracePeriod
 }
 
-func (s *Server) sftpHandler(session ssh.Session) {
-	debugStream := io.Discard
-	serverOptions := []sftp.ServerOption{
-		sftp.WithDebug(debugStream),
+func (s *Server) wrapCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if len(s.CommandWrapper) == 0 {
+		return exec.CommandContext(ctx, name, args...)
 	}
-	server, err := sftp.NewServer(
-		session,
-		serverOptions...,
-	)
-	if err != nil {
-		log.Errorf("sftp server init error: %s\n", err)
-		return
+
+	argv := make([]string, 0, len(s.CommandWrapper)+len(args))
+	argv = append(argv, s.CommandWrapper[1:]...)
+	argv = append(argv, name)
+	argv = append(argv, args...)
+
+	return exec.CommandContext(ctx, s.CommandWrapper[0], argv...)
+}
+
+// userShell returns the shell UserShells configures for the identity of the
+// connection, or else its user name, and fallback if there is none.
+func (s *Server) userShell(ctx ssh.Context, fallback string) string {
+	if identity, ok := IdentityFromContext(ctx); ok && identity.ID != "" {
+		if shell, ok := s.UserShells[identity.ID]; ok {
+			return shell
+		}
 	}
-	if err := server.Serve(); err == io.EOF {
-		server.Close()
-	} else if err != nil {
-		log.Errorf("sftp server completed with error: %s\n", err)
+	if shell, ok := s.UserShells[ctx.User()]; ok {
+		return shell
 	}
+
+	return fallback
+}
+
+func (s *Server) ptyFactory() common.PTYFactory {
+	if s.PTYFactory == nil {
+		return common.DefaultPTYFactory
+	}
+
+	return s.PTYFactory
+}
+
+func (s *Server) breakSignal() syscall.Signal {
+	if s.BreakSignal == 0 {
+		return syscall.SIGINT
+	}
+
+	return s.BreakSignal
+}
+
+// sshSignals maps the signal names of RFC 4254 to the signals delivered.
+var sshSignals = map[ssh.Signal]unix.Signal{
+	ssh.SIGABRT: unix.SIGABRT,
+	ssh.SIGALRM: unix.SIGALRM,
+	ssh.SIGFPE:  unix.SIGFPE,
+	ssh.SIGHUP:  unix.SIGHUP,
+	ssh.SIGILL:  unix.SIGILL,
+	ssh.SIGINT:  unix.SIGINT,
+	ssh.SIGKILL: unix.SIGKILL,
+	ssh.SIGPIPE: unix.SIGPIPE,
+	ssh.SIGQUIT: unix.SIGQUIT,
+	ssh.SIGSEGV: unix.SIGSEGV,
+	ssh.SIGTERM: unix.SIGTERM,
+	ssh.SIGUSR1: unix.SIGUSR1,
+	ssh.SIGUSR2: unix.SIGUSR2,
+}
+
+func (s *Server) osSignalFrom(sig ssh.Signal) os.Signal {
+	if signal, ok := sshSignals[sig]; ok {
+		return signal
+	}
+
+	// Unhandled, use sane fallback.
+	return unix.SIGKILL
+}
+
+// signalAllowed reports whether AllowedSignals lets the client of session
+// send sig, logging the attempt if it doesn't.
+func (s *Server) signalAllowed(session ssh.Session, sig ssh.Signal) bool {
+	if s.AllowedSignals == nil || slices.Contains(s.AllowedSignals, sig) {
+		return true
+	}
+
+	log.Warnf("Blocked signal %s from user %s in session %s", sig, session.User(), session.Context().SessionID())
+	return false
 }
@@ -0,0 +1,1506 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/daytonaio/daemon/pkg/common"
+	"github.com/gliderlabs/ssh"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func startTestServer(t testing.TB, s *Server) string {
+	t.Helper()
+
+	return serveTestServer(t, s.newSSHServer())
+}
+
+func serveTestServer(t testing.TB, sshServer *ssh.Server) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		_ = sshServer.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = sshServer.Close()
+	})
+
+	return l.Addr().String()
+}
+
+func dialTestServer(t testing.TB, addr string, auth ...gossh.AuthMethod) *gossh.Client {
+	t.Helper()
+
+	return dialTestServerAs(t, addr, "daytona", auth...)
+}
+
+func dialTestServerAs(t testing.TB, addr, user string, auth ...gossh.AuthMethod) *gossh.Client {
+	t.Helper()
+
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func newTestServer(t testing.TB) *Server {
+	t.Helper()
+
+	return &Server{
+		ProjectDir:        t.TempDir(),
+		DefaultProjectDir: t.TempDir(),
+	}
+}
+
+func runWithTimeout(t *testing.T, timeout time.Duration, fn func() error) error {
+	t.Helper()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		t.Fatalf("timed out after %s", timeout)
+		return nil
+	}
+}
+
+func TestNonPtyLargeStdoutAndStderr(t *testing.T) {
+	const size = 8 << 20
+
+	addr := startTestServer(t, newTestServer(t))
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	err = runWithTimeout(t, 30*time.Second, func() error {
+		return session.Run("head -c 8388608 /dev/zero & head -c 8388608 /dev/zero >&2; wait")
+	})
+	require.NoError(t, err)
+	require.Equal(t, size, stdout.Len())
+	require.Equal(t, size, stderr.Len())
+}
+
+func TestShellDeniedMessage(t *testing.T) {
+	s := newTestServer(t)
+	s.DisablePty = true
+	s.ShellDeniedMessage = "Interactive shells are disabled for this workspace; use the Daytona CLI"
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+
+	require.NoError(t, session.RequestPty("xterm", 24, 80, gossh.TerminalModes{}))
+	require.NoError(t, session.Shell())
+
+	err = runWithTimeout(t, 5*time.Second, session.Wait)
+
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitStatus())
+	require.Contains(t, stdout.String(), s.ShellDeniedMessage)
+}
+
+func TestSessionAuthorizer(t *testing.T) {
+	s := newTestServer(t)
+	s.SessionAuthorizer = func(ctx ssh.Context, subsystem, command string) error {
+		if subsystem == "sftp" {
+			return nil
+		}
+		return errors.New("only SFTP is allowed for this user")
+	}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	err = session.Run("echo denied")
+
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitStatus())
+	require.Equal(t, "only SFTP is allowed for this user\n", stderr.String())
+
+	// The connection stays usable for the allowed channel type.
+	sftpClient, err := sftp.NewClient(client)
+	require.NoError(t, err)
+	defer sftpClient.Close()
+
+	_, err = sftpClient.Stat(s.ProjectDir)
+	require.NoError(t, err)
+}
+
+func TestServerSurvivesHandlerPanic(t *testing.T) {
+	var sftpPanicked atomic.Bool
+	s := newTestServer(t)
+	// The authorizer runs outside of the handler, in the wrapping every
+	// session goes through.
+	s.SessionAuthorizer = func(ctx ssh.Context, subsystem, command string) error {
+		if command == "panic" || subsystem == "sftp" && !sftpPanicked.Swap(true) {
+			panic("boom")
+		}
+		return nil
+	}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		return session.Run("panic")
+	})
+
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitStatus())
+
+	_, err = sftp.NewClient(client)
+	require.Error(t, err)
+
+	session, err = client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	output, err := session.Output("echo ok")
+	require.NoError(t, err)
+	require.Equal(t, "ok\n", string(output))
+
+	sftpClient, err := sftp.NewClient(client)
+	require.NoError(t, err)
+	defer sftpClient.Close()
+	_, err = sftpClient.Stat(s.ProjectDir)
+	require.NoError(t, err)
+}
+
+func TestCommandWrapper(t *testing.T) {
+	s := newTestServer(t)
+	s.CommandWrapper = []string{"env", "WRAPPED=1"}
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		return session.Run("echo wrapped=$WRAPPED dir=$(pwd); exit 3")
+	})
+
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 3, exitErr.ExitStatus())
+	require.Equal(t, "wrapped=1 dir="+s.ProjectDir+"\n", stdout.String())
+}
+
+func TestContextProvider(t *testing.T) {
+	type tenantKey struct{}
+
+	s := newTestServer(t)
+	s.ContextProvider = func(ctx ssh.Context) {
+		ctx.SetValue(tenantKey{}, "tenant-"+ctx.User())
+	}
+
+	sshServer := s.newSSHServer()
+	sshServer.Handler = func(session ssh.Session) {
+		tenant, _ := session.Context().Value(tenantKey{}).(string)
+		_, _ = io.WriteString(session, tenant)
+	}
+
+	addr := serveTestServer(t, sshServer)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	output, err := session.Output("true")
+	require.NoError(t, err)
+	require.Equal(t, "tenant-daytona", string(output))
+}
+
+func TestSessionCloseReason(t *testing.T) {
+	ends := make(chan SessionEnd, 1)
+
+	s := newTestServer(t)
+	s.IdleTimeout = 200 * time.Millisecond
+	s.OnSessionEnd = func(end SessionEnd) {
+		ends <- end
+	}
+
+	addr := startTestServer(t, s)
+
+	t.Run("exit", func(t *testing.T) {
+		client := dialTestServer(t, addr)
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		err = session.Run("exit 3")
+		var exitErr *gossh.ExitError
+		require.ErrorAs(t, err, &exitErr)
+
+		end := <-ends
+		require.Equal(t, CloseReasonExit, end.Reason)
+		require.Equal(t, 3, end.ExitCode)
+		require.Equal(t, "exit 3", end.Command)
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		client := dialTestServer(t, addr)
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		stdin, err := session.StdinPipe()
+		require.NoError(t, err)
+		defer stdin.Close()
+
+		require.NoError(t, session.Start("cat"))
+
+		select {
+		case end := <-ends:
+			require.Equal(t, CloseReasonIdleTimeout, end.Reason)
+		case <-time.After(5 * time.Second):
+			t.Fatal("session wasn't closed")
+		}
+	})
+}
+
+func TestPtySignal(t *testing.T) {
+	addr := startTestServer(t, newTestServer(t))
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	output := make(chan string, 64)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				output <- string(buf[:n])
+			}
+			if err != nil {
+				close(output)
+				return
+			}
+		}
+	}()
+
+	waitFor := func(marker string) {
+		t.Helper()
+
+		var received strings.Builder
+		timeout := time.After(5 * time.Second)
+		for !strings.Contains(received.String(), marker) {
+			select {
+			case chunk, ok := <-output:
+				require.True(t, ok, "session closed before %q was received, got %q", marker, received.String())
+				received.WriteString(chunk)
+			case <-timeout:
+				t.Fatalf("timed out waiting for %q, got %q", marker, received.String())
+			}
+		}
+	}
+
+	require.NoError(t, session.Shell())
+
+	// The quotes keep the echoed command line from matching the markers.
+	_, err = io.WriteString(stdin, `sh -c 'trap "echo got-""usr1; exit" USR1; echo rea""dy; while :; do sleep 0.1; done'`+"\n")
+	require.NoError(t, err)
+	waitFor("ready")
+
+	require.NoError(t, session.Signal(gossh.SIGUSR1))
+	waitFor("got-usr1")
+}
+
+func TestAllowedSignals(t *testing.T) {
+	for name, pty := range map[string]bool{"command": false, "pty": true} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.AllowedSignals = []ssh.Signal{ssh.SIGINT, ssh.SIGTERM}
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			stdout, err := session.StdoutPipe()
+			require.NoError(t, err)
+			output := bufio.NewReader(stdout)
+			waitFor := func(marker string) string {
+				t.Helper()
+
+				var received strings.Builder
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for !strings.Contains(received.String(), marker) {
+						b, err := output.ReadByte()
+						if err != nil {
+							return
+						}
+						received.WriteByte(b)
+					}
+				}()
+				select {
+				case <-done:
+				case <-time.After(10 * time.Second):
+					t.Fatalf("timed out waiting for %q", marker)
+				}
+				require.Contains(t, received.String(), marker)
+				return received.String()
+			}
+
+			// The quotes keep the echoed command line from matching the markers.
+			script := `trap "echo got-""usr1" USR1; trap "echo got-""term; exit" TERM; echo rea""dy; while :; do sleep 0.1; done`
+			if pty {
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+				stdin, err := session.StdinPipe()
+				require.NoError(t, err)
+				require.NoError(t, session.Shell())
+				_, err = io.WriteString(stdin, "sh -c '"+script+"'\n")
+				require.NoError(t, err)
+			} else {
+				require.NoError(t, session.Start(script))
+			}
+			waitFor("ready")
+
+			require.NoError(t, session.Signal(gossh.SIGUSR1))
+			require.NoError(t, session.Signal(gossh.SIGTERM))
+			require.NotContains(t, waitFor("got-term"), "got-usr1")
+		})
+	}
+}
+
+func TestUserShells(t *testing.T) {
+	dir := t.TempDir()
+	fakeShell := func(name string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho \"shell="+name+" args=$*\"\n"), 0o755))
+		return path
+	}
+
+	s := newTestServer(t)
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{"alice": {ID: "alice"}, "anonymous": {}},
+	}
+	s.UserShells = map[string]string{
+		"alice":   fakeShell("alice"),
+		"daytona": fakeShell("daytona"),
+	}
+	addr := startTestServer(t, s)
+
+	for name, tc := range map[string]struct {
+		user     string
+		password string
+		command  string
+		shell    string
+	}{
+		"identity": {
+			user:     "daytona",
+			password: "alice",
+			command:  "shell=alice args=-c echo default\n",
+			shell:    "shell=alice args=\r\n",
+		},
+		"user name": {
+			user:     "daytona",
+			password: "anonymous",
+			command:  "shell=daytona args=-c echo default\n",
+			shell:    "shell=daytona args=\r\n",
+		},
+		"global shell": {
+			user:     "bob",
+			password: "anonymous",
+			command:  "default\n",
+			shell:    "default=2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := dialTestServerAs(t, addr, tc.user, gossh.Password(tc.password))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			output, err := session.Output("echo default")
+			require.NoError(t, err)
+			require.Equal(t, tc.command, string(output))
+
+			session, err = client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+			var shellOutput bytes.Buffer
+			session.Stdout = &shellOutput
+			session.Stdin = strings.NewReader("echo default=$((1+1)); exit\n")
+			require.NoError(t, session.Shell())
+			_ = runWithTimeout(t, 10*time.Second, session.Wait)
+			require.Contains(t, shellOutput.String(), tc.shell)
+		})
+	}
+}
+
+func TestVerboseCommands(t *testing.T) {
+	s := newTestServer(t)
+	s.VerboseCommands = true
+
+	addr := startTestServer(t, s)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	require.NoError(t, session.Run("echo out; echo err >&2"))
+	require.Equal(t, "out\n", stdout.String())
+	require.Equal(t, "+ echo out; echo err >&2\nerr\n", stderr.String())
+}
+
+func TestBannerFunc(t *testing.T) {
+	var calls atomic.Int32
+
+	s := newTestServer(t)
+	s.Banner = "static banner\n"
+	s.BannerFunc = func(ctx ssh.Context) string {
+		calls.Add(1)
+		return fmt.Sprintf("Welcome %s, workspace stops in 5m\n", ctx.User())
+	}
+
+	addr := startTestServer(t, s)
+
+	var banners []string
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "daytona",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		BannerCallback: func(message string) error {
+			banners = append(banners, message)
+			return nil
+		},
+		Timeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+
+		output, err := session.Output("echo hello")
+		require.NoError(t, err)
+		require.Equal(t, "hello\n", string(output))
+		session.Close()
+	}
+
+	require.Equal(t, []string{"Welcome daytona, workspace stops in 5m\n"}, banners)
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestSessionQueue(t *testing.T) {
+	// blockSession occupies the only slot until the returned func is called.
+	blockSession := func(t *testing.T, client *gossh.Client) func() {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+
+		stdin, err := session.StdinPipe()
+		require.NoError(t, err)
+		stdout, err := session.StdoutPipe()
+		require.NoError(t, err)
+		require.NoError(t, session.Start("echo started; read line"))
+
+		_, err = io.ReadFull(stdout, make([]byte, len("started\n")))
+		require.NoError(t, err)
+
+		return func() {
+			_ = stdin.Close()
+			_ = session.Wait()
+			_ = session.Close()
+		}
+	}
+
+	t.Run("admitted once a slot frees", func(t *testing.T) {
+		s := newTestServer(t)
+		s.MaxSessions = 1
+		s.SessionQueueTimeout = 5 * time.Second
+
+		client := dialTestServer(t, startTestServer(t, s))
+		release := blockSession(t, client)
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		result := make(chan error, 1)
+		var output []byte
+		go func() {
+			var err error
+			output, err = session.Output("echo queued")
+			result <- err
+		}()
+
+		select {
+		case <-result:
+			t.Fatal("queued session ran while the slot was taken")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		release()
+
+		select {
+		case err := <-result:
+			require.NoError(t, err)
+			require.Equal(t, "queued\n", string(output))
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued session wasn't admitted")
+		}
+	})
+
+	t.Run("rejected after the queue timeout", func(t *testing.T) {
+		s := newTestServer(t)
+		s.MaxSessions = 1
+		s.SessionQueueTimeout = 100 * time.Millisecond
+
+		client := dialTestServer(t, startTestServer(t, s))
+		release := blockSession(t, client)
+		defer release()
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		var stderr bytes.Buffer
+		session.Stderr = &stderr
+
+		err = session.Run("echo queued")
+		var exitErr *gossh.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 1, exitErr.ExitStatus())
+		require.Equal(t, serverBusyMessage+"\n", stderr.String())
+	})
+}
+
+func TestSessionLoad(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxSessions = 3
+	client := dialTestServer(t, startTestServer(t, s))
+
+	first, err := client.NewSession()
+	require.NoError(t, err)
+	defer first.Close()
+
+	stdin, err := first.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := first.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, first.Start("echo $DAYTONA_SESSION_LOAD; read line"))
+
+	load := make([]byte, len("1/3\n"))
+	_, err = io.ReadFull(stdout, load)
+	require.NoError(t, err)
+	require.Equal(t, "1/3\n", string(load))
+
+	second, err := client.NewSession()
+	require.NoError(t, err)
+	defer second.Close()
+
+	output, err := second.Output("echo $DAYTONA_SESSION_LOAD")
+	require.NoError(t, err)
+	require.Equal(t, "2/3\n", string(output))
+
+	_, err = io.WriteString(stdin, "\n")
+	require.NoError(t, err)
+	require.NoError(t, runWithTimeout(t, 5*time.Second, first.Wait))
+}
+
+func TestCommandTimeout(t *testing.T) {
+	for name, command := range map[string]string{
+		"terminated": "sleep 10",
+		"killed":     "trap '' TERM; sleep 10",
+	} {
+		t.Run(name, func(t *testing.T) {
+			ends := make(chan SessionEnd, 1)
+
+			s := newTestServer(t)
+			s.CommandTimeout = 200 * time.Millisecond
+			s.CommandKillGrace = 200 * time.Millisecond
+			s.OnSessionEnd = func(end SessionEnd) {
+				ends <- end
+			}
+
+			client := dialTestServer(t, startTestServer(t, s))
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			var stderr bytes.Buffer
+			session.Stderr = &stderr
+
+			started := time.Now()
+			err = runWithTimeout(t, 5*time.Second, func() error {
+				return session.Run(command)
+			})
+
+			var exitErr *gossh.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			require.Equal(t, 124, exitErr.ExitStatus())
+			require.Contains(t, stderr.String(), "Command timed out after 200ms")
+			require.Less(t, time.Since(started), 2*time.Second)
+			require.Equal(t, CloseReasonCommandTimeout, (<-ends).Reason)
+		})
+	}
+}
+
+func TestShellTermination(t *testing.T) {
+	started := filepath.Join(t.TempDir(), "started")
+	ends := make(chan SessionEnd, 1)
+
+	s := newTestServer(t)
+	s.TerminationGracePeriod = 500 * time.Millisecond
+	s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Shell())
+
+	// The shell ignores the hangup once the client is gone.
+	_, err = fmt.Fprintf(stdin, "trap '' HUP; touch %s; read line\n", started)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(started)
+		return err == nil
+	}, 15*time.Second, 10*time.Millisecond)
+
+	closed := time.Now()
+	require.NoError(t, client.Close())
+
+	select {
+	case <-ends:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shell wasn't killed")
+	}
+	require.GreaterOrEqual(t, time.Since(closed), s.TerminationGracePeriod)
+}
+
+func TestPtyTerminalModes(t *testing.T) {
+	addr := startTestServer(t, newTestServer(t))
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 200, gossh.TerminalModes{
+		gossh.VERASE: 0x08,
+		gossh.IXON:   0,
+	}))
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stdin = strings.NewReader("stty -a; exit\n")
+	require.NoError(t, session.Shell())
+	require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+	require.Contains(t, output.String(), "erase = ^H;")
+	require.Contains(t, output.String(), "-ixon")
+}
+
+// fakePTY echoes the input of the session back with a prefix and ends the
+// session on "exit", without starting the shell.
+type fakePTY struct {
+	out      *io.PipeReader
+	in       *io.PipeWriter
+	started  chan<- *fakePTY
+	startErr error
+
+	mu         sync.Mutex
+	sizes      []common.TTYSize
+	startSizes []common.TTYSize
+	signals    []syscall.Signal
+}
+
+func (p *fakePTY) Start(cmd *exec.Cmd) error {
+	if p.startErr != nil {
+		return p.startErr
+	}
+	p.mu.Lock()
+	p.startSizes = append([]common.TTYSize(nil), p.sizes...)
+	p.mu.Unlock()
+	p.started <- p
+	return nil
+}
+
+func (p *fakePTY) Read(b []byte) (int, error) {
+	return p.out.Read(b)
+}
+
+func (p *fakePTY) Write(b []byte) (int, error) {
+	if strings.Contains(string(b), "exit") {
+		return len(b), p.in.Close()
+	}
+
+	if _, err := fmt.Fprintf(p.in, "fake: %s", b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (p *fakePTY) Close() error {
+	p.in.Close()
+	return p.out.Close()
+}
+
+func (p *fakePTY) Resize(size common.TTYSize) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sizes = append(p.sizes, size)
+	return nil
+}
+
+func (p *fakePTY) Signal(sig syscall.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.signals = append(p.signals, sig)
+	return nil
+}
+
+type fakePTYFactory struct {
+	started  chan *fakePTY
+	err      error
+	startErr error
+}
+
+func (f *fakePTYFactory) Open(modes gossh.TerminalModes) (common.PTY, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	out, in := io.Pipe()
+	return &fakePTY{out: out, in: in, started: f.started, startErr: f.startErr}, nil
+}
+
+func TestExitStatus(t *testing.T) {
+	for name, tc := range map[string]struct {
+		configure func(s *Server)
+		pty       bool
+		command   string
+		stdin     string
+		expected  int
+		reason    CloseReason
+	}{
+		"command succeeds": {command: "true", expected: 0, reason: CloseReasonExit},
+		"command fails":    {command: "exit 7", expected: 7, reason: CloseReasonExit},
+		"command doesn't start": {
+			configure: func(s *Server) { s.CommandWrapper = []string{"/nonexistent/wrapper"} },
+			command:   "true",
+			expected:  1,
+			reason:    CloseReasonError,
+		},
+		"shell exits":   {pty: true, stdin: "exit 3\n", expected: 3, reason: CloseReasonExit},
+		"shell exits 0": {pty: true, stdin: "exit\n", expected: 0, reason: CloseReasonExit},
+		"shell doesn't start": {
+			configure: func(s *Server) { s.PTYFactory = &fakePTYFactory{startErr: errors.New("no shell")} },
+			pty:       true,
+			expected:  1,
+			reason:    CloseReasonError,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ends := make(chan SessionEnd, 1)
+			s := newTestServer(t)
+			s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+			if tc.configure != nil {
+				tc.configure(s)
+			}
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			if tc.pty {
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+				session.Stdin = strings.NewReader(tc.stdin)
+				require.NoError(t, session.Shell())
+			} else {
+				require.NoError(t, session.Start(tc.command))
+			}
+
+			err = runWithTimeout(t, 10*time.Second, session.Wait)
+			if tc.expected == 0 {
+				require.NoError(t, err)
+			} else {
+				var exitErr *gossh.ExitError
+				require.ErrorAs(t, err, &exitErr)
+				require.Equal(t, tc.expected, exitErr.ExitStatus())
+			}
+
+			select {
+			case end := <-ends:
+				require.Equal(t, tc.reason, end.Reason)
+			case <-time.After(5 * time.Second):
+				t.Fatal("session end was not reported")
+			}
+		})
+	}
+}
+
+func TestPTYFactory(t *testing.T) {
+	factory := &fakePTYFactory{started: make(chan *fakePTY, 1)}
+	server := newTestServer(t)
+	server.PTYFactory = factory
+	addr := startTestServer(t, server)
+	client := dialTestServer(t, addr)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Shell())
+
+	var pty *fakePTY
+	select {
+	case pty = <-factory.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the PTY")
+	}
+
+	require.NoError(t, session.WindowChange(50, 100))
+	require.NoError(t, session.Signal(gossh.SIGINT))
+	require.Eventually(t, func() bool {
+		pty.mu.Lock()
+		defer pty.mu.Unlock()
+
+		return len(pty.sizes) > 0 && pty.sizes[len(pty.sizes)-1] == common.TTYSize{Height: 50, Width: 100} &&
+			len(pty.signals) == 1 && pty.signals[0] == syscall.SIGINT
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = io.WriteString(stdin, "hello")
+	require.NoError(t, err)
+	echo := make([]byte, len("fake: hello"))
+	_, err = io.ReadFull(stdout, echo)
+	require.NoError(t, err)
+	require.Equal(t, "fake: hello", string(echo))
+
+	_, err = io.WriteString(stdin, "exit")
+	require.NoError(t, err)
+	require.NoError(t, runWithTimeout(t, 5*time.Second, session.Wait))
+}
+
+func TestPTYResizeBeforeShell(t *testing.T) {
+	factory := &fakePTYFactory{started: make(chan *fakePTY, 1)}
+	release := make(chan struct{})
+	server := newTestServer(t)
+	server.PTYFactory = factory
+	server.ReadinessCheck = func(ctx ssh.Context) error {
+		<-release
+		return nil
+	}
+	client := dialTestServer(t, startTestServer(t, server))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Shell())
+
+	// The shell waits for readiness, but resizes are still accepted.
+	for _, size := range []common.TTYSize{{Height: 50, Width: 100}, {Height: 60, Width: 120}, {Height: 70, Width: 140}} {
+		require.NoError(t, session.WindowChange(size.Height, size.Width))
+	}
+	// Window changes aren't replied to, but requests are handled in order.
+	require.NoError(t, runWithTimeout(t, 5*time.Second, func() error {
+		_, err := session.SendRequest("ping@example.com", true, nil)
+		return err
+	}))
+	close(release)
+
+	var pty *fakePTY
+	select {
+	case pty = <-factory.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the PTY")
+	}
+
+	// The shell started with the latest size.
+	pty.mu.Lock()
+	require.Equal(t, []common.TTYSize{{Height: 70, Width: 140}}, pty.startSizes)
+	pty.mu.Unlock()
+
+	_, err = io.WriteString(stdin, "exit")
+	require.NoError(t, err)
+	require.NoError(t, runWithTimeout(t, 5*time.Second, session.Wait))
+}
+
+func TestPtyBreak(t *testing.T) {
+	for name, tc := range map[string]struct {
+		breakSignal syscall.Signal
+		want        syscall.Signal
+	}{
+		"default":    {want: syscall.SIGINT},
+		"configured": {breakSignal: syscall.SIGQUIT, want: syscall.SIGQUIT},
+	} {
+		t.Run(name, func(t *testing.T) {
+			factory := &fakePTYFactory{started: make(chan *fakePTY, 1)}
+			server := newTestServer(t)
+			server.PTYFactory = factory
+			server.BreakSignal = tc.breakSignal
+			addr := startTestServer(t, server)
+			client := dialTestServer(t, addr)
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+			stdin, err := session.StdinPipe()
+			require.NoError(t, err)
+			require.NoError(t, session.Shell())
+
+			var pty *fakePTY
+			select {
+			case pty = <-factory.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the PTY")
+			}
+
+			// The payload is the break length in milliseconds, RFC 4335.
+			ok, err := session.SendRequest("break", true, gossh.Marshal(struct{ Length uint32 }{500}))
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Eventually(t, func() bool {
+				pty.mu.Lock()
+				defer pty.mu.Unlock()
+
+				return len(pty.signals) == 1 && pty.signals[0] == tc.want
+			}, 5*time.Second, 10*time.Millisecond)
+
+			_, err = io.WriteString(stdin, "exit")
+			require.NoError(t, err)
+			require.NoError(t, runWithTimeout(t, 5*time.Second, session.Wait))
+		})
+	}
+}
+
+func TestTranslateCRLF(t *testing.T) {
+	for name, tc := range map[string]struct {
+		translate bool
+		want      string
+	}{
+		"off": {want: "a\r\nb\r\n"},
+		"on":  {translate: true, want: "a\nb\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.TranslateCRLF = tc.translate
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			session.Stdin = strings.NewReader("a\r\nb\r\n")
+			output, err := session.Output("cat")
+			require.NoError(t, err)
+			require.Equal(t, tc.want, string(output))
+		})
+	}
+}
+
+func TestSessionNice(t *testing.T) {
+	s := newTestServer(t)
+	s.InteractiveNice = 3
+	s.BatchNice = 7
+	client := dialTestServer(t, startTestServer(t, s))
+
+	t.Run("batch", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("nice")
+		require.NoError(t, err)
+		require.Equal(t, "7\n", string(output))
+	})
+
+	t.Run("interactive", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+		var output bytes.Buffer
+		session.Stdout = &output
+		// The echoed command line doesn't match "nice=3".
+		session.Stdin = strings.NewReader("echo nice=$(nice); exit\n")
+		require.NoError(t, session.Shell())
+		require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+		require.Contains(t, output.String(), "nice=3")
+	})
+}
+
+func TestWelcomeCommand(t *testing.T) {
+	for name, tc := range map[string]struct {
+		command  string
+		expected string
+	}{
+		"output":  {command: "echo welcome-to-the-workspace", expected: "welcome-to-the-workspace\r\n"},
+		"timeout": {command: "sleep 30; echo never-shown"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.WelcomeCommand = tc.command
+			s.WelcomeTimeout = 500 * time.Millisecond
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+			var output bytes.Buffer
+			session.Stdout = &output
+			session.Stdin = strings.NewReader("echo shell=$((6*7)); exit\n")
+			require.NoError(t, session.Shell())
+			require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+			shell := strings.Index(output.String(), "shell=42")
+			require.GreaterOrEqual(t, shell, 0)
+			require.NotContains(t, output.String(), "never-shown")
+			if tc.expected != "" {
+				welcome := strings.Index(output.String(), tc.expected)
+				require.GreaterOrEqual(t, welcome, 0)
+				require.Less(t, welcome, shell)
+			}
+		})
+	}
+}
+
+func TestReadOnlyShell(t *testing.T) {
+	s := newTestServer(t)
+	s.ReadOnly = true
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stdin = strings.NewReader("echo read-only=$DAYTONA_READ_ONLY; exit\n")
+	require.NoError(t, session.Shell())
+	require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+	require.True(t, strings.HasPrefix(output.String(), readOnlyNotice+"\r\n"))
+	require.Contains(t, output.String(), "read-only=1\r\n")
+}
+
+func TestPTYEnv(t *testing.T) {
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+	t.Run("command", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("echo pty=$DAYTONA_SSH_PTY")
+		require.NoError(t, err)
+		require.Equal(t, "pty=0\n", string(output))
+	})
+
+	t.Run("shell", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+		var output bytes.Buffer
+		session.Stdout = &output
+		session.Stdin = strings.NewReader("echo pty=$DAYTONA_SSH_PTY; exit\n")
+		require.NoError(t, session.Shell())
+		require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+		require.Contains(t, output.String(), "pty=1\r\n")
+	})
+}
+
+func TestIsolateTmp(t *testing.T) {
+	s := newTestServer(t)
+	s.IsolateTmp = true
+	client := dialTestServer(t, startTestServer(t, s))
+
+	run := func(command string) string {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		var stdout bytes.Buffer
+		session.Stdout = &stdout
+		_ = session.Run(command)
+
+		dir := strings.TrimSpace(stdout.String())
+		require.NotEmpty(t, dir)
+		return dir
+	}
+
+	first := run(`touch "$TMPDIR/file" && echo $TMPDIR`)
+	// The directory is removed even if the command is killed.
+	second := run(`echo $TMPDIR; mkdir "$TMPDIR/sub"; kill -9 $$`)
+	require.NotEqual(t, first, second)
+
+	for _, dir := range []string{first, second} {
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(dir)
+			return errors.Is(err, os.ErrNotExist)
+		}, 5*time.Second, 10*time.Millisecond, dir)
+	}
+}
+
+func TestPtyRequestFailure(t *testing.T) {
+	server := newTestServer(t)
+	server.PTYFactory = &fakePTYFactory{err: errors.New("out of PTYs")}
+	client := dialTestServer(t, startTestServer(t, server))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.Error(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+	// The session is still usable without a PTY.
+	output, err := session.Output("echo no-pty")
+	require.NoError(t, err)
+	require.Equal(t, "no-pty\n", string(output))
+}
+
+func TestReadinessCheck(t *testing.T) {
+	t.Run("delayed", func(t *testing.T) {
+		s := newTestServer(t)
+		s.ReadinessMessage = "Waiting for the workspace"
+		s.ReadinessCheck = func(ctx ssh.Context) error {
+			time.Sleep(1500 * time.Millisecond)
+			return nil
+		}
+		client := dialTestServer(t, startTestServer(t, s))
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		var stderr bytes.Buffer
+		session.Stderr = &stderr
+		output, err := session.Output("echo ready")
+		require.NoError(t, err)
+		require.Equal(t, "ready\n", string(output))
+		require.Equal(t, "Waiting for the workspace.\n", stderr.String())
+	})
+
+	for name, tc := range map[string]struct {
+		check   func(ctx ssh.Context) error
+		message string
+	}{
+		"failed": {
+			check: func(ctx ssh.Context) error {
+				return errors.New("volume not mounted")
+			},
+			message: "Workspace is not ready: volume not mounted\n",
+		},
+		"timed out": {
+			check: func(ctx ssh.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			message: "Workspace is not ready: not ready after 100ms\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var ended atomic.Value
+			s := newTestServer(t)
+			s.ReadinessCheck = tc.check
+			s.ReadinessTimeout = 100 * time.Millisecond
+			s.OnSessionEnd = func(end SessionEnd) {
+				ended.Store(end.Reason)
+			}
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			var stdout, stderr bytes.Buffer
+			session.Stdout = &stdout
+			session.Stderr = &stderr
+			err = session.Run("echo ready")
+			var exitErr *gossh.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			require.Equal(t, 1, exitErr.ExitStatus())
+			require.Empty(t, stdout.String())
+			require.Equal(t, tc.message, stderr.String())
+			require.Eventually(t, func() bool {
+				return ended.Load() == CloseReasonNotReady
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestSlowCommand(t *testing.T) {
+	slow := make(chan SlowCommand, 2)
+	s := newTestServer(t)
+	s.SlowCommandThreshold = 300 * time.Millisecond
+	s.OnSlowCommand = func(command SlowCommand) {
+		slow <- command
+	}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	for _, command := range []string{"true", "sleep 0.5"} {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		require.NoError(t, session.Run(command))
+		session.Close()
+	}
+
+	select {
+	case command := <-slow:
+		require.Equal(t, "sleep 0.5", command.Command)
+		require.Equal(t, "daytona", command.User)
+		require.GreaterOrEqual(t, command.Duration, 500*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow command wasn't reported")
+	}
+	require.Empty(t, slow, "only the slow command is reported")
+}
+
+func TestBlankAndOversizedCommands(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxCommandLength = 64
+	client := dialTestServer(t, startTestServer(t, s))
+
+	t.Run("whitespace", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output(" \t ")
+		require.NoError(t, err)
+		require.Empty(t, output)
+	})
+
+	t.Run("whitespace with a PTY", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+		var output bytes.Buffer
+		session.Stdout = &output
+		// The echoed command line doesn't match "interactive=yes".
+		session.Stdin = strings.NewReader("echo interactive=$(test -t 0 && echo yes); exit\n")
+		require.NoError(t, session.Start("   "))
+		require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+		require.Contains(t, output.String(), "interactive=yes")
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		var stdout, stderr bytes.Buffer
+		session.Stdout = &stdout
+		session.Stderr = &stderr
+		err = session.Run("echo " + strings.Repeat("x", 64))
+		var exitErr *gossh.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 1, exitErr.ExitStatus())
+		require.Empty(t, stdout.String())
+		require.Equal(t, "Command exceeds the maximum length of 64 bytes\n", stderr.String())
+	})
+
+	t.Run("at the limit", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("echo " + strings.Repeat("x", 59))
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("x", 59)+"\n", string(output))
+	})
+}
+
+// processGone reports whether the process with the given ID exited. Zombies
+// count as exited, nothing might reap orphans in a container.
+func processGone(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] == "Z"
+}
+
+func TestCommandCanceledOnDisconnect(t *testing.T) {
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	// The background process doesn't hold the session output open, so only
+	// the process group kill ends it.
+	require.NoError(t, session.Start("sleep 30 >/dev/null & echo $$ $!; wait"))
+
+	var shell, background int
+	_, err = fmt.Fscan(stdout, &shell, &background)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Close())
+
+	require.Eventually(t, func() bool {
+		return processGone(shell) && processGone(background)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCommandCanceledOnSessionClose(t *testing.T) {
+	for name, pty := range map[string]bool{"command": false, "pty": true} {
+		t.Run(name, func(t *testing.T) {
+			client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+
+			if pty {
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+			}
+			stdout, err := session.StdoutPipe()
+			require.NoError(t, err)
+			require.NoError(t, session.Start("sleep 30 >/dev/null & echo $$ $!; wait"))
+
+			var shell, background int
+			_, err = fmt.Fscan(stdout, &shell, &background)
+			require.NoError(t, err)
+
+			// The connection stays open for other sessions.
+			require.NoError(t, session.Close())
+
+			require.Eventually(t, func() bool {
+				return processGone(shell) && processGone(background)
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestCommandKillsProcessesIgnoringSIGTERM(t *testing.T) {
+	s := newTestServer(t)
+	s.CommandKillGrace = time.Second
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	// The shell exits on SIGTERM, the background process outlives it and
+	// doesn't hold the session open either.
+	require.NoError(t, session.Start(`sh -c 'trap "" TERM; sleep 30' </dev/null >/dev/null 2>&1 & echo $!; wait`))
+
+	var background int
+	_, err = fmt.Fscan(stdout, &background)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Close())
+
+	require.Eventually(t, func() bool {
+		return processGone(background)
+	}, 5*time.Second, 10*time.Millisecond)
+}
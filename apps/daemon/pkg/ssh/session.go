@@ -0,0 +1,269 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CloseReason describes why a session or connection ended.
+type CloseReason string
+
+const (
+	// CloseReasonExit is used for sessions that ended on their own, e.g. the
+	// command exited or the client closed the SFTP subsystem.
+	CloseReasonExit             CloseReason = "exit"
+	CloseReasonClientDisconnect CloseReason = "client_disconnect"
+	CloseReasonIdleTimeout      CloseReason = "idle_timeout"
+	CloseReasonMaxDuration      CloseReason = "max_duration"
+	CloseReasonServerShutdown   CloseReason = "server_shutdown"
+	CloseReasonPolicyDenied     CloseReason = "policy_denied"
+	CloseReasonCommandTimeout   CloseReason = "command_timeout"
+	CloseReasonNotReady         CloseReason = "not_ready"
+	CloseReasonBackpressure     CloseReason = "backpressure"
+	CloseReasonError            CloseReason = "error"
+)
+
+// SessionEnd is passed to the OnSessionEnd callback once a session ended.
+type SessionEnd struct {
+	SessionID string
+	User      string
+	RemoteIP  net.IP
+	// Tenant is the tenant the connection is tagged with, if any.
+	Tenant    string
+	Subsystem string
+	Command   string
+	ExitCode  int
+	Reason    CloseReason
+	Duration  time.Duration
+	// BytesOut counts the bytes written to stdout and stderr of the session,
+	// LinesOut the lines among them for shells and commands.
+	BytesOut int64
+	LinesOut int64
+	// StderrTail holds the last StderrTailLines lines a non-PTY command wrote
+	// to stderr, if it exited nonzero.
+	StderrTail string
+}
+
+// trackedSession records how a session ended.
+type trackedSession struct {
+	ssh.Session
+	// id is the ID the session is listed with in Sessions.
+	id string
+
+	mu       sync.Mutex
+	exited   bool
+	exitCode int
+	reason   CloseReason
+
+	transcript *transcript
+	log        *sessionLog
+
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+
+	// attach is set for PTY sessions admins may attach to.
+	attach *ptyAttach
+	// stderrTail is set for non-PTY commands if StderrTailLines is set.
+	stderrTail *stderrTail
+	// output counts the lines of shells and commands.
+	output *outputLines
+}
+
+func (t *trackedSession) Read(p []byte) (int, error) {
+	n, err := t.Session.Read(p)
+	t.bytesIn.Add(int64(n))
+	if t.log != nil {
+		t.log.record(sessionLogIn, p[:n])
+	}
+	return n, err
+}
+
+func (t *trackedSession) Write(p []byte) (int, error) {
+	return t.writeLimited(t.write, p)
+}
+
+func (t *trackedSession) write(p []byte) (int, error) {
+	n, err := t.Session.Write(p)
+	t.bytesOut.Add(int64(n))
+	if t.transcript != nil {
+		_, _ = t.transcript.Write(p[:n])
+	}
+	if t.log != nil {
+		t.log.record(sessionLogOut, p[:n])
+	}
+	return n, err
+}
+
+func (t *trackedSession) Stderr() io.ReadWriter {
+	var stderr io.ReadWriter = &countingWriter{ReadWriter: t.Session.Stderr(), n: &t.bytesOut}
+	if t.transcript != nil {
+		stderr = &transcriptWriter{ReadWriter: stderr, transcript: t.transcript}
+	}
+	if t.log != nil {
+		stderr = &sessionLogWriter{ReadWriter: stderr, log: t.log, direction: sessionLogErr}
+	}
+	if t.output != nil {
+		stderr = &limitedWriter{ReadWriter: stderr, session: t}
+	}
+	return stderr
+}
+
+func (t *trackedSession) Exit(code int) error {
+	t.mu.Lock()
+	if !t.exited {
+		t.exited = true
+		t.exitCode = code
+		t.reason = t.resolveReasonLocked()
+	}
+	t.mu.Unlock()
+
+	return t.Session.Exit(code)
+}
+
+// setReason overrides the reason the session ends with. It has no effect once
+// the session exited.
+func (t *trackedSession) setReason(reason CloseReason) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.exited {
+		t.reason = reason
+	}
+}
+
+// resolveReasonLocked prefers an explicitly set reason, then the reason the
+// connection was closed for.
+func (t *trackedSession) resolveReasonLocked() CloseReason {
+	if t.reason != "" {
+		return t.reason
+	}
+
+	if reason := connCloseReason(t.Context()); reason != "" {
+		return reason
+	}
+
+	return CloseReasonExit
+}
+
+// end marks the session as exited with status 0 if the handler returned
+// without calling Exit, which is what gliderlabs/ssh sends in that case.
+func (t *trackedSession) end() (int, CloseReason) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.exited {
+		t.exited = true
+		t.reason = t.resolveReasonLocked()
+	}
+
+	return t.exitCode, t.reason
+}
+
+// exitOnFailure sends exit status 1 if the handler of a shell or command
+// session returned, or panicked, without calling Exit. gliderlabs/ssh would
+// send 0, and clients couldn't tell failures to start from commands that
+// succeeded.
+func exitOnFailure(session ssh.Session) {
+	t, ok := session.(*trackedSession)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	exited := t.exited
+	t.mu.Unlock()
+	if exited {
+		return
+	}
+
+	if !connClosed(t.Context()) {
+		t.setReason(CloseReasonError)
+	}
+	_ = t.Exit(1)
+}
+
+func setCloseReason(session ssh.Session, reason CloseReason) {
+	if t, ok := session.(*trackedSession); ok {
+		t.setReason(reason)
+	}
+}
+
+// trackSession reports the outcome of every session handled by handler.
+func (s *Server) trackSession(handler func(ssh.Session)) func(ssh.Session) {
+	return func(session ssh.Session) {
+		started := time.Now()
+		tracked := &trackedSession{Session: session, id: s.sessions.newID()}
+		span := s.startSessionSpan(session)
+		s.auditSessionStart(tracked)
+
+		if c, ok := session.Context().Value(contextKeyConnState).(*trackedConn); ok {
+			c.sessions.Add(1)
+		}
+		s.sessionStarted()
+		defer s.sessionEnded()
+
+		// Subsystems like SFTP speak binary protocols not worth recording.
+		if session.Subsystem() == "" {
+			tracked.transcript = s.startTranscript(tracked.id)
+			tracked.log = s.startSessionLog(session.Context().SessionID())
+			tracked.output = &outputLines{max: int64(s.MaxOutputLines)}
+		}
+
+		handler(tracked)
+
+		if tracked.transcript != nil {
+			s.endTranscript(tracked.transcript)
+		}
+		if tracked.log != nil {
+			_ = tracked.log.Close()
+		}
+
+		exitCode, reason := tracked.end()
+		end := SessionEnd{
+			SessionID: session.Context().SessionID(),
+			User:      session.User(),
+			RemoteIP:  remoteIP(session.Context()),
+			Tenant:    tenantOf(session.Context()),
+			Subsystem: session.Subsystem(),
+			Command:   session.RawCommand(),
+			ExitCode:  exitCode,
+			Reason:    reason,
+			Duration:  time.Since(started),
+			BytesOut:  tracked.bytesOut.Load(),
+		}
+		if tracked.output != nil {
+			end.LinesOut = tracked.output.count()
+		}
+		if exitCode != 0 && tracked.stderrTail != nil {
+			end.StderrTail = tracked.stderrTail.String()
+		}
+
+		log.WithFields(log.Fields{
+			"session":   end.SessionID,
+			"user":      end.User,
+			"remoteIP":  end.RemoteIP.String(),
+			"tenant":    end.Tenant,
+			"subsystem": end.Subsystem,
+			"exitCode":  end.ExitCode,
+			"reason":    end.Reason,
+			"duration":  end.Duration,
+		}).Info("SSH session closed")
+
+		endSessionSpan(span, end, tracked.bytesIn.Load(), tracked.bytesOut.Load())
+		s.auditSessionEnd(tracked, end, tracked.bytesIn.Load(), tracked.bytesOut.Load())
+
+		if s.OnSessionEnd != nil {
+			s.OnSessionEnd(end)
+		}
+	}
+}
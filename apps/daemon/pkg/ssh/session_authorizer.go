@@ -0,0 +1,41 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// authorizeSession rejects the sessions of connections that didn't unlock
+// the workspace, the sessions selecting a workspace the identity of the
+// client isn't bound to and the sessions SessionAuthorizer denies before they
+// are handled. The connection stays open for other channels.
+func (s *Server) authorizeSession(handler func(ssh.Session)) func(ssh.Session) {
+	if s.SessionAuthorizer == nil && s.UnlockFunc == nil && s.WorkspaceResolver == nil {
+		return handler
+	}
+
+	return func(session ssh.Session) {
+		err := s.checkUnlocked(session.Context())
+		if err == nil {
+			err = s.checkWorkspace(session)
+		}
+		if err == nil && s.SessionAuthorizer != nil {
+			err = s.SessionAuthorizer(session.Context(), session.Subsystem(), session.RawCommand())
+		}
+		if err != nil {
+			log.Warnf("Denying session %s of user %s: %v", session.Context().SessionID(), session.User(), err)
+			_, _ = fmt.Fprintln(session.Stderr(), err)
+			setCloseReason(session, CloseReasonPolicyDenied)
+			_ = session.Exit(1)
+			return
+		}
+
+		handler(session)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build linux
+
+package ssh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// sessionLeaderScript prints whether the shell leads its own session and has
+// a controlling terminal, read from /proc since ps isn't always installed.
+const sessionLeaderScript = `read -r pid comm state ppid pgrp sid tty rest < /proc/$$/stat; echo "leader=$((pid == sid)),ctty=$((tty != 0))"`
+
+func TestSessionLeader(t *testing.T) {
+	client := dialTestServer(t, startTestServer(t, newTestServer(t)))
+
+	t.Run("command", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		// The command has no controlling terminal even if the test runs in
+		// one, like the daemon under systemd.
+		output, err := session.CombinedOutput(sessionLeaderScript)
+		require.NoError(t, err, string(output))
+		require.Equal(t, "leader=1,ctty=0\n", string(output))
+	})
+
+	t.Run("pty", func(t *testing.T) {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+		var output bytes.Buffer
+		session.Stdout = &output
+		session.Stdin = strings.NewReader(sessionLeaderScript + "; exit\n")
+		require.NoError(t, session.Shell())
+		require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+		require.Contains(t, output.String(), "leader=1,ctty=1\r\n")
+	})
+}
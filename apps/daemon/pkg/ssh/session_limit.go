@@ -0,0 +1,84 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	serverBusyMessage = "Server busy: too many active sessions, try again later."
+	sftpBusyMessage   = "Server busy: too many active SFTP sessions, try again later."
+)
+
+// sessionLimiter returns a wrapper enforcing MaxSessions on the sessions of
+// all handlers it wraps together.
+func (s *Server) sessionLimiter() func(handler func(ssh.Session)) func(ssh.Session) {
+	if s.MaxSessions <= 0 {
+		return func(handler func(ssh.Session)) func(ssh.Session) {
+			return handler
+		}
+	}
+
+	slots := make(chan struct{}, s.MaxSessions)
+	s.sessionSlots = slots
+
+	return func(handler func(ssh.Session)) func(ssh.Session) {
+		return func(session ssh.Session) {
+			if !s.acquireSessionSlot(session, slots) {
+				log.Warnf("Rejecting session %s of user %s, %d sessions are active", session.Context().SessionID(), session.User(), s.MaxSessions)
+				_, _ = fmt.Fprintln(session.Stderr(), serverBusyMessage)
+				setCloseReason(session, CloseReasonPolicyDenied)
+				_ = session.Exit(1)
+				return
+			}
+			defer func() {
+				<-slots
+			}()
+
+			handler(session)
+		}
+	}
+}
+
+// acquireSessionSlot waits up to SessionQueueTimeout for a free slot. It gives
+// up early if the client disconnects while queued.
+func (s *Server) acquireSessionSlot(session ssh.Session, slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if s.SessionQueueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(s.SessionQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-session.Context().Done():
+		return false
+	}
+}
+
+// sessionLoadEnv returns the DAYTONA_SESSION_LOAD variable letting clients
+// warn users of a server near capacity.
+func (s *Server) sessionLoadEnv() []string {
+	if s.sessionSlots == nil {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("DAYTONA_SESSION_LOAD=%d/%d", len(s.sessionSlots), cap(s.sessionSlots))}
+}
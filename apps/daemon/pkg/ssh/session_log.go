@@ -0,0 +1,176 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultSessionLogMaxSize = 10 << 20
+
+// minRedactedLength keeps short values like "1" or "on" from being redacted
+// all over the log.
+const minRedactedLength = 4
+
+const (
+	sessionLogIn  = "in"
+	sessionLogOut = "out"
+	sessionLogErr = "err"
+)
+
+var sessionLogSeq atomic.Uint64
+
+// sessionLog records the input and output of a session, one line per read or
+// write with a timestamp, the direction and the quoted data. Once the file
+// exceeds maxSize it's rotated to a single backup with the suffix ".1".
+type sessionLog struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxSize  int64
+	redactor *strings.Replacer
+	// sanitizers keep the state of every direction, since escape sequences
+	// may be split across writes.
+	sanitizers map[string]*sanitizer
+}
+
+// startSessionLog opens the log of a new session of the connection with the
+// given id, or returns nil if SessionLogDir isn't set.
+func (s *Server) startSessionLog(id string) *sessionLog {
+	if s.SessionLogDir == "" {
+		return nil
+	}
+
+	maxSize := s.SessionLogMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultSessionLogMaxSize
+	}
+
+	l := &sessionLog{
+		path:     filepath.Join(s.SessionLogDir, fmt.Sprintf("%s-%d.log", id, sessionLogSeq.Add(1))),
+		maxSize:  maxSize,
+		redactor: s.sessionLogRedactor(),
+	}
+	if s.SanitizeRecordings != SanitizeRaw {
+		l.sanitizers = map[string]*sanitizer{
+			sessionLogIn:  newSanitizer(s.SanitizeRecordings),
+			sessionLogOut: newSanitizer(s.SanitizeRecordings),
+			sessionLogErr: newSanitizer(s.SanitizeRecordings),
+		}
+	}
+	if err := l.open(); err != nil {
+		log.Warnf("Failed to open session log: %v", err)
+		return nil
+	}
+
+	return l
+}
+
+// sessionLogRedactor masks the values of the EnvFile variables, which often
+// hold secrets. Values split across two reads or writes aren't caught.
+func (s *Server) sessionLogRedactor() *strings.Replacer {
+	var pairs []string
+	for _, env := range s.sessionEnv() {
+		_, value, _ := strings.Cut(env, "=")
+		if len(value) >= minRedactedLength {
+			pairs = append(pairs, value, "***")
+		}
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+func (l *sessionLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	l.file = f
+	l.size = 0
+	return nil
+}
+
+func (l *sessionLog) record(direction string, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+
+	p = l.sanitizers[direction].sanitize(p)
+	if len(p) == 0 {
+		return
+	}
+	line := fmt.Sprintf("%s %s %q\n", time.Now().UTC().Format(time.RFC3339Nano), direction, l.redactor.Replace(string(p)))
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotateLocked(); err != nil {
+			log.Warnf("Failed to rotate session log %s: %v", l.path, err)
+			return
+		}
+	}
+
+	n, err := io.WriteString(l.file, line)
+	l.size += int64(n)
+	if err != nil {
+		log.Warnf("Failed to write session log %s: %v", l.path, err)
+	}
+}
+
+func (l *sessionLog) rotateLocked() error {
+	_ = l.file.Close()
+	l.file = nil
+
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+
+	return l.open()
+}
+
+func (l *sessionLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// sessionLogWriter records writes to the stream of a session.
+type sessionLogWriter struct {
+	io.ReadWriter
+	log       *sessionLog
+	direction string
+}
+
+func (w *sessionLogWriter) Write(p []byte) (int, error) {
+	n, err := w.ReadWriter.Write(p)
+	w.log.record(w.direction, p[:n])
+	return n, err
+}
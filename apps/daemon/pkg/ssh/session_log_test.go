@@ -0,0 +1,76 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readSessionLogs(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	logs := map[string]string{}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		logs[entry.Name()] = string(data)
+	}
+
+	return logs
+}
+
+func TestSessionLog(t *testing.T) {
+	s := newTestServer(t)
+	s.SessionLogDir = t.TempDir()
+	s.EnvFile = filepath.Join(t.TempDir(), "env")
+	require.NoError(t, os.WriteFile(s.EnvFile, []byte("SECRET=hunter22\n"), 0o600))
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	session.Stdin = strings.NewReader("hello")
+	require.NoError(t, session.Run("cat; echo $SECRET >&2"))
+	session.Close()
+
+	logs := readSessionLogs(t, s.SessionLogDir)
+	require.Len(t, logs, 1)
+	for name, content := range logs {
+		require.True(t, strings.HasSuffix(name, ".log"))
+		require.Contains(t, content, ` in "hello"`)
+		require.Contains(t, content, ` out "hello"`)
+		require.Contains(t, content, ` err "***\n"`)
+		require.NotContains(t, content, "hunter22")
+	}
+}
+
+func TestSessionLogRotation(t *testing.T) {
+	s := newTestServer(t)
+	s.SessionLogDir = t.TempDir()
+	s.SessionLogMaxSize = 256
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, session.Run("for i in 1 2 3 4 5 6 7 8 9 10; do echo line-$i; sleep 0.01; done"))
+	session.Close()
+
+	logs := readSessionLogs(t, s.SessionLogDir)
+	require.Len(t, logs, 2)
+	for name, content := range logs {
+		require.LessOrEqual(t, len(content), 256, name)
+		if strings.HasSuffix(name, ".log") {
+			require.Contains(t, content, `out "line-10\n"`)
+		} else {
+			require.True(t, strings.HasSuffix(name, ".log.1"))
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sessionTmpDir creates the temporary directory of a session if IsolateTmp is
+// set and returns the TMPDIR variable pointing to it. cleanup removes it with
+// everything the session left behind and must be called once it ended.
+func (s *Server) sessionTmpDir() (env []string, cleanup func(), err error) {
+	if !s.IsolateTmp {
+		return nil, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "daytona-session-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	cleanup = func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("Failed to remove temporary directory %s: %v", dir, err)
+		}
+	}
+
+	return []string{fmt.Sprintf("TMPDIR=%s", dir)}, cleanup, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// SessionInfo describes an active session channel. Clients like VS Code open
+// an SFTP session next to their shells on the same connection, all of which
+// share the SessionID of the connection but have IDs of their own.
+type SessionInfo struct {
+	ID        string
+	SessionID string
+	User      string
+	// Subsystem is "sftp" or another subsystem, or empty for shell and
+	// command sessions.
+	Subsystem string
+	Command   string
+	PTY       bool
+	Started   time.Time
+}
+
+type sessions struct {
+	mu      sync.Mutex
+	nextID  uint64
+	entries map[string]*sessionEntry
+}
+
+type sessionEntry struct {
+	info SessionInfo
+	// attach lets admins attach to PTY sessions if SessionAttach is set.
+	attach *ptyAttach
+	// terminal is the session of shells and commands with a PTY, which
+	// Broadcast writes to.
+	terminal ssh.Session
+}
+
+// Sessions lists the active sessions of all connections, oldest first.
+// Sessions queued for a slot under MaxSessions aren't listed until they run.
+func (s *Server) Sessions() []SessionInfo {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(s.sessions.entries))
+	for _, entry := range s.sessions.entries {
+		infos = append(infos, entry.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		a, _ := strconv.ParseUint(infos[i].ID, 10, 64)
+		b, _ := strconv.ParseUint(infos[j].ID, 10, 64)
+		return a < b
+	})
+
+	return infos
+}
+
+// registerSession lists the sessions handled by handler in Sessions while
+// they run.
+func (s *Server) registerSession(handler func(ssh.Session)) func(ssh.Session) {
+	return func(session ssh.Session) {
+		_, _, isPty := session.Pty()
+		info := SessionInfo{
+			SessionID: session.Context().SessionID(),
+			User:      session.User(),
+			Subsystem: session.Subsystem(),
+			Command:   session.RawCommand(),
+			PTY:       isPty,
+			Started:   time.Now(),
+		}
+
+		entry := &sessionEntry{info: info}
+		if isPty && session.Subsystem() == "" {
+			entry.terminal = session
+			if s.SessionAttach {
+				entry.attach = newPTYAttach()
+				if tracked, ok := session.(*trackedSession); ok {
+					tracked.attach = entry.attach
+				}
+			}
+		}
+
+		// Sessions are tracked before, which assigns the ID audit records
+		// refer to them with.
+		if tracked, ok := session.(*trackedSession); ok {
+			entry.info.ID = tracked.id
+		} else {
+			entry.info.ID = s.sessions.newID()
+		}
+
+		s.sessions.mu.Lock()
+		if s.sessions.entries == nil {
+			s.sessions.entries = map[string]*sessionEntry{}
+		}
+		s.sessions.entries[entry.info.ID] = entry
+		s.sessions.mu.Unlock()
+
+		defer func() {
+			s.sessions.mu.Lock()
+			delete(s.sessions.entries, entry.info.ID)
+			s.sessions.mu.Unlock()
+
+			if entry.attach != nil {
+				entry.attach.close()
+			}
+		}()
+
+		handler(session)
+	}
+}
+
+// newID returns the ID of a new session, see SessionInfo.ID.
+func (r *sessions) newID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	return strconv.FormatUint(r.nextID, 10)
+}
+
+// attachPoint returns the attach point of the PTY session with the given ID,
+// or nil if there is no such session or it can't be attached to.
+func (s *Server) attachPoint(id string) *ptyAttach {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	if entry, ok := s.sessions.entries[id]; ok {
+		return entry.attach
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/pkg/sftp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *Server) sftpHandler(session ssh.Session) error {
+	if s.sftpSlots != nil {
+		select {
+		case s.sftpSlots <- struct{}{}:
+			defer func() {
+				<-s.sftpSlots
+			}()
+		default:
+			log.Warnf("Rejecting SFTP session %s of user %s, %d SFTP sessions are active", session.Context().SessionID(), session.User(), s.MaxSFTPSessions)
+			_, _ = fmt.Fprintln(session.Stderr(), sftpBusyMessage)
+			setCloseReason(session, CloseReasonPolicyDenied)
+			_ = session.Exit(1)
+			return nil
+		}
+	}
+
+	if s.SFTPHandlers != nil {
+		return s.serveSFTP(session, "")
+	}
+
+	root, err := s.sftpRoot(session.Context())
+	if err != nil {
+		return fmt.Errorf("failed to resolve sftp root for user %s: %w", session.User(), err)
+	}
+
+	return s.serveSFTP(session, root)
+}
+
+// sftpRoot returns the directory SFTP sessions of the connection are confined
+// to, or an empty string if they aren't.
+func (s *Server) sftpRoot(ctx ssh.Context) (string, error) {
+	root := s.SFTPRoot
+	if s.SFTPUserRoot != nil {
+		userRoot, err := s.SFTPUserRoot(ctx)
+		if err != nil {
+			return "", err
+		}
+		if root == "" {
+			root = "/"
+		}
+		root = filepath.Join(root, filepath.Clean("/"+userRoot))
+	}
+
+	if root == "" {
+		return "", nil
+	}
+
+	return filepath.EvalSymlinks(root)
+}
+
+// serveSFTP serves SFTP requests on rwc until the client closes it. Errors
+// while serving are logged, only failing to start the server is returned.
+func (s *Server) serveSFTP(rwc io.ReadWriteCloser, root string) error {
+	session, _ := rwc.(ssh.Session)
+
+	if s.SFTPBufferSize > 0 {
+		rwc = newSFTPBufferedConn(rwc, s.SFTPBufferSize)
+	}
+
+	var idle *idleReadWriteCloser
+	if s.IdleTimeout > 0 {
+		idle = newIdleReadWriteCloser(rwc, s.IdleTimeout)
+		defer idle.Stop()
+		rwc = idle
+	}
+
+	// Without a root, relative paths are resolved against the daemon's working
+	// directory, matching the behaviour of sftp.NewServer.
+	startDir := "/"
+	if root == "" && s.SFTPHandlers == nil {
+		workDir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to resolve the working directory: %w", err)
+		}
+		startDir = workDir
+	}
+
+	var handlers sftp.Handlers
+	if s.SFTPHandlers != nil {
+		handlers = *s.SFTPHandlers
+	} else {
+		h := &fsHandler{
+			root:         root,
+			maxFileSize:  s.SFTPMaxFileSize,
+			readOnly:     s.ReadOnly,
+			uploadOnly:   s.SFTPUploadOnly,
+			downloadOnly: s.SFTPDownloadOnly,
+			deniedPaths:  s.SFTPDeniedPaths,
+		}
+		if session != nil {
+			h.user = session.User()
+		}
+		if s.SFTPMaxInFlight > 0 {
+			h.ops = make(chan struct{}, s.SFTPMaxInFlight)
+		}
+		handlers = newFSHandlers(h)
+	}
+	options := []sftp.RequestServerOption{sftp.WithStartDirectory(startDir)}
+	if s.SFTPAllocator {
+		options = append(options, sftp.WithRSAllocator())
+	}
+	server := sftp.NewRequestServer(rwc, handlers, options...)
+
+	if idle != nil {
+		idle.OnIdle(func() {
+			log.Debugf("Closing sftp session idle for more than %s", s.IdleTimeout)
+			if session != nil {
+				setCloseReason(session, CloseReasonIdleTimeout)
+			}
+			server.Close()
+		})
+	}
+
+	if err := server.Serve(); err == io.EOF {
+		server.Close()
+	} else if err != nil && (idle == nil || !idle.TimedOut()) {
+		logSessionError(log.ErrorLevel, err, "sftp server completed with error: %s\n", err)
+	}
+
+	return nil
+}
+
+// sftpBufferedConn reduces the number of reads from and writes to the SSH
+// channel. pkg/sftp reads the length of every packet separately from its
+// payload and writes packets as header and payload, each of which costs a
+// channel operation and, for writes, an SSH packet of its own.
+type sftpBufferedConn struct {
+	io.Closer
+	reader *bufio.Reader
+	writer io.Writer
+
+	buf []byte
+}
+
+func newSFTPBufferedConn(rwc io.ReadWriteCloser, size int) *sftpBufferedConn {
+	return &sftpBufferedConn{
+		Closer: rwc,
+		reader: bufio.NewReaderSize(rwc, size),
+		writer: rwc,
+		buf:    make([]byte, 0, size),
+	}
+}
+
+func (c *sftpBufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Write collects the parts of a packet and passes every complete packet to
+// the channel in a single write. pkg/sftp serializes packet writes, so a
+// packet is never interleaved with another one.
+func (c *sftpBufferedConn) Write(p []byte) (int, error) {
+	c.buf = append(c.buf, p...)
+
+	written := 0
+	for len(c.buf)-written >= 4 {
+		size := 4 + int(binary.BigEndian.Uint32(c.buf[written:]))
+		if len(c.buf)-written < size {
+			break
+		}
+
+		if _, err := c.writer.Write(c.buf[written : written+size]); err != nil {
+			c.buf = c.buf[:0]
+			return 0, err
+		}
+		written += size
+	}
+
+	c.buf = c.buf[:copy(c.buf, c.buf[written:])]
+	return len(p), nil
+}
+
+// idleReadWriteCloser invokes its idle callback once no Read or Write has
+// completed for the configured timeout.
+type idleReadWriteCloser struct {
+	io.ReadWriteCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	onIdle   atomic.Value
+	timedOut atomic.Bool
+}
+
+func newIdleReadWriteCloser(rwc io.ReadWriteCloser, timeout time.Duration) *idleReadWriteCloser {
+	i := &idleReadWriteCloser{
+		ReadWriteCloser: rwc,
+		timeout:         timeout,
+	}
+	i.timer = time.AfterFunc(timeout, i.fire)
+	return i
+}
+
+func (i *idleReadWriteCloser) OnIdle(fn func()) {
+	i.onIdle.Store(fn)
+}
+
+func (i *idleReadWriteCloser) TimedOut() bool {
+	return i.timedOut.Load()
+}
+
+func (i *idleReadWriteCloser) Stop() {
+	i.timer.Stop()
+}
+
+func (i *idleReadWriteCloser) Read(p []byte) (int, error) {
+	n, err := i.ReadWriteCloser.Read(p)
+	i.timer.Reset(i.timeout)
+	return n, err
+}
+
+func (i *idleReadWriteCloser) Write(p []byte) (int, error) {
+	n, err := i.ReadWriteCloser.Write(p)
+	i.timer.Reset(i.timeout)
+	return n, err
+}
+
+func (i *idleReadWriteCloser) fire() {
+	if !i.timedOut.CompareAndSwap(false, true) {
+		return
+	}
+
+	if fn, ok := i.onIdle.Load().(func()); ok {
+		fn()
+		return
+	}
+	_ = i.ReadWriteCloser.Close()
+}
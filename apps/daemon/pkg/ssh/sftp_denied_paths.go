@@ -0,0 +1,92 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// errSFTPPathDenied is returned for paths matching SFTPDeniedPaths.
+var errSFTPPathDenied = fmt.Errorf("%w: access to the path is denied", sftp.ErrSSHFxPermissionDenied)
+
+// matchPathPattern reports whether the absolute path p matches pattern. "**"
+// matches any number of path elements, including none, other elements are
+// matched with path.Match, e.g. "**/.ssh/**" matches ~/.ssh and everything
+// in it.
+func matchPathPattern(pattern, p string) bool {
+	return matchPathElements(splitPath(pattern), splitPath(p))
+}
+
+func matchPathElements(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchPathElements(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+
+	return len(elems) == 0
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// validatePathPattern fails for patterns path.Match can't match with.
+func validatePathPattern(pattern string) error {
+	for _, elem := range splitPath(pattern) {
+		if _, err := path.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDenied fails for local paths matching one of the denied patterns,
+// either as requested or with all symlinks resolved, so links can't be used
+// to reach denied files under a name of their own.
+func (h *fsHandler) checkDenied(p string) error {
+	if len(h.deniedPaths) == 0 {
+		return nil
+	}
+
+	candidates := []string{filepath.ToSlash(p)}
+	if real, err := evalExistingSymlinks(p); err == nil && real != p {
+		candidates = append(candidates, filepath.ToSlash(real))
+	}
+
+	for _, pattern := range h.deniedPaths {
+		for _, candidate := range candidates {
+			if matchPathPattern(pattern, candidate) {
+				log.Warnf("Denied SFTP access of user %s to %s, it matches %q", h.user, p, pattern)
+				return errSFTPPathDenied
+			}
+		}
+	}
+
+	return nil
+}
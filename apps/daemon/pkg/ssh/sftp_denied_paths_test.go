@@ -0,0 +1,124 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPathPattern(t *testing.T) {
+	for name, tc := range map[string]struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		"directory":            {pattern: "**/.ssh/**", path: "/home/daytona/.ssh", matches: true},
+		"file in directory":    {pattern: "**/.ssh/**", path: "/home/daytona/.ssh/config", matches: true},
+		"nested file":          {pattern: "**/.ssh/**", path: "/home/daytona/.ssh/keys/id_rsa", matches: true},
+		"other directory":      {pattern: "**/.ssh/**", path: "/home/daytona/.sshrc", matches: false},
+		"file at any depth":    {pattern: "**/.env", path: "/workspace/app/.env", matches: true},
+		"file at root":         {pattern: "**/.env", path: "/.env", matches: true},
+		"similar file":         {pattern: "**/.env", path: "/workspace/app/.env.example", matches: false},
+		"wildcard":             {pattern: "**/id_*", path: "/root/id_ed25519", matches: true},
+		"anchored":             {pattern: "/etc/**", path: "/etc/shadow", matches: true},
+		"anchored elsewhere":   {pattern: "/etc/**", path: "/home/etc/shadow", matches: false},
+		"single element":       {pattern: "/home/*/.env", path: "/home/daytona/.env", matches: true},
+		"single element depth": {pattern: "/home/*/.env", path: "/home/daytona/app/.env", matches: false},
+		"unclean path":         {pattern: "**/.env", path: "/workspace/app/../.env", matches: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.matches, matchPathPattern(tc.pattern, tc.path))
+		})
+	}
+}
+
+func TestSFTPDeniedPaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".ssh"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".ssh", "id_ed25519"), []byte("private key"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("TOKEN=secret"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, ".ssh", "id_ed25519"), filepath.Join(dir, "key")))
+	require.NoError(t, os.Symlink(filepath.Join(".ssh", "authorized_keys"), filepath.Join(dir, "authorized")))
+
+	s := newTestServer(t)
+	s.SFTPDeniedPaths = []string{"**/.ssh/**", "**/.env", "**/id_*"}
+
+	check := func(t *testing.T, client *sftp.Client, dir string) {
+		for name, op := range map[string]func() error{
+			"read": func() error {
+				_, err := client.Open(path.Join(dir, ".env"))
+				return err
+			},
+			"read through symlink": func() error {
+				_, err := client.Open(path.Join(dir, "key"))
+				return err
+			},
+			"write": func() error {
+				_, err := client.Create(path.Join(dir, ".ssh", "authorized_keys"))
+				return err
+			},
+			"write through dangling symlink": func() error {
+				_, err := client.Create(path.Join(dir, "authorized"))
+				return err
+			},
+			"list": func() error {
+				_, err := client.ReadDir(path.Join(dir, ".ssh"))
+				return err
+			},
+			"stat": func() error {
+				_, err := client.Stat(path.Join(dir, ".ssh", "id_ed25519"))
+				return err
+			},
+			"rename from": func() error {
+				return client.Rename(path.Join(dir, ".env"), path.Join(dir, "env.txt"))
+			},
+			"rename to": func() error {
+				return client.Rename(path.Join(dir, "notes.txt"), path.Join(dir, ".env"))
+			},
+			"remove": func() error {
+				return client.Remove(path.Join(dir, ".env"))
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				require.ErrorIs(t, op(), os.ErrPermission)
+			})
+		}
+
+		t.Run("allowed", func(t *testing.T) {
+			f, err := client.Open(path.Join(dir, "notes.txt"))
+			require.NoError(t, err)
+			content, err := io.ReadAll(f)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			require.Equal(t, "notes", string(content))
+
+			entries, err := client.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, entries, 5)
+		})
+	}
+
+	t.Run("without root", func(t *testing.T) {
+		check(t, newSFTPTestClient(t, s), dir)
+	})
+	t.Run("with root", func(t *testing.T) {
+		root, err := filepath.EvalSymlinks(dir)
+		require.NoError(t, err)
+		check(t, newSFTPTestClientWithRoot(t, s, root), "/")
+	})
+
+	content, err := os.ReadFile(filepath.Join(dir, ".env"))
+	require.NoError(t, err)
+	require.Equal(t, "TOKEN=secret", string(content))
+	_, err = os.Stat(filepath.Join(dir, ".ssh", "authorized_keys"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,556 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/sys/unix"
+)
+
+// errWorkspaceDiskFull replaces the errors of writes failing for lack of
+// space, which SFTP clients would otherwise only report as a generic failure.
+var errWorkspaceDiskFull = errors.New("workspace disk is full")
+
+// diskFullError returns errWorkspaceDiskFull for errors caused by a full disk
+// or an exceeded quota and err otherwise.
+func diskFullError(err error) error {
+	if errors.Is(err, unix.ENOSPC) || errors.Is(err, unix.EDQUOT) {
+		return errWorkspaceDiskFull
+	}
+	return err
+}
+
+// Errors of requests SFTPUploadOnly and SFTPDownloadOnly refuse. Clients see
+// a permission error with the reason as message.
+var (
+	errSFTPUploadOnly   = fmt.Errorf("%w: only uploads are allowed", sftp.ErrSSHFxPermissionDenied)
+	errSFTPDownloadOnly = fmt.Errorf("%w: only downloads are allowed", sftp.ErrSSHFxPermissionDenied)
+)
+
+// fsHandler serves SFTP requests from the local filesystem.
+type fsHandler struct {
+	// root confines all requests to the given directory, which is presented
+	// to the client as "/". Empty serves the whole filesystem.
+	root string
+	// maxFileSize caps the size of files written through a single handle.
+	// Zero means unlimited.
+	maxFileSize int64
+	// readOnly refuses all requests modifying the filesystem.
+	readOnly bool
+	// uploadOnly only allows writing files, creating directories and
+	// looking up paths. Nothing can be read, listed, removed or moved.
+	uploadOnly bool
+	// downloadOnly refuses all requests modifying the filesystem, like
+	// readOnly but with errSFTPDownloadOnly.
+	downloadOnly bool
+	// ops bounds the reads and writes in flight to its capacity. Nil leaves
+	// them to the workers of pkg/sftp.
+	ops chan struct{}
+	// deniedPaths lists patterns of local paths all requests are refused
+	// for, see matchPathPattern.
+	deniedPaths []string
+	// user is the user the session belongs to, for logging denied requests.
+	user string
+}
+
+// resolve maps a request path to a path on the local filesystem, failing for
+// denied paths. Inside a root, symlinks are resolved as well so they can't be
+// used to escape it. For follow == false the last path element isn't
+// resolved, which is needed for requests operating on the link itself like
+// Lstat or Remove.
+func (h *fsHandler) resolve(p string, follow bool) (string, error) {
+	local, err := h.resolveRoot(p, follow)
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.checkDenied(local); err != nil {
+		return "", err
+	}
+
+	return local, nil
+}
+
+func (h *fsHandler) resolveRoot(p string, follow bool) (string, error) {
+	if h.root == "" {
+		return p, nil
+	}
+
+	full := filepath.Join(h.root, filepath.FromSlash(path.Clean("/"+p)))
+
+	checked := filepath.Dir(full)
+	if follow {
+		checked = full
+	}
+
+	real, err := evalExistingSymlinks(checked)
+	if err != nil {
+		return "", err
+	}
+
+	if real != h.root && !strings.HasPrefix(real, h.root+string(filepath.Separator)) {
+		return "", sftp.ErrSSHFxPermissionDenied
+	}
+
+	return full, nil
+}
+
+// maxDanglingLinks bounds the chains of dangling symlinks evalExistingSymlinks
+// follows, like the kernel bounds symlink resolution.
+const maxDanglingLinks = 40
+
+// evalExistingSymlinks resolves symlinks in the longest existing prefix of p
+// and appends the remaining, not yet existing, elements. Dangling symlinks
+// are resolved to their targets, as creating a file through one creates the
+// target.
+func evalExistingSymlinks(p string) (string, error) {
+	return evalSymlinksWithin(p, maxDanglingLinks)
+}
+
+func evalSymlinksWithin(p string, links int) (string, error) {
+	real, err := filepath.EvalSymlinks(p)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p, nil
+	}
+
+	realParent, err := evalSymlinksWithin(parent, links)
+	if err != nil {
+		return "", err
+	}
+
+	if info, err := os.Lstat(p); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if links == 0 {
+			return "", fmt.Errorf("%s: too many links", p)
+		}
+		target, err := os.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		// Relative targets are relative to the directory the link is in,
+		// not the path it was reached by.
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(realParent, target)
+		}
+		return evalSymlinksWithin(target, links-1)
+	}
+
+	return filepath.Join(realParent, filepath.Base(p)), nil
+}
+
+// writeDenied returns the error requests modifying the filesystem fail with,
+// or nil if they are allowed.
+func (h *fsHandler) writeDenied() error {
+	switch {
+	case h.readOnly:
+		return sftp.ErrSSHFxPermissionDenied
+	case h.downloadOnly:
+		return errSFTPDownloadOnly
+	default:
+		return nil
+	}
+}
+
+func newFSHandlers(h *fsHandler) sftp.Handlers {
+	return sftp.Handlers{
+		FileGet:  h,
+		FilePut:  h,
+		FileCmd:  h,
+		FileList: h,
+	}
+}
+
+func (h *fsHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	if h.uploadOnly {
+		return nil, errSFTPUploadOnly
+	}
+
+	p, err := h.resolve(r.Filepath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.throttle(f), nil
+}
+
+func (h *fsHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return h.OpenFile(r)
+}
+
+func (h *fsHandler) OpenFile(r *sftp.Request) (sftp.WriterAtReaderAt, error) {
+	pflags := r.Pflags()
+	if err := h.writeDenied(); err != nil && (pflags.Write || pflags.Creat || pflags.Trunc) {
+		return nil, err
+	}
+	if h.uploadOnly && !pflags.Write {
+		return nil, errSFTPUploadOnly
+	}
+
+	flags := 0
+	switch {
+	case pflags.Read && pflags.Write:
+		flags |= os.O_RDWR
+	case pflags.Write:
+		flags |= os.O_WRONLY
+	default:
+		flags |= os.O_RDONLY
+	}
+	// Append is deliberately not mapped to O_APPEND, it conflicts with WriteAt.
+	if pflags.Creat {
+		flags |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+
+	mode := os.FileMode(0644)
+	if r.AttrFlags().Permissions {
+		mode = r.Attributes().FileMode().Perm()
+	}
+
+	p, err := h.resolve(r.Filepath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(p, flags, mode)
+	if err != nil {
+		return nil, diskFullError(err)
+	}
+
+	var file fileAt = f
+	if h.maxFileSize > 0 && pflags.Write {
+		file = &limitedFile{File: f, limit: h.maxFileSize}
+	}
+	// Clients like OpenSSH open uploads for reading and writing.
+	if h.uploadOnly {
+		file = &writeOnlyFile{fileAt: file}
+	}
+
+	return h.throttle(&diskFullFile{fileAt: file}), nil
+}
+
+func (h *fsHandler) Filecmd(r *sftp.Request) error {
+	// All commands modify the filesystem.
+	if err := h.writeDenied(); err != nil {
+		return err
+	}
+	// Uploads may set the times and mode of their files.
+	if h.uploadOnly && r.Method != "Setstat" && r.Method != "Mkdir" {
+		return errSFTPUploadOnly
+	}
+
+	return diskFullError(h.filecmd(r))
+}
+
+func (h *fsHandler) filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		p, err := h.resolve(r.Filepath, true)
+		if err != nil {
+			return err
+		}
+		return h.setstat(p, r)
+	case "Rename":
+		source, target, err := h.resolvePair(r)
+		if err != nil {
+			return err
+		}
+		// SFTP rename must not overwrite an existing target.
+		if _, err := os.Lstat(target); err == nil {
+			return os.ErrExist
+		}
+		return os.Rename(source, target)
+	case "Rmdir":
+		p, err := h.resolve(r.Filepath, false)
+		if err != nil {
+			return err
+		}
+		return unix.Rmdir(p)
+	case "Mkdir":
+		p, err := h.resolve(r.Filepath, false)
+		if err != nil {
+			return err
+		}
+		return os.Mkdir(p, 0755)
+	case "Link":
+		source, target, err := h.resolvePair(r)
+		if err != nil {
+			return err
+		}
+		return os.Link(source, target)
+	case "Symlink":
+		// Filepath is the content of the link and isn't resolved, only
+		// absolute targets are moved into the root.
+		target, err := h.resolve(r.Target, false)
+		if err != nil {
+			return err
+		}
+		linkTarget := r.Filepath
+		if h.root != "" && path.IsAbs(linkTarget) {
+			linkTarget = filepath.Join(h.root, filepath.FromSlash(path.Clean(linkTarget)))
+		}
+		return os.Symlink(linkTarget, target)
+	case "Remove":
+		p, err := h.resolve(r.Filepath, false)
+		if err != nil {
+			return err
+		}
+		return unlink(p)
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (h *fsHandler) resolvePair(r *sftp.Request) (string, string, error) {
+	source, err := h.resolve(r.Filepath, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	target, err := h.resolve(r.Target, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	return source, target, nil
+}
+
+func (h *fsHandler) PosixRename(r *sftp.Request) error {
+	if err := h.writeDenied(); err != nil {
+		return err
+	}
+	if h.uploadOnly {
+		return errSFTPUploadOnly
+	}
+
+	source, target, err := h.resolvePair(r)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(source, target)
+}
+
+func (h *fsHandler) StatVFS(r *sftp.Request) (*sftp.StatVFS, error) {
+	p, err := h.resolve(r.Filepath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return statVFS(p)
+}
+
+func (h *fsHandler) setstat(p string, r *sftp.Request) error {
+	attrs := r.Attributes()
+	flags := r.AttrFlags()
+
+	if flags.Size {
+		if err := os.Truncate(p, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	if flags.Permissions {
+		if err := os.Chmod(p, attrs.FileMode()); err != nil {
+			return err
+		}
+	}
+	if flags.UidGid {
+		if err := os.Chown(p, int(attrs.UID), int(attrs.GID)); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		atime := time.Unix(int64(attrs.Atime), 0)
+		mtime := time.Unix(int64(attrs.Mtime), 0)
+		if err := os.Chtimes(p, atime, mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *fsHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	p, err := h.resolve(r.Filepath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		if h.uploadOnly {
+			return nil, errSFTPUploadOnly
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+
+		infos := make([]os.FileInfo, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				// The entry was removed while listing.
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return listerAt(infos), nil
+	case "Stat":
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (h *fsHandler) Lstat(r *sftp.Request) (sftp.ListerAt, error) {
+	p, err := h.resolve(r.Filepath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	return listerAt{info}, nil
+}
+
+func (h *fsHandler) Readlink(p string) (string, error) {
+	resolved, err := h.resolve(p, false)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := os.Readlink(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	// Present absolute targets inside the root relative to the virtual "/".
+	if h.root != "" && filepath.IsAbs(target) {
+		if rel, err := filepath.Rel(h.root, target); err == nil && !strings.HasPrefix(rel, "..") {
+			return "/" + filepath.ToSlash(rel), nil
+		}
+	}
+
+	return target, nil
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// limitedFile refuses writes that would grow the file beyond limit.
+type limitedFile struct {
+	*os.File
+	limit int64
+}
+
+func (f *limitedFile) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > f.limit {
+		return 0, fmt.Errorf("file exceeds the maximum upload size of %d bytes", f.limit)
+	}
+	return f.File.WriteAt(p, off)
+}
+
+// fileAt is the part of *os.File SFTP handles use.
+type fileAt interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+}
+
+func (h *fsHandler) throttle(f fileAt) fileAt {
+	if h.ops == nil {
+		return f
+	}
+
+	return &throttledFile{fileAt: f, ops: h.ops}
+}
+
+// throttledFile waits for a free slot of ops before every read and write, so
+// operations beyond the limit queue up instead of failing.
+type throttledFile struct {
+	fileAt
+	ops chan struct{}
+}
+
+func (f *throttledFile) ReadAt(p []byte, off int64) (int, error) {
+	f.ops <- struct{}{}
+	defer func() { <-f.ops }()
+
+	return f.fileAt.ReadAt(p, off)
+}
+
+func (f *throttledFile) WriteAt(p []byte, off int64) (int, error) {
+	f.ops <- struct{}{}
+	defer func() { <-f.ops }()
+
+	return f.fileAt.WriteAt(p, off)
+}
+
+// writeOnlyFile refuses reads of files opened in upload-only mode.
+type writeOnlyFile struct {
+	fileAt
+}
+
+func (f *writeOnlyFile) ReadAt([]byte, int64) (int, error) {
+	return 0, errSFTPUploadOnly
+}
+
+// diskFullFile reports writes failing for lack of space as
+// errWorkspaceDiskFull. Some filesystems only fail on close, e.g. NFS.
+type diskFullFile struct {
+	fileAt
+}
+
+func (f *diskFullFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.fileAt.WriteAt(p, off)
+	return n, diskFullError(err)
+}
+
+func (f *diskFullFile) Close() error {
+	return diskFullError(f.fileAt.Close())
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+const sftpProbeTimeout = 5 * time.Second
+
+// SFTPProbeResult reports whether the SFTP subsystem works, see ProbeSFTP.
+type SFTPProbeResult struct {
+	// Root is the directory SFTP sessions start in, as reported by the
+	// server.
+	Root     string
+	Duration time.Duration
+	// Err is why the SFTP subsystem failed, nil if it works.
+	Err error
+}
+
+// OK reports whether the probe succeeded.
+func (r SFTPProbeResult) OK() bool {
+	return r.Err == nil
+}
+
+// ProbeSFTP serves an SFTP session over a pipe, checking that the server
+// starts with the current configuration and can stat the directory sessions
+// start in. SFTPUserRoot isn't applied, it needs a connection.
+func (s *Server) ProbeSFTP() SFTPProbeResult {
+	started := time.Now()
+	root, err := s.probeSFTP()
+	return SFTPProbeResult{
+		Root:     root,
+		Duration: time.Since(started),
+		Err:      err,
+	}
+}
+
+func (s *Server) probeSFTP() (string, error) {
+	root := ""
+	if s.SFTPHandlers == nil && s.SFTPRoot != "" {
+		var err error
+		if root, err = filepath.EvalSymlinks(s.SFTPRoot); err != nil {
+			return "", fmt.Errorf("failed to resolve sftp root: %w", err)
+		}
+	}
+
+	serverConn, clientConn := net.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		err := s.serveSFTP(serverConn, root)
+		_ = serverConn.Close()
+		served <- err
+	}()
+
+	// Unblocks the client if the server hangs.
+	timer := time.AfterFunc(sftpProbeTimeout, func() {
+		_ = clientConn.Close()
+	})
+	defer timer.Stop()
+
+	workDir, err := probeSFTPClient(clientConn)
+	_ = clientConn.Close()
+	if serveErr := <-served; serveErr != nil {
+		return "", serveErr
+	}
+	if err != nil && !timer.Stop() {
+		return "", fmt.Errorf("sftp server didn't respond within %s", sftpProbeTimeout)
+	}
+	return workDir, err
+}
+
+// probeSFTPClient returns the working directory of the SFTP server on conn
+// once it stat'ed it, then ends the session.
+func probeSFTPClient(conn net.Conn) (string, error) {
+	client, err := sftp.NewClientPipe(conn, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to start sftp session: %w", err)
+	}
+	defer client.Close()
+
+	workDir, err := client.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the sftp working directory: %w", err)
+	}
+
+	info, err := client.Stat(workDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat sftp working directory %s: %w", workDir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("sftp working directory %s is not a directory", workDir)
+	}
+
+	return workDir, nil
+}
@@ -0,0 +1,695 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newSFTPTestClient(t *testing.T, s *Server) *sftp.Client {
+	t.Helper()
+
+	return newSFTPTestClientWithRoot(t, s, "")
+}
+
+func newSFTPTestClientWithRoot(t *testing.T, s *Server, root string) *sftp.Client {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.serveSFTP(serverConn, root)
+	}()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+		<-done
+	})
+
+	return client
+}
+
+func TestSFTPFileOperations(t *testing.T) {
+	client := newSFTPTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+
+	require.NoError(t, client.Mkdir(path.Join(dir, "sub")))
+
+	f, err := client.Create(path.Join(dir, "sub", "file.txt"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, client.Rename(path.Join(dir, "sub", "file.txt"), path.Join(dir, "sub", "renamed.txt")))
+
+	entries, err := client.ReadDir(path.Join(dir, "sub"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "renamed.txt", entries[0].Name())
+	require.EqualValues(t, 5, entries[0].Size())
+
+	f, err = client.Open(path.Join(dir, "sub", "renamed.txt"))
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "hello", string(content))
+
+	require.NoError(t, client.Chmod(path.Join(dir, "sub", "renamed.txt"), 0600))
+	info, err := os.Stat(filepath.Join(dir, "sub", "renamed.txt"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, client.Remove(path.Join(dir, "sub", "renamed.txt")))
+	require.NoError(t, client.RemoveDirectory(path.Join(dir, "sub")))
+	_, err = client.Stat(path.Join(dir, "sub"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSFTPMaxFileSize(t *testing.T) {
+	s := newTestServer(t)
+	s.SFTPMaxFileSize = 1024
+
+	client := newSFTPTestClient(t, s)
+	dir := t.TempDir()
+
+	f, err := client.Create(path.Join(dir, "small"))
+	require.NoError(t, err)
+	_, err = f.Write(bytes.Repeat([]byte("a"), 1024))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = client.Create(path.Join(dir, "large"))
+	require.NoError(t, err)
+	_, err = f.Write(bytes.Repeat([]byte("a"), 4096))
+	require.ErrorContains(t, err, "maximum upload size")
+	_ = f.Close()
+}
+
+func TestSFTPHandlers(t *testing.T) {
+	handlers := sftp.InMemHandler()
+	s := newTestServer(t)
+	s.SFTPHandlers = &handlers
+	s.SFTPUserRoot = func(ctx ssh.Context) (string, error) {
+		return "", errors.New("not used with custom handlers")
+	}
+	client := dialTestServer(t, startTestServer(t, s))
+
+	sftpClient, err := sftp.NewClient(client)
+	require.NoError(t, err)
+	defer sftpClient.Close()
+
+	f, err := sftpClient.Create("/in-memory.txt")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("virtual"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = sftpClient.Open("/in-memory.txt")
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "virtual", string(content))
+
+	_, err = os.Stat("/in-memory.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSFTPReadOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.ReadOnly = true
+	client := newSFTPTestClient(t, s)
+
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("snapshot"), 0o644))
+
+	f, err := client.Open(existing)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "snapshot", string(content))
+
+	_, err = client.Create(path.Join(dir, "new.txt"))
+	require.ErrorIs(t, err, os.ErrPermission)
+	_, err = client.OpenFile(existing, os.O_WRONLY)
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.ErrorIs(t, client.Mkdir(path.Join(dir, "sub")), os.ErrPermission)
+	require.ErrorIs(t, client.Remove(existing), os.ErrPermission)
+	require.ErrorIs(t, client.Rename(existing, path.Join(dir, "renamed.txt")), os.ErrPermission)
+	require.ErrorIs(t, client.PosixRename(existing, path.Join(dir, "renamed.txt")), os.ErrPermission)
+	require.ErrorIs(t, client.Chmod(existing, 0o600), os.ErrPermission)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	content, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	require.Equal(t, "snapshot", string(content))
+}
+
+func TestSFTPUploadOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.SFTPUploadOnly = true
+	client := newSFTPTestClient(t, s)
+
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("private"), 0o644))
+
+	f, err := client.Create(path.Join(dir, "upload.txt"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("dropped"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, client.Chtimes(path.Join(dir, "upload.txt"), time.Unix(0, 0), time.Unix(0, 0)))
+	require.NoError(t, client.Mkdir(path.Join(dir, "sub")))
+	_, err = client.Stat(existing)
+	require.NoError(t, err)
+
+	_, err = client.Open(existing)
+	require.ErrorIs(t, err, os.ErrPermission)
+	f, err = client.OpenFile(existing, os.O_RDWR)
+	require.NoError(t, err)
+	_, err = f.Read(make([]byte, 7))
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.NoError(t, f.Close())
+	_, err = client.ReadDir(dir)
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.ErrorIs(t, client.Remove(existing), os.ErrPermission)
+	require.ErrorIs(t, client.Rename(existing, path.Join(dir, "renamed.txt")), os.ErrPermission)
+	require.ErrorIs(t, client.PosixRename(existing, path.Join(dir, "renamed.txt")), os.ErrPermission)
+
+	content, err := os.ReadFile(path.Join(dir, "upload.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "dropped", string(content))
+	content, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	require.Equal(t, "private", string(content))
+}
+
+func TestSFTPDownloadOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.SFTPDownloadOnly = true
+	client := newSFTPTestClient(t, s)
+
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("release"), 0o644))
+
+	f, err := client.Open(existing)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "release", string(content))
+	listed, err := client.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	_, err = client.Create(path.Join(dir, "new.txt"))
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.ErrorIs(t, client.Mkdir(path.Join(dir, "sub")), os.ErrPermission)
+	require.ErrorIs(t, client.Remove(existing), os.ErrPermission)
+	require.ErrorIs(t, client.PosixRename(existing, path.Join(dir, "renamed.txt")), os.ErrPermission)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestSFTPStartFailure(t *testing.T) {
+	s := newTestServer(t)
+	s.SFTPRoot = filepath.Join(t.TempDir(), "missing")
+	client := dialTestServer(t, startTestServer(t, s))
+
+	// gossh.Session doesn't collect stderr and exit status of subsystems.
+	ch, reqs, err := client.OpenChannel("session", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	ok, err := ch.SendRequest("subsystem", true, gossh.Marshal(struct{ Name string }{"sftp"}))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	var exitStatus struct{ Status uint32 }
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		for req := range reqs {
+			if req.Type == "exit-status" {
+				return gossh.Unmarshal(req.Payload, &exitStatus)
+			}
+		}
+		return io.EOF
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), exitStatus.Status)
+
+	stderr, err := io.ReadAll(ch.Stderr())
+	require.NoError(t, err)
+	require.Contains(t, string(stderr), "Failed to start sftp subsystem: failed to resolve sftp root")
+
+	// Clients fail right away instead of waiting for the server version.
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		_, err := sftp.NewClient(client)
+		return err
+	})
+	require.Error(t, err)
+}
+
+// slowFile records how many reads and writes run at the same time.
+type slowFile struct {
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (f *slowFile) do() {
+	f.mu.Lock()
+	f.active++
+	f.maxSeen = max(f.maxSeen, f.active)
+	f.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	f.mu.Lock()
+	f.active--
+	f.mu.Unlock()
+}
+
+func (f *slowFile) ReadAt(p []byte, off int64) (int, error)  { f.do(); return len(p), nil }
+func (f *slowFile) WriteAt(p []byte, off int64) (int, error) { f.do(); return len(p), nil }
+func (f *slowFile) Close() error                             { return nil }
+
+func TestSFTPMaxInFlight(t *testing.T) {
+	t.Run("throttled file", func(t *testing.T) {
+		file := &slowFile{}
+		throttled := (&fsHandler{ops: make(chan struct{}, 2)}).throttle(file)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				buf := make([]byte, 16)
+				_, err := throttled.ReadAt(buf, 0)
+				require.NoError(t, err)
+				_, err = throttled.WriteAt(buf, 0)
+				require.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, 2, file.maxSeen)
+	})
+
+	t.Run("pipelined reads", func(t *testing.T) {
+		s := newTestServer(t)
+		s.SFTPMaxInFlight = 1
+		client := newSFTPTestClient(t, s)
+
+		content := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+		p := path.Join(t.TempDir(), "large")
+		require.NoError(t, os.WriteFile(p, content, 0o644))
+
+		// The client issues reads concurrently, none of which may fail.
+		f, err := client.Open(p)
+		require.NoError(t, err)
+		defer f.Close()
+
+		var buf bytes.Buffer
+		_, err = f.WriteTo(&buf)
+		require.NoError(t, err)
+		require.Equal(t, content, buf.Bytes())
+	})
+}
+
+func TestSFTPIdleSessionIsReaped(t *testing.T) {
+	s := newTestServer(t)
+	s.IdleTimeout = 100 * time.Millisecond
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.serveSFTP(serverConn, "")
+	}()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Getwd()
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle sftp session was not closed")
+	}
+
+	_, err = client.Getwd()
+	require.Error(t, err)
+}
+
+func TestSFTPUserRoot(t *testing.T) {
+	root := t.TempDir()
+	for _, user := range []string{"alice", "bob"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, user), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, user, user+".txt"), []byte(user), 0644))
+	}
+
+	s := newTestServer(t)
+	s.SFTPRoot = root
+	s.SFTPUserRoot = func(ctx ssh.Context) (string, error) {
+		return ctx.User(), nil
+	}
+
+	addr := startTestServer(t, s)
+
+	for _, user := range []string{"alice", "bob"} {
+		t.Run(user, func(t *testing.T) {
+			client, err := sftp.NewClient(dialTestServerAs(t, addr, user))
+			require.NoError(t, err)
+			defer client.Close()
+
+			entries, err := client.ReadDir("/")
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			require.Equal(t, user+".txt", entries[0].Name())
+
+			entries, err = client.ReadDir("/../..")
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+
+			wd, err := client.Getwd()
+			require.NoError(t, err)
+			require.Equal(t, "/", wd)
+		})
+	}
+}
+
+func TestSFTPRootRejectsSymlinkEscape(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+
+	s := newTestServer(t)
+	client := newSFTPTestClientWithRoot(t, s, root)
+
+	_, err := client.Open("/escape/secret")
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	_, err = client.Create("/escape/new")
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	info, err := client.Lstat("/escape")
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&os.ModeSymlink)
+}
+
+func TestSFTPRootRejectsDanglingSymlinkEscape(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	require.NoError(t, os.Mkdir(root, 0o755))
+	require.NoError(t, os.Mkdir(outside, 0o755))
+	require.NoError(t, os.Symlink("../outside/new", filepath.Join(root, "escape")))
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "self")))
+
+	s := newTestServer(t)
+	client := newSFTPTestClientWithRoot(t, s, root)
+
+	// Through self the link is lexically inside the root, but its target
+	// is resolved relative to the root itself.
+	for _, p := range []string{"/escape", "/self/escape"} {
+		_, err := client.Create(p)
+		require.ErrorIs(t, err, os.ErrPermission, p)
+	}
+
+	_, err := os.Lstat(filepath.Join(outside, "new"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// BenchmarkSFTPTransfer measures SFTP throughput over a loopback SSH
+// connection, e.g. go test -run '^$' -bench SFTPTransfer ./pkg/ssh
+func BenchmarkSFTPTransfer(b *testing.B) {
+	const size = 64 << 20
+
+	for _, bc := range []struct {
+		name       string
+		bufferSize int
+		allocator  bool
+	}{
+		{name: "default"},
+		{name: "buffered", bufferSize: 256 << 10},
+		{name: "allocator", allocator: true},
+		{name: "buffered-allocator", bufferSize: 256 << 10, allocator: true},
+	} {
+		s := newTestServer(b)
+		s.SFTPBufferSize = bc.bufferSize
+		s.SFTPAllocator = bc.allocator
+
+		addr := startTestServer(b, s)
+		client, err := sftp.NewClient(dialTestServer(b, addr), sftp.UseConcurrentWrites(true))
+		require.NoError(b, err)
+		defer client.Close()
+
+		data := bytes.Repeat([]byte{0xda}, size)
+		remote := path.Join(b.TempDir(), "file")
+
+		b.Run(bc.name+"/upload", func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				f, err := client.Create(remote)
+				require.NoError(b, err)
+				_, err = f.ReadFrom(bytes.NewReader(data))
+				require.NoError(b, err)
+				require.NoError(b, f.Close())
+			}
+		})
+
+		b.Run(bc.name+"/download", func(b *testing.B) {
+			require.NoError(b, os.WriteFile(remote, data, 0644))
+
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				f, err := client.Open(remote)
+				require.NoError(b, err)
+				_, err = f.WriteTo(io.Discard)
+				require.NoError(b, err)
+				require.NoError(b, f.Close())
+			}
+		})
+	}
+}
+
+func TestSFTPBufferedConn(t *testing.T) {
+	s := newTestServer(t)
+	s.SFTPBufferSize = 4 << 10
+	s.SFTPAllocator = true
+	client := newSFTPTestClient(t, s)
+
+	// Larger than the buffer, so packets are split across several reads.
+	data := bytes.Repeat([]byte("daytona"), 64<<10)
+	file := path.Join(t.TempDir(), "file")
+
+	f, err := client.Create(file)
+	require.NoError(t, err)
+	_, err = f.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = client.Open(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, data, content)
+}
+
+func TestSFTPAlongsideShell(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxSessions = 2
+	client := dialTestServer(t, startTestServer(t, s))
+
+	sftpClient, err := sftp.NewClient(client)
+	require.NoError(t, err)
+	defer sftpClient.Close()
+
+	shell, err := client.NewSession()
+	require.NoError(t, err)
+	defer shell.Close()
+	require.NoError(t, shell.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+	stdin, err := shell.StdinPipe()
+	require.NoError(t, err)
+	var output bytes.Buffer
+	shell.Stdout = &output
+	require.NoError(t, shell.Shell())
+
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	sessions := s.Sessions()
+	require.Equal(t, "sftp", sessions[0].Subsystem)
+	require.False(t, sessions[0].PTY)
+	require.Equal(t, "", sessions[1].Subsystem)
+	require.True(t, sessions[1].PTY)
+	require.Equal(t, sessions[0].SessionID, sessions[1].SessionID)
+	require.NotEqual(t, sessions[0].ID, sessions[1].ID)
+
+	// Both count against MaxSessions.
+	third, err := client.NewSession()
+	require.NoError(t, err)
+	defer third.Close()
+	require.Error(t, third.Run("true"))
+
+	// A file written over SFTP is visible to the shell.
+	f, err := sftpClient.Create(filepath.Join(s.ProjectDir, "from-sftp.txt"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("written-over-sftp"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = io.WriteString(stdin, "cat from-sftp.txt; exit\n")
+	require.NoError(t, err)
+	require.NoError(t, runWithTimeout(t, 10*time.Second, shell.Wait))
+	require.Contains(t, output.String(), "written-over-sftp")
+
+	require.Eventually(t, func() bool {
+		return len(s.Sessions()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestMaxSFTPSessions(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxSFTPSessions = 1
+	client := dialTestServer(t, startTestServer(t, s))
+
+	first, err := sftp.NewClient(client)
+	require.NoError(t, err)
+
+	// gossh.Session doesn't collect stderr and exit status of subsystems.
+	ch, reqs, err := client.OpenChannel("session", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	ok, err := ch.SendRequest("subsystem", true, gossh.Marshal(struct{ Name string }{"sftp"}))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	var exitStatus struct{ Status uint32 }
+	err = runWithTimeout(t, 5*time.Second, func() error {
+		for req := range reqs {
+			if req.Type == "exit-status" {
+				return gossh.Unmarshal(req.Payload, &exitStatus)
+			}
+		}
+		return io.EOF
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), exitStatus.Status)
+
+	stderr, err := io.ReadAll(ch.Stderr())
+	require.NoError(t, err)
+	require.Equal(t, sftpBusyMessage+"\n", string(stderr))
+
+	// Shells don't count against the limit.
+	for i := 0; i < 3; i++ {
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		output, err := session.Output("echo ok")
+		require.NoError(t, err)
+		require.Equal(t, "ok\n", string(output))
+		session.Close()
+	}
+
+	_, err = first.Stat(s.ProjectDir)
+	require.NoError(t, err)
+	require.NoError(t, first.Close())
+
+	require.Eventually(t, func() bool {
+		second, err := sftp.NewClient(client)
+		if err != nil {
+			return false
+		}
+		defer second.Close()
+
+		_, err = second.Stat(s.ProjectDir)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestSFTPDiskFull(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full is unavailable")
+	}
+	client := newSFTPTestClient(t, newTestServer(t))
+
+	// Writes to /dev/full fail with ENOSPC.
+	f, err := client.OpenFile("/dev/full", os.O_WRONLY)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("data"))
+	var statusErr *sftp.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Contains(t, statusErr.Error(), errWorkspaceDiskFull.Error())
+}
+
+func TestProbeSFTP(t *testing.T) {
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	for name, tc := range map[string]struct {
+		root     string
+		expected string
+		err      string
+	}{
+		"working directory": {expected: workDir},
+		"root":              {root: t.TempDir(), expected: "/"},
+		"missing root":      {root: filepath.Join(t.TempDir(), "missing"), err: "failed to resolve sftp root"},
+		"root is a file":    {root: file, err: "is not a directory"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.SFTPRoot = tc.root
+
+			result := s.ProbeSFTP()
+			if tc.err != "" {
+				require.False(t, result.OK())
+				require.ErrorContains(t, result.Err, tc.err)
+				return
+			}
+			require.True(t, result.OK(), "%v", result.Err)
+			require.Equal(t, tc.expected, result.Root)
+			require.Positive(t, result.Duration)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// commandNotFoundStatus is the exit status shells use for commands they
+// can't find.
+const commandNotFoundStatus = 127
+
+// shellFallbackAllowed reports whether a shell may replace the command of the
+// session if it isn't found, see FallbackToShell. The shell must be allowed
+// for the session on its own, a forced command is never replaced.
+func (s *Server) shellFallbackAllowed(session ssh.Session, command string) bool {
+	if !s.FallbackToShell || s.DisablePty || command != session.RawCommand() {
+		return false
+	}
+	if _, _, isPty := session.Pty(); !isPty {
+		return false
+	}
+	if s.SessionAuthorizer != nil && s.SessionAuthorizer(session.Context(), "", "") != nil {
+		return false
+	}
+	return true
+}
+
+// showShellFallback tells the user why a shell starts instead of the command.
+func (s *Server) showShellFallback(session ssh.Session, command string) {
+	log.Infof("Command %q of session %s wasn't found, starting a shell", command, session.Context().SessionID())
+
+	if _, err := fmt.Fprintf(session, "%.64q wasn't found, starting a shell instead\r\n", command); err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to write shell fallback notice: %v", err)
+	}
+}
+
+// sessionInput reads the input of a session in a goroutine of its own and
+// passes it to one reader after the other. The copy to a command would
+// otherwise swallow the first input meant for the shell replacing it.
+type sessionInput struct {
+	chunks chan []byte
+	done   chan struct{}
+	// err is set before chunks is closed.
+	err error
+}
+
+func newSessionInput(r io.Reader) *sessionInput {
+	in := &sessionInput{
+		chunks: make(chan []byte),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				select {
+				case in.chunks <- append([]byte(nil), buf[:n]...):
+				case <-in.done:
+					return
+				}
+			}
+			if err != nil {
+				in.err = err
+				close(in.chunks)
+				return
+			}
+		}
+	}()
+
+	return in
+}
+
+// reader returns a reader of the input that ends once stop is closed. Input
+// it hasn't returned by then stays with the next reader.
+func (in *sessionInput) reader(stop <-chan struct{}) io.Reader {
+	return &sessionInputReader{input: in, stop: stop}
+}
+
+// close stops reading the input once the session ended.
+func (in *sessionInput) close() {
+	close(in.done)
+}
+
+type sessionInputReader struct {
+	input   *sessionInput
+	stop    <-chan struct{}
+	pending []byte
+}
+
+func (r *sessionInputReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		// Input arriving as the reader is stopped goes to the next one.
+		select {
+		case <-r.stop:
+			return 0, io.EOF
+		default:
+		}
+
+		select {
+		case <-r.stop:
+			return 0, io.EOF
+		case chunk, ok := <-r.input.chunks:
+			if !ok {
+				return 0, r.input.err
+			}
+			r.pending = chunk
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
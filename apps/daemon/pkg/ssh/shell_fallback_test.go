@@ -0,0 +1,105 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestFallbackToShell(t *testing.T) {
+	s := newTestServer(t)
+	s.FallbackToShell = true
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	output := &attachedOutput{}
+	session.Stdout = output
+	session.Stderr = io.Discard
+	require.NoError(t, session.Start("no-such-command"))
+
+	require.Eventually(t, func() bool {
+		return output.contains(`"no-such-command" wasn't found, starting a shell instead`)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The first input after the command exited reaches the shell.
+	_, err = fmt.Fprintln(stdin, "echo fallback=$((6*7)); exit 3")
+	require.NoError(t, err)
+
+	err = runWithTimeout(t, 10*time.Second, session.Wait)
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 3, exitErr.ExitStatus())
+	require.True(t, output.contains("fallback=42"))
+}
+
+func TestFallbackToShellNotAllowed(t *testing.T) {
+	for name, tc := range map[string]struct {
+		configure func(s *Server)
+		pty       bool
+	}{
+		"disabled": {
+			configure: func(s *Server) {},
+			pty:       true,
+		},
+		"without pty": {
+			configure: func(s *Server) { s.FallbackToShell = true },
+		},
+		"pty disabled": {
+			configure: func(s *Server) {
+				s.FallbackToShell = true
+				s.DisablePty = true
+			},
+			pty: true,
+		},
+		"shell denied": {
+			configure: func(s *Server) {
+				s.FallbackToShell = true
+				s.SessionAuthorizer = func(ctx ssh.Context, subsystem, command string) error {
+					if command == "" {
+						return errors.New("shells are denied")
+					}
+					return nil
+				}
+			},
+			pty: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			tc.configure(s)
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			if tc.pty {
+				require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+			}
+			_, err = session.StdinPipe()
+			require.NoError(t, err)
+
+			err = runWithTimeout(t, 10*time.Second, func() error {
+				return session.Run("no-such-command")
+			})
+			var exitErr *gossh.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			require.Equal(t, commandNotFoundStatus, exitErr.ExitStatus())
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SlowCommand is passed to the OnSlowCommand callback for non-PTY commands
+// running longer than SlowCommandThreshold.
+type SlowCommand struct {
+	SessionID string
+	User      string
+	Command   string
+	Duration  time.Duration
+}
+
+func (s *Server) reportSlowCommand(session ssh.Session, command string, duration time.Duration) {
+	if s.SlowCommandThreshold <= 0 || duration <= s.SlowCommandThreshold {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"session":  session.Context().SessionID(),
+		"user":     session.User(),
+		"command":  command,
+		"duration": duration,
+	}).Warn("Slow SSH command")
+
+	if s.OnSlowCommand != nil {
+		s.OnSlowCommand(SlowCommand{
+			SessionID: session.Context().SessionID(),
+			User:      session.User(),
+			Command:   command,
+			Duration:  duration,
+		})
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+//go:build !linux
+
+package ssh
+
+import "github.com/pkg/sftp"
+
+func statVFS(p string) (*sftp.StatVFS, error) {
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// stderrTailMaxBytes bounds the stderr kept per command, however long its
+// lines are.
+const stderrTailMaxBytes = 4096
+
+// stderrTail keeps the last lines written to the stderr of a command.
+type stderrTail struct {
+	mu    sync.Mutex
+	lines int
+	buf   []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailMaxBytes {
+		t.buf = t.buf[len(t.buf)-stderrTailMaxBytes:]
+	}
+	return len(p), nil
+}
+
+// String returns the last lines written, without a trailing newline.
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tail := bytes.TrimRight(t.buf, "\n")
+	for i, n := len(tail)-1, 0; i >= 0; i-- {
+		if tail[i] == '\n' {
+			if n++; n == t.lines {
+				return string(tail[i+1:])
+			}
+		}
+	}
+	return string(tail)
+}
+
+// teeStderrTail returns the stderr writer of a command, which also keeps the
+// last StderrTailLines lines for the end of the session.
+func (s *Server) teeStderrTail(session ssh.Session) io.Writer {
+	tracked, ok := session.(*trackedSession)
+	if s.StderrTailLines <= 0 || !ok {
+		return session.Stderr()
+	}
+
+	tracked.stderrTail = &stderrTail{lines: s.StderrTailLines}
+	// The tail goes first as it never fails.
+	return io.MultiWriter(tracked.stderrTail, session.Stderr())
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestStderrTail(t *testing.T) {
+	for name, tc := range map[string]struct {
+		command  string
+		expected string
+	}{
+		"failed":    {command: "for i in 1 2 3 4 5; do echo line$i >&2; done; exit 2", expected: "line3\nline4\nline5"},
+		"few lines": {command: "echo only >&2; exit 1", expected: "only"},
+		"succeeded": {command: "echo warning >&2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var audit bytes.Buffer
+			ends := make(chan SessionEnd, 1)
+
+			s := newTestServer(t)
+			s.StderrTailLines = 3
+			s.AuditLog = &audit
+			s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			var stderr bytes.Buffer
+			session.Stderr = &stderr
+			_ = session.Run(tc.command)
+
+			var end SessionEnd
+			select {
+			case end = <-ends:
+			case <-time.After(5 * time.Second):
+				t.Fatal("session didn't end")
+			}
+			require.Equal(t, tc.expected, end.StderrTail)
+
+			var record map[string]any
+			lines := bufio.NewScanner(&audit)
+			for lines.Scan() {
+				record = nil
+				require.NoError(t, json.Unmarshal(lines.Bytes(), &record))
+			}
+			require.Equal(t, AuditSessionEnd, record["event"])
+			if tc.expected == "" {
+				require.NotContains(t, record, "stderr_tail")
+			} else {
+				require.Equal(t, tc.expected, record["stderr_tail"])
+			}
+
+			// The client gets all of it regardless.
+			require.NotEmpty(t, stderr.String())
+		})
+	}
+}
+
+func TestStderrTailBounded(t *testing.T) {
+	tail := &stderrTail{lines: 2}
+	_, _ = tail.Write([]byte("first\nsecond\n"))
+	_, _ = tail.Write([]byte(strings.Repeat("x", 2*stderrTailMaxBytes) + "\nlast\n"))
+
+	require.Equal(t, strings.Repeat("x", stderrTailMaxBytes-len("\nlast\n"))+"\nlast", tail.String())
+	require.Len(t, tail.buf, stderrTailMaxBytes)
+}
+
+func TestStderrTailDisabled(t *testing.T) {
+	ends := make(chan SessionEnd, 1)
+	s := newTestServer(t)
+	s.OnSessionEnd = func(end SessionEnd) { ends <- end }
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	err = session.Run("echo failure >&2; exit 1")
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+
+	select {
+	case end := <-ends:
+		require.Empty(t, end.StderrTail)
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't end")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// stdinFileEnv names the file clients pass as stdin to their command if
+// AllowStdinFile is set, independent of AcceptEnv.
+const stdinFileEnv = "DAYTONA_STDIN_FILE"
+
+var errInvalidStdinFile = errors.New("invalid stdin file")
+
+// openStdinFile opens the file the client of session set stdinFileEnv to, or
+// returns nil if it didn't. Relative paths are resolved against dir, the
+// directory the command runs in, and the file must not be outside of it, not
+// even through symlinks.
+func (s *Server) openStdinFile(session ssh.Session, dir string) (*os.File, error) {
+	if !s.AllowStdinFile {
+		return nil, nil
+	}
+
+	name := ""
+	for _, env := range session.Environ() {
+		if value, ok := strings.CutPrefix(env, stdinFileEnv+"="); ok {
+			name = value
+		}
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errInvalidStdinFile, name, err)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errInvalidStdinFile, name, err)
+	}
+	if !strings.HasPrefix(real, root+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%w %s: not in %s", errInvalidStdinFile, name, dir)
+	}
+
+	f, err := os.Open(real)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errInvalidStdinFile, name, err)
+	}
+	// Reading from a FIFO or device could block the command forever.
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%w %s: not a regular file", errInvalidStdinFile, name)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdinFile(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("outside\n"), 0o600))
+
+	for name, tc := range map[string]struct {
+		disabled bool
+		file     string
+		output   string
+		err      string
+	}{
+		"relative path":  {file: "input.txt", output: "from file\n"},
+		"absolute path":  {file: "$DIR/input.txt", output: "from file\n"},
+		"disabled":       {disabled: true, file: "input.txt", output: "from client\n"},
+		"outside":        {file: outside, err: "invalid stdin file " + outside + ": not in"},
+		"symlink escape": {file: "link.txt", err: "invalid stdin file link.txt: not in"},
+		"directory":      {file: "sub", err: "invalid stdin file sub: not a regular file"},
+		"missing":        {file: "missing.txt", err: "invalid stdin file missing.txt"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.AllowStdinFile = !tc.disabled
+			dir := s.ProjectDir
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "input.txt"), []byte("from file\n"), 0o600))
+			require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link.txt")))
+			require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o700))
+			client := dialTestServer(t, startTestServer(t, s))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+			require.NoError(t, session.Setenv(stdinFileEnv, strings.ReplaceAll(tc.file, "$DIR", dir)))
+			session.Stdin = strings.NewReader("from client\n")
+
+			output, err := session.CombinedOutput("cat")
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, string(output), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.output, string(output))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// subsystemHandler adapts a subsystem that can fail to start. The subsystem
+// request already succeeded by then, so clients would wait for protocol data
+// if the session just ended. Instead the error is written to stderr and the
+// session exits with status 1.
+func subsystemHandler(name string, handler func(ssh.Session) error) func(ssh.Session) {
+	return func(session ssh.Session) {
+		err := handler(session)
+		if err == nil {
+			return
+		}
+
+		log.Errorf("Failed to start %s subsystem of session %s: %v", name, session.Context().SessionID(), err)
+		_, _ = fmt.Fprintf(session.Stderr(), "Failed to start %s subsystem: %v\n", name, err)
+		setCloseReason(session, CloseReasonError)
+		_ = session.Exit(1)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TemplateData holds the variables of BannerTemplate and WelcomeTemplate,
+// e.g. "Welcome to {{.Workspace}}, {{.User}}".
+type TemplateData struct {
+	// User is the user name the client logged in with.
+	User string
+	// Workspace is the workspace the client selected if WorkspaceResolver
+	// knows it, the host name otherwise. The banner always shows the host
+	// name, as it is sent before the client authenticated.
+	Workspace string
+	// Time is the local time of the login.
+	Time time.Time
+	// ActiveSessions counts the sessions running on the server, not
+	// including the one the welcome message is shown in.
+	ActiveSessions int
+	// RemoteIP is the address the client connected from.
+	RemoteIP string
+}
+
+// parseTemplates parses BannerTemplate and WelcomeTemplate, leaving the ones
+// that are empty or invalid nil.
+func (s *Server) parseTemplates() (banner, welcome *template.Template, err error) {
+	var errs []error
+	if s.BannerTemplate != "" {
+		if banner, err = template.New("banner").Parse(s.BannerTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("invalid banner template: %w", err))
+		}
+	}
+	if s.WelcomeTemplate != "" {
+		if welcome, err = template.New("welcome").Parse(s.WelcomeTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("invalid welcome template: %w", err))
+		}
+	}
+	return banner, welcome, errors.Join(errs...)
+}
+
+func (s *Server) templateData(ctx ssh.Context, workspace string) TemplateData {
+	data := TemplateData{
+		User:           ctx.User(),
+		Workspace:      s.templateWorkspace(workspace),
+		Time:           time.Now(),
+		ActiveSessions: len(s.Sessions()),
+	}
+	if ip := remoteIP(ctx); ip != nil {
+		data.RemoteIP = ip.String()
+	}
+	return data
+}
+
+func (s *Server) templateWorkspace(name string) string {
+	if s.WorkspaceResolver != nil && name != "" {
+		if _, err := s.WorkspaceResolver(name); err == nil {
+			return name
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// renderTemplate executes tmpl, returning false if it failed, e.g. on an
+// unknown variable, so the message can be left out rather than shown half
+// rendered.
+func renderTemplate(tmpl *template.Template, data TemplateData) (string, bool) {
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		log.Warnf("Failed to render %s template: %v", tmpl.Name(), err)
+		return "", false
+	}
+	return out.String(), true
+}
+
+// bannerTemplateHandler renders BannerTemplate for a connection, falling back
+// to the banner it replaces if rendering fails.
+func (s *Server) bannerTemplateHandler(tmpl *template.Template, fallback ssh.BannerHandler) ssh.BannerHandler {
+	return func(ctx ssh.Context) string {
+		// Resolving the workspace would tell unauthenticated clients
+		// whether it exists.
+		banner, ok := renderTemplate(tmpl, s.templateData(ctx, ""))
+		if !ok {
+			if fallback != nil {
+				return fallback(ctx)
+			}
+			return s.Banner
+		}
+		return banner
+	}
+}
+
+// showWelcomeTemplate writes WelcomeTemplate to the PTY session before the
+// welcome command runs.
+func (s *Server) showWelcomeTemplate(session ssh.Session) {
+	if s.welcomeTemplate == nil {
+		return
+	}
+
+	workspace, _ := s.workspaceName(session)
+	data := s.templateData(session.Context(), workspace)
+	// The session was registered before the shell started.
+	data.ActiveSessions = max(data.ActiveSessions-1, 0)
+	welcome, ok := renderTemplate(s.welcomeTemplate, data)
+	if !ok || welcome == "" {
+		return
+	}
+
+	output := bytes.ReplaceAll([]byte(welcome), []byte("\n"), []byte("\r\n"))
+	if _, err := session.Write(output); err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to write welcome message: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestBannerTemplate(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		template string
+		expected string
+	}{
+		"user":            {template: "{{.User}}", expected: "daytona"},
+		"workspace":       {template: "{{.Workspace}}", expected: hostname},
+		"time":            {template: "{{.Time.Year}}", expected: strconv.Itoa(time.Now().Year())},
+		"active sessions": {template: "{{.ActiveSessions}}", expected: "0"},
+		"remote ip":       {template: "{{.RemoteIP}}", expected: "127.0.0.1"},
+		"render error":    {template: "{{.Missing}}", expected: "static banner\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.Banner = "static banner\n"
+			s.BannerTemplate = tc.template
+
+			require.Equal(t, tc.expected, dialBanner(t, startTestServer(t, s)))
+		})
+	}
+}
+
+func TestBannerTemplateWorkspace(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	var resolved atomic.Bool
+	s := newTestServer(t)
+	s.BannerTemplate = "Welcome to {{.Workspace}}"
+	s.WorkspaceResolver = func(name string) (string, error) {
+		resolved.Store(true)
+		return t.TempDir(), nil
+	}
+
+	// Workspaces aren't resolved before the client authenticated.
+	require.Equal(t, "Welcome to "+hostname, dialBanner(t, startTestServer(t, s)))
+	require.False(t, resolved.Load())
+}
+
+func TestWelcomeTemplate(t *testing.T) {
+	s := newTestServer(t)
+	s.WelcomeTemplate = "Hello {{.User}} from {{.RemoteIP}}, {{.ActiveSessions}} other sessions\n"
+	s.WelcomeCommand = "echo welcome-command"
+	client := dialTestServer(t, startTestServer(t, s))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.RequestPty("xterm", 40, 80, gossh.TerminalModes{}))
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stdin = strings.NewReader("echo shell=$((6*7)); exit\n")
+	require.NoError(t, session.Shell())
+	require.NoError(t, runWithTimeout(t, 10*time.Second, session.Wait))
+
+	welcome := strings.Index(output.String(), "Hello daytona from 127.0.0.1, 0 other sessions\r\n")
+	command := strings.Index(output.String(), "welcome-command")
+	shell := strings.Index(output.String(), "shell=42")
+	require.GreaterOrEqual(t, welcome, 0)
+	require.Greater(t, command, welcome)
+	require.Greater(t, shell, command)
+}
+
+func TestStartRejectsInvalidTemplate(t *testing.T) {
+	s := newTestServer(t)
+	s.WelcomeTemplate = "{{if .User}}"
+
+	require.ErrorContains(t, s.Start(), "invalid welcome template")
+}
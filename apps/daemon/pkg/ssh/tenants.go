@@ -0,0 +1,127 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TenantUsage aggregates the connections of a tenant, see Identity.Tenant.
+// Bytes count the SSH traffic of its connections after authentication.
+type TenantUsage struct {
+	Connections       int64
+	ActiveConnections int64
+	Sessions          int64
+	ActiveSessions    int64
+	BytesRead         int64
+	BytesWritten      int64
+}
+
+type tenantCounters struct {
+	connections       atomic.Int64
+	activeConnections atomic.Int64
+	sessions          atomic.Int64
+	activeSessions    atomic.Int64
+	bytesRead         atomic.Int64
+	bytesWritten      atomic.Int64
+}
+
+type tenants struct {
+	mu      sync.Mutex
+	entries map[string]*tenantCounters
+}
+
+// TenantUsage returns the usage of every tenant that connected since the
+// server started.
+func (s *Server) TenantUsage() map[string]TenantUsage {
+	s.tenants.mu.Lock()
+	defer s.tenants.mu.Unlock()
+
+	usage := make(map[string]TenantUsage, len(s.tenants.entries))
+	for tenant, c := range s.tenants.entries {
+		usage[tenant] = TenantUsage{
+			Connections:       c.connections.Load(),
+			ActiveConnections: c.activeConnections.Load(),
+			Sessions:          c.sessions.Load(),
+			ActiveSessions:    c.activeSessions.Load(),
+			BytesRead:         c.bytesRead.Load(),
+			BytesWritten:      c.bytesWritten.Load(),
+		}
+	}
+
+	return usage
+}
+
+func (s *Server) tenantCounters(tenant string) *tenantCounters {
+	s.tenants.mu.Lock()
+	defer s.tenants.mu.Unlock()
+
+	if s.tenants.entries == nil {
+		s.tenants.entries = map[string]*tenantCounters{}
+	}
+
+	c, ok := s.tenants.entries[tenant]
+	if !ok {
+		c = &tenantCounters{}
+		s.tenants.entries[tenant] = c
+	}
+
+	return c
+}
+
+// tagConnection accounts the connection of ctx to the tenant of identity,
+// the one the client authenticated with, see commitIdentity.
+func (s *Server) tagConnection(ctx ssh.Context, identity Identity) {
+	if identity.Tenant == "" {
+		return
+	}
+
+	conn, ok := ctx.Value(contextKeyConnState).(*trackedConn)
+	if !ok {
+		return
+	}
+
+	conn.tag(identity.Tenant, s.tenantCounters(identity.Tenant))
+}
+
+// tenantOf returns the tenant the connection of ctx is tagged with.
+func tenantOf(ctx ssh.Context) string {
+	if identity, ok := IdentityFromContext(ctx); ok {
+		return identity.Tenant
+	}
+
+	return ""
+}
+
+// limitTenantSessions accounts the sessions handled by handler to their
+// tenants and enforces MaxTenantSessions.
+func (s *Server) limitTenantSessions(handler func(ssh.Session)) func(ssh.Session) {
+	return func(session ssh.Session) {
+		tenant := tenantOf(session.Context())
+		if tenant == "" {
+			handler(session)
+			return
+		}
+
+		c := s.tenantCounters(tenant)
+		if active := c.activeSessions.Add(1); s.MaxTenantSessions > 0 && active > int64(s.MaxTenantSessions) {
+			c.activeSessions.Add(-1)
+			log.Warnf("Rejecting session %s of tenant %s, %d sessions are active", session.Context().SessionID(), tenant, s.MaxTenantSessions)
+			_, _ = fmt.Fprintln(session.Stderr(), serverBusyMessage)
+			setCloseReason(session, CloseReasonPolicyDenied)
+			_ = session.Exit(1)
+			return
+		}
+		defer c.activeSessions.Add(-1)
+		c.sessions.Add(1)
+
+		handler(session)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestTenantUsage(t *testing.T) {
+	s := newTestServer(t)
+	s.MaxTenantSessions = 1
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{
+			"alice": {ID: "alice", Tenant: "acme"},
+			"bob":   {ID: "bob", Tenant: "acme"},
+			"carol": {ID: "carol", Tenant: "globex"},
+			"dave":  {ID: "dave"},
+		},
+	}
+	addr := startTestServer(t, s)
+
+	run := func(password, command string) error {
+		client := dialTestServer(t, addr, gossh.Password(password))
+		defer client.Close()
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		return session.Run(command)
+	}
+
+	require.NoError(t, run("alice", "echo hello"))
+	require.NoError(t, run("carol", "true"))
+	require.NoError(t, run("dave", "true"))
+
+	// A running session of alice keeps bob of the same tenant out.
+	alice := dialTestServer(t, addr, gossh.Password("alice"))
+	session, err := alice.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("cat"))
+	require.Eventually(t, func() bool {
+		return s.TenantUsage()["acme"].ActiveSessions == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	bob := dialTestServer(t, addr, gossh.Password("bob"))
+	rejected, err := bob.NewSession()
+	require.NoError(t, err)
+	defer rejected.Close()
+	var stderr bytes.Buffer
+	rejected.Stderr = &stderr
+	require.Error(t, rejected.Run("true"))
+	require.Contains(t, stderr.String(), serverBusyMessage)
+
+	// Other tenants aren't affected.
+	require.NoError(t, run("carol", "true"))
+
+	require.NoError(t, stdin.Close())
+	require.NoError(t, runWithTimeout(t, 5*time.Second, session.Wait))
+	require.NoError(t, alice.Close())
+	require.NoError(t, bob.Close())
+
+	require.Eventually(t, func() bool {
+		usage := s.TenantUsage()
+		return usage["acme"].ActiveConnections == 0 && usage["acme"].ActiveSessions == 0 &&
+			usage["globex"].ActiveConnections == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	usage := s.TenantUsage()
+	require.Len(t, usage, 2)
+	require.Equal(t, int64(3), usage["acme"].Connections)
+	require.Equal(t, int64(2), usage["acme"].Sessions)
+	require.Equal(t, int64(2), usage["globex"].Connections)
+	require.Equal(t, int64(2), usage["globex"].Sessions)
+	require.Positive(t, usage["acme"].BytesRead)
+	require.Positive(t, usage["acme"].BytesWritten)
+}
+
+// tenantKeyAuthenticator accepts the key of mockAuthenticator for tenant.
+type tenantKeyAuthenticator struct {
+	mockAuthenticator
+	tenant string
+}
+
+func (a *tenantKeyAuthenticator) AuthPublicKey(ctx ssh.Context, key ssh.PublicKey) (Identity, bool) {
+	identity, ok := a.mockAuthenticator.AuthPublicKey(ctx, key)
+	identity.Tenant = a.tenant
+	return identity, ok
+}
+
+func TestTenantOfQueriedKey(t *testing.T) {
+	signer := newTestSigner(t)
+
+	s := newTestServer(t)
+	s.Authenticator = &tenantKeyAuthenticator{
+		mockAuthenticator: mockAuthenticator{
+			key:       signer.PublicKey(),
+			passwords: map[string]Identity{"secret": {ID: "alice", Tenant: "acme"}},
+		},
+		tenant: "globex",
+	}
+	addr := startTestServer(t, s)
+
+	// The key is only queried, the password authenticates the client.
+	client := dialTestServer(t, addr, gossh.PublicKeys(querySigner{signer}), gossh.Password("secret"))
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	require.NoError(t, session.Run("true"))
+
+	usage := s.TenantUsage()
+	require.Len(t, usage, 1)
+	require.Equal(t, int64(1), usage["acme"].Connections)
+}
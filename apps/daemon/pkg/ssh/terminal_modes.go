@@ -0,0 +1,240 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/daytonaio/daemon/pkg/common"
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ptyRequestMsg is the payload of a "pty-req" request, RFC 4254 section 6.2.
+type ptyRequestMsg struct {
+	Term     string
+	Columns  uint32
+	Rows     uint32
+	Width    uint32
+	Height   uint32
+	Modelist string
+}
+
+// parseTerminalModes decodes the encoded terminal modes of a pty-req payload.
+func parseTerminalModes(payload []byte) gossh.TerminalModes {
+	var msg ptyRequestMsg
+	if err := gossh.Unmarshal(payload, &msg); err != nil {
+		return nil
+	}
+
+	modes := gossh.TerminalModes{}
+	list := []byte(msg.Modelist)
+	for len(list) >= 5 {
+		opcode := list[0]
+		// Opcodes from 160 on have arguments of unknown size, so parsing
+		// stops there just like at TTY_OP_END.
+		if opcode == 0 || opcode >= 160 {
+			break
+		}
+
+		modes[opcode] = binary.BigEndian.Uint32(list[1:5])
+		list = list[5:]
+	}
+
+	return modes
+}
+
+// withPtyRequests records the terminal modes of pty-req requests, which
+// gliderlabs/ssh discards while parsing them, and allocates the PTY right
+// away, so the reply to the request tells the client whether it succeeded.
+// Window sizes are kept here, too, see windowSizes. Requests exceeding the
+// limits of checkRequest are rejected beforehand.
+func withPtyRequests(factory common.PTYFactory, next ssh.ChannelHandler) ssh.ChannelHandler {
+	return func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+		next(srv, conn, &modesNewChannel{NewChannel: newChan, factory: factory, ctx: ctx}, ctx)
+	}
+}
+
+type modesNewChannel struct {
+	gossh.NewChannel
+	factory common.PTYFactory
+	ctx     ssh.Context
+}
+
+func (c *modesNewChannel) Accept() (gossh.Channel, <-chan *gossh.Request, error) {
+	ch, reqs, err := c.NewChannel.Accept()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel := &modesChannel{Channel: ch, sizes: newWindowSizes(), closed: make(chan struct{})}
+	out := make(chan *gossh.Request)
+	go func() {
+		// The requests end once the channel is closed.
+		defer close(channel.closed)
+		defer close(out)
+		defer close(channel.sizes)
+		// A PTY the session didn't take, e.g. because it ran a command, is
+		// released with the channel.
+		defer channel.releasePTY()
+
+		envVars := 0
+		for req := range reqs {
+			if err := checkRequest(req, envVars); err != nil {
+				log.Warnf("Rejecting %s request of session %s: %v", req.Type, c.ctx.SessionID(), err)
+				_ = req.Reply(false, nil)
+				continue
+			}
+			if req.Type == "env" {
+				envVars++
+			}
+
+			if req.Type == "pty-req" && !channel.ptyRequested() {
+				modes := parseTerminalModes(req.Payload)
+				pty, err := c.factory.Open(modes)
+				if err != nil {
+					log.Warnf("Failed to allocate PTY: %v", err)
+					_ = req.Reply(false, nil)
+					continue
+				}
+				channel.setPTY(pty, modes)
+				if size, ok := ptyRequestSize(req.Payload); ok {
+					channel.sizes.set(size)
+				}
+			}
+			if req.Type == "window-change" {
+				size, ok := windowChangeSize(req.Payload)
+				ok = ok && channel.ptyRequested()
+				if ok {
+					channel.sizes.set(size)
+				}
+				if req.WantReply {
+					_ = req.Reply(ok, nil)
+				}
+				continue
+			}
+			out <- req
+		}
+	}()
+
+	return channel, out, nil
+}
+
+type modesChannel struct {
+	gossh.Channel
+
+	mu        sync.Mutex
+	modes     gossh.TerminalModes
+	requested bool
+	pty       common.PTY
+	sizes     windowSizes
+	closed    chan struct{}
+}
+
+func (c *modesChannel) setPTY(pty common.PTY, modes gossh.TerminalModes) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pty = pty
+	c.modes = modes
+	c.requested = true
+}
+
+func (c *modesChannel) ptyRequested() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.requested
+}
+
+func (c *modesChannel) terminalModes() gossh.TerminalModes {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.modes
+}
+
+func (c *modesChannel) takePTY() common.PTY {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pty := c.pty
+	c.pty = nil
+	return pty
+}
+
+func (c *modesChannel) releasePTY() {
+	if pty := c.takePTY(); pty != nil {
+		_ = pty.Close()
+	}
+}
+
+// Stderr is the only method of the channel reachable through ssh.Session that
+// returns a value of our choosing, so it carries the modes to the handler.
+func (c *modesChannel) Stderr() io.ReadWriter {
+	return &modesStderr{ReadWriter: c.Channel.Stderr(), channel: c}
+}
+
+type modesStderr struct {
+	io.ReadWriter
+	channel *modesChannel
+}
+
+func sessionChannel(session ssh.Session) *modesChannel {
+	if tracked, ok := session.(*trackedSession); ok {
+		session = tracked.Session
+	}
+
+	if stderr, ok := session.Stderr().(*modesStderr); ok {
+		return stderr.channel
+	}
+
+	return nil
+}
+
+// terminalModes returns the terminal modes the client requested for the PTY
+// of session.
+func terminalModes(session ssh.Session) gossh.TerminalModes {
+	if channel := sessionChannel(session); channel != nil {
+		return channel.terminalModes()
+	}
+
+	return nil
+}
+
+// sessionPTY hands the PTY allocated for session over to the caller, which
+// becomes responsible for closing it. It returns nil if none was allocated.
+func sessionPTY(session ssh.Session) common.PTY {
+	if channel := sessionChannel(session); channel != nil {
+		return channel.takePTY()
+	}
+
+	return nil
+}
+
+// sessionContext returns a context of session that is also done once its
+// channel is closed, e.g. by a client closing the session without
+// disconnecting, so commands bound to it don't outlive the session.
+func sessionContext(session ssh.Session) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(session.Context())
+
+	channel := sessionChannel(session)
+	if channel == nil {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-channel.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gliderlabs/ssh"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Clients join sessions into their traces by sending the W3C trace context
+// in these variables, independent of AcceptEnv.
+const (
+	traceparentEnv = "TRACEPARENT"
+	tracestateEnv  = "TRACESTATE"
+)
+
+// startConnSpan starts the span of a new connection, which ends once the
+// connection is closed. It returns nil if tracing is off.
+func (s *Server) startConnSpan(c *trackedConn) trace.Span {
+	if s.Tracer == nil {
+		return nil
+	}
+
+	_, span := s.Tracer.Start(context.Background(), "ssh.connection",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithTimestamp(c.started),
+		trace.WithAttributes(attribute.String("net.peer.ip", addrIP(c.RemoteAddr()).String())),
+	)
+	return span
+}
+
+func (c *trackedConn) endSpan(tenant string) {
+	if c.span == nil {
+		return
+	}
+
+	c.span.SetAttributes(
+		attribute.String("ssh.tenant", tenant),
+		attribute.String("ssh.close_reason", string(c.closeReason())),
+		attribute.Int64("ssh.bytes_read", c.bytesRead.Load()),
+		attribute.Int64("ssh.bytes_written", c.bytesWritten.Load()),
+		attribute.Int64("ssh.sessions", c.sessions.Load()),
+		attribute.Int64("ssh.forwards", c.forwards.Load()),
+	)
+	c.span.End()
+}
+
+// startSessionSpan starts the span of a session as child of the trace context
+// sent by the client, linked to the span of its connection, or else as child
+// of the connection span. It returns nil if tracing is off.
+func (s *Server) startSessionSpan(session ssh.Session) trace.Span {
+	if s.Tracer == nil {
+		return nil
+	}
+
+	var connSpan trace.Span
+	if c, ok := session.Context().Value(contextKeyConnState).(*trackedConn); ok && c.span != nil {
+		connSpan = c.span
+		connSpan.SetAttributes(attribute.String("ssh.user", session.User()))
+	}
+
+	ctx := context.Background()
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("ssh.session_id", session.Context().SessionID()),
+			attribute.String("ssh.user", session.User()),
+			attribute.String("ssh.subsystem", session.Subsystem()),
+			attribute.String("ssh.command", session.RawCommand()),
+		),
+	}
+
+	if remote := clientTraceContext(session.Environ()); remote.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, remote)
+		if connSpan != nil {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: connSpan.SpanContext()}))
+		}
+	} else if connSpan != nil {
+		ctx = trace.ContextWithSpan(ctx, connSpan)
+	}
+
+	_, span := s.Tracer.Start(ctx, "ssh.session", opts...)
+	return span
+}
+
+func endSessionSpan(span trace.Span, end SessionEnd, bytesIn, bytesOut int64) {
+	if span == nil {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("ssh.exit_code", end.ExitCode),
+		attribute.String("ssh.close_reason", string(end.Reason)),
+		attribute.Int64("ssh.bytes_in", bytesIn),
+		attribute.Int64("ssh.bytes_out", bytesOut),
+	)
+	if end.Reason == CloseReasonError {
+		span.SetStatus(codes.Error, "session failed")
+	}
+	span.End()
+}
+
+// clientTraceContext extracts the span context of the TRACEPARENT and
+// TRACESTATE variables in env.
+func clientTraceContext(env []string) trace.SpanContext {
+	carrier := propagation.MapCarrier{}
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		switch name {
+		case traceparentEnv, tracestateEnv:
+			carrier.Set(strings.ToLower(name), value)
+		}
+	}
+
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
+
+// countingWriter counts the bytes written to the stderr of a session.
+type countingWriter struct {
+	io.ReadWriter
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ReadWriter.Write(p)
+	w.n.Add(int64(n))
+	return n, err
+}
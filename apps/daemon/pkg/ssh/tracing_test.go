@@ -0,0 +1,138 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+// findSpan returns the span with the given name and, if not empty, command.
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name, command string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name == name && (command == "" || spanAttributes(span)["ssh.command"].AsString() == command) {
+			return span
+		}
+	}
+
+	t.Fatalf("no span %s with command %q", name, command)
+	return tracetest.SpanStub{}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	s := newTestServer(t)
+	s.Tracer = provider.Tracer("test")
+	s.AcceptEnv = []string{}
+	addr := startTestServer(t, s)
+
+	waitForSpans := func(t *testing.T, count int) tracetest.SpanStubs {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			return len(exporter.GetSpans()) >= count
+		}, 5*time.Second, 10*time.Millisecond)
+		return exporter.GetSpans()
+	}
+
+	t.Run("connection and sessions", func(t *testing.T) {
+		exporter.Reset()
+		client := dialTestServer(t, addr)
+
+		for _, command := range []string{"echo hello", "exit 3"} {
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			_ = session.Run(command)
+			session.Close()
+		}
+		require.NoError(t, client.Close())
+
+		spans := waitForSpans(t, 3)
+		require.Len(t, spans, 3)
+
+		conn := findSpan(t, spans, "ssh.connection", "")
+		require.Equal(t, "daytona", spanAttributes(conn)["ssh.user"].AsString())
+		require.Greater(t, spanAttributes(conn)["ssh.bytes_written"].AsInt64(), int64(0))
+
+		for _, expected := range []struct {
+			command  string
+			exitCode int64
+			bytesOut int64
+		}{
+			{"echo hello", 0, int64(len("hello\n"))},
+			{"exit 3", 3, 0},
+		} {
+			span := findSpan(t, spans, "ssh.session", expected.command)
+			attrs := spanAttributes(span)
+			require.Equal(t, conn.SpanContext.SpanID(), span.Parent.SpanID())
+			require.Equal(t, conn.SpanContext.TraceID(), span.SpanContext.TraceID())
+			require.Equal(t, "daytona", attrs["ssh.user"].AsString())
+			require.Equal(t, expected.command, attrs["ssh.command"].AsString())
+			require.Equal(t, expected.exitCode, attrs["ssh.exit_code"].AsInt64())
+			require.Equal(t, expected.bytesOut, attrs["ssh.bytes_out"].AsInt64())
+		}
+	})
+
+	t.Run("client trace context", func(t *testing.T) {
+		exporter.Reset()
+		client := dialTestServer(t, addr)
+
+		traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		require.NoError(t, err)
+		spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+		require.NoError(t, err)
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		// The variable is read even though AcceptEnv rejects it.
+		require.NoError(t, session.Setenv(traceparentEnv, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+		require.NoError(t, session.Run("true"))
+		session.Close()
+		require.NoError(t, client.Close())
+
+		spans := waitForSpans(t, 2)
+		session0, conn := findSpan(t, spans, "ssh.session", "true"), findSpan(t, spans, "ssh.connection", "")
+		require.Equal(t, traceID, session0.SpanContext.TraceID())
+		require.Equal(t, spanID, session0.Parent.SpanID())
+		require.True(t, session0.Parent.IsRemote())
+		require.Len(t, session0.Links, 1)
+		require.Equal(t, conn.SpanContext.SpanID(), session0.Links[0].SpanContext.SpanID())
+	})
+}
+
+func TestClientTraceContext(t *testing.T) {
+	for name, tc := range map[string]struct {
+		env   []string
+		valid bool
+	}{
+		"none":      {env: []string{"LANG=C"}},
+		"valid":     {env: []string{"TRACEPARENT=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "TRACESTATE=vendor=value"}, valid: true},
+		"malformed": {env: []string{"TRACEPARENT=garbage"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sc := clientTraceContext(tc.env)
+			require.Equal(t, tc.valid, sc.IsValid())
+		})
+	}
+
+	sc := clientTraceContext([]string{"TRACEPARENT=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "TRACESTATE=vendor=value"})
+	require.Equal(t, "value", sc.TraceState().Get("vendor"))
+}
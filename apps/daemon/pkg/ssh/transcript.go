@@ -0,0 +1,133 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultTranscriptRetention = 10 * time.Minute
+
+// ErrTranscriptNotFound is returned for sessions without a transcript, either
+// because they are unknown or their transcript expired.
+var ErrTranscriptNotFound = errors.New("transcript not found")
+
+// transcript keeps the last output of a shell or command session.
+type transcript struct {
+	mu        sync.Mutex
+	buf       []byte
+	size      int
+	active    bool
+	ended     time.Time
+	sanitizer *sanitizer
+}
+
+func (t *transcript) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	p = t.sanitizer.sanitize(p)
+
+	if len(p) >= t.size {
+		t.buf = append(t.buf[:0], p[len(p)-t.size:]...)
+		return n, nil
+	}
+
+	if overflow := len(t.buf) + len(p) - t.size; overflow > 0 {
+		t.buf = t.buf[:copy(t.buf, t.buf[overflow:])]
+	}
+	t.buf = append(t.buf, p...)
+
+	return n, nil
+}
+
+func (t *transcript) snapshot() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return bytes.Clone(t.buf)
+}
+
+type transcripts struct {
+	mu      sync.Mutex
+	entries map[string]*transcript
+}
+
+// SessionTranscript returns the recorded output of the session with the given
+// ID, as listed by Sessions and in audit records, while it is active or for
+// TranscriptRetention after it ended. Output is only recorded if
+// TranscriptSize is set.
+func (s *Server) SessionTranscript(id string) (io.ReadCloser, error) {
+	s.transcripts.mu.Lock()
+	defer s.transcripts.mu.Unlock()
+
+	s.expireTranscriptsLocked()
+
+	t, ok := s.transcripts.entries[id]
+	if !ok {
+		return nil, ErrTranscriptNotFound
+	}
+
+	return io.NopCloser(bytes.NewReader(t.snapshot())), nil
+}
+
+// startTranscript returns the transcript the output of the new session with
+// the given id is recorded to, or nil if recording is off.
+func (s *Server) startTranscript(id string) *transcript {
+	if s.TranscriptSize <= 0 {
+		return nil
+	}
+
+	s.transcripts.mu.Lock()
+	defer s.transcripts.mu.Unlock()
+
+	s.expireTranscriptsLocked()
+
+	if s.transcripts.entries == nil {
+		s.transcripts.entries = map[string]*transcript{}
+	}
+
+	t := &transcript{size: s.TranscriptSize, active: true, sanitizer: newSanitizer(s.SanitizeRecordings)}
+	s.transcripts.entries[id] = t
+
+	return t
+}
+
+func (s *Server) endTranscript(t *transcript) {
+	s.transcripts.mu.Lock()
+	defer s.transcripts.mu.Unlock()
+
+	t.active = false
+	t.ended = time.Now()
+}
+
+func (s *Server) expireTranscriptsLocked() {
+	retention := s.TranscriptRetention
+	if retention <= 0 {
+		retention = defaultTranscriptRetention
+	}
+
+	for id, t := range s.transcripts.entries {
+		if !t.active && time.Since(t.ended) > retention {
+			delete(s.transcripts.entries, id)
+		}
+	}
+}
+
+// transcriptWriter copies the output written to the client to the transcript.
+type transcriptWriter struct {
+	io.ReadWriter
+	transcript *transcript
+}
+
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	n, err := w.ReadWriter.Write(p)
+	_, _ = w.transcript.Write(p[:n])
+	return n, err
+}
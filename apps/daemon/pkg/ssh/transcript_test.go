@@ -0,0 +1,86 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscriptKeepsLastOutput(t *testing.T) {
+	tr := &transcript{size: 8}
+
+	for _, chunk := range []string{"abc", "defgh", "ij", "klmnopqrstuvwxyz"} {
+		_, err := tr.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	require.Equal(t, "stuvwxyz", string(tr.snapshot()))
+
+	_, err := tr.Write([]byte("0"))
+	require.NoError(t, err)
+	require.Equal(t, "tuvwxyz0", string(tr.snapshot()))
+}
+
+func TestSessionTranscript(t *testing.T) {
+	s := newTestServer(t)
+	s.TranscriptSize = 1 << 10
+	s.TranscriptRetention = 200 * time.Millisecond
+
+	client := dialTestServer(t, startTestServer(t, s))
+
+	_, err := s.SessionTranscript("1")
+	require.ErrorIs(t, err, ErrTranscriptNotFound)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("echo out; echo err >&2; read line"))
+
+	_, err = io.ReadFull(stdout, make([]byte, len("out\n")))
+	require.NoError(t, err)
+	sessions := s.Sessions()
+	require.Len(t, sessions, 1)
+	id := sessions[0].ID
+
+	// Other sessions of the connection have transcripts of their own.
+	other, err := client.NewSession()
+	require.NoError(t, err)
+	output, err := other.Output("echo other")
+	require.NoError(t, err)
+	require.Equal(t, "other\n", string(output))
+
+	require.Eventually(t, func() bool {
+		r, err := s.SessionTranscript(id)
+		require.NoError(t, err)
+		defer r.Close()
+
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		// stdout and stderr are copied independently and may be reordered.
+		return len(content) == len("out\nerr\n") &&
+			strings.Contains(string(content), "out\n") && strings.Contains(string(content), "err\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The transcript outlives the session for the retention period only.
+	_, err = io.WriteString(stdin, "\n")
+	require.NoError(t, err)
+	require.NoError(t, session.Wait())
+
+	_, err = s.SessionTranscript(id)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := s.SessionTranscript(id)
+		return err == ErrTranscriptNotFound
+	}, 5*time.Second, 50*time.Millisecond)
+}
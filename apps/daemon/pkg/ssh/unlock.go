@@ -0,0 +1,75 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var contextKeyUnlocked = &contextKey{"unlocked"}
+
+const (
+	defaultUnlockAttempts = 3
+
+	unlockPrompt      = "Workspace passphrase: "
+	unlockRetryPrompt = "Unlocking the workspace failed, try again."
+)
+
+var errWorkspaceLocked = errors.New("workspace is locked, authenticate with keyboard-interactive to unlock it")
+
+// unlockHandler authenticates clients with the passphrase unlocking the
+// workspace. Wrong passphrases are asked for again within the same attempt,
+// so clients don't need to reconnect, up to UnlockAttempts times.
+func (s *Server) unlockHandler(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
+	attempts := s.UnlockAttempts
+	if attempts <= 0 {
+		attempts = defaultUnlockAttempts
+	}
+
+	instruction := ""
+	for i := 0; i < attempts; i++ {
+		answers, err := challenger(ctx.User(), instruction, []string{unlockPrompt}, []bool{false})
+		if err != nil || len(answers) != 1 {
+			return false
+		}
+
+		if err := s.UnlockFunc(ctx, answers[0]); err != nil {
+			log.Warnf("Failed to unlock workspace for user %s: %v", ctx.User(), err)
+			// The error may tell more about the workspace than the client
+			// should know, so it isn't passed on.
+			instruction = unlockRetryPrompt
+			continue
+		}
+
+		ctx.SetValue(contextKeyUnlocked, true)
+		// The passphrase doesn't identify anyone, keys the client only
+		// queried before don't count.
+		ctx.SetValue(contextKeyKeyOptions, nil)
+		authenticated(ctx, nil)
+		return true
+	}
+
+	log.Warnf("Rejecting user %s after %d failed attempts to unlock the workspace", ctx.User(), attempts)
+	s.authFailed(ctx)
+	return false
+}
+
+// checkUnlocked fails for connections that didn't unlock the workspace if
+// UnlockFunc is set.
+func (s *Server) checkUnlocked(ctx ssh.Context) error {
+	if s.UnlockFunc == nil {
+		return nil
+	}
+
+	if unlocked, _ := ctx.Value(contextKeyUnlocked).(bool); !unlocked {
+		return errWorkspaceLocked
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// passphrases answers the prompts of keyboard-interactive authentication with
+// the given passphrases in order and records the instructions it was sent.
+type passphrases struct {
+	mu           sync.Mutex
+	answers      []string
+	instructions []string
+}
+
+func (p *passphrases) challenge(user, instruction string, questions []string, echos []bool) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(questions) == 0 {
+		return nil, nil
+	}
+	p.instructions = append(p.instructions, instruction)
+	if len(p.answers) == 0 {
+		return nil, errors.New("no passphrase left")
+	}
+
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return []string{answer}, nil
+}
+
+func TestUnlock(t *testing.T) {
+	var unlocked []string
+	var mu sync.Mutex
+
+	s := newTestServer(t)
+	s.UnlockFunc = func(ctx ssh.Context, passphrase string) error {
+		if passphrase != "correct horse" {
+			return errors.New("bad key")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		unlocked = append(unlocked, ctx.User())
+		return nil
+	}
+	addr := startTestServer(t, s)
+
+	dial := func(p *passphrases) (*gossh.Client, error) {
+		return gossh.Dial("tcp", addr, &gossh.ClientConfig{
+			User:            "daytona",
+			Auth:            []gossh.AuthMethod{gossh.RetryableAuthMethod(gossh.KeyboardInteractive(p.challenge), 1)},
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			Timeout:         5 * time.Second,
+		})
+	}
+
+	t.Run("correct passphrase", func(t *testing.T) {
+		p := &passphrases{answers: []string{"correct horse"}}
+		client, err := dial(p)
+		require.NoError(t, err)
+		defer client.Close()
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("echo unlocked")
+		require.NoError(t, err)
+		require.Equal(t, "unlocked\n", string(output))
+		require.Equal(t, []string{""}, p.instructions)
+	})
+
+	t.Run("reprompted", func(t *testing.T) {
+		p := &passphrases{answers: []string{"wrong", "correct horse"}}
+		client, err := dial(p)
+		require.NoError(t, err)
+		defer client.Close()
+
+		require.Equal(t, []string{"", unlockRetryPrompt}, p.instructions)
+	})
+
+	t.Run("incorrect passphrases", func(t *testing.T) {
+		p := &passphrases{answers: []string{"wrong", "still wrong", "nope", "correct horse"}}
+		_, err := dial(p)
+		require.Error(t, err)
+
+		// The fourth passphrase was never asked for.
+		require.Len(t, p.instructions, defaultUnlockAttempts)
+		require.Equal(t, []string{"correct horse"}, p.answers)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"daytona", "daytona"}, unlocked)
+}
+
+func TestUnlockRequiredForSessions(t *testing.T) {
+	signer := newTestSigner(t)
+
+	s := newTestServer(t)
+	s.Authenticator = &mockAuthenticator{key: signer.PublicKey()}
+	s.UnlockFunc = func(ctx ssh.Context, passphrase string) error {
+		return nil
+	}
+	client := dialTestServer(t, startTestServer(t, s), gossh.PublicKeys(signer))
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	err = session.Run("echo locked")
+
+	var exitErr *gossh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitStatus())
+	require.Equal(t, errWorkspaceLocked.Error()+"\n", stderr.String())
+}
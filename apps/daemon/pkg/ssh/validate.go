@@ -0,0 +1,143 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/daytonaio/daemon/pkg/ssh/config"
+)
+
+// Validate checks the configuration without starting the server, so
+// misconfigurations surface at startup rather than on the first connection.
+// It reports all problems found. Host keys aren't checked, they are parsed
+// before they are passed in HostKeys.
+func (s *Server) Validate() error {
+	var errs []error
+
+	if err := s.validateAlgorithms(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := net.ResolveTCPAddr("tcp", fmt.Sprintf(":%d", config.SSH_PORT)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid listen address: %w", err))
+	}
+	for _, l := range s.Listeners {
+		if _, err := net.ResolveTCPAddr("tcp", l.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid listen address %q: %w", l.Addr, err))
+		}
+	}
+
+	if _, err := s.resolveProjectDir(""); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.Authenticator == nil && s.AuthorizedKeysFile != "" {
+		if _, err := loadAuthorizedKeys(s.AuthorizedKeysFile); err != nil {
+			errs = append(errs, fmt.Errorf("failed to load authorized keys: %w", err))
+		}
+	}
+
+	if s.EnvFile != "" {
+		if _, err := loadEnvFile(s.EnvFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid env file %s: %w", s.EnvFile, err))
+		}
+	}
+
+	if s.BannerFile != "" {
+		if _, err := os.ReadFile(s.BannerFile); err != nil {
+			errs = append(errs, fmt.Errorf("failed to load banner: %w", err))
+		}
+	}
+
+	if _, _, err := s.parseTemplates(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.SFTPRoot != "" {
+		if info, err := os.Stat(s.SFTPRoot); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sftp root: %w", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("invalid sftp root: %s is not a directory", s.SFTPRoot))
+		}
+	}
+
+	if s.SFTPUploadOnly && s.SFTPDownloadOnly {
+		errs = append(errs, errors.New("sftp upload-only and download-only modes are mutually exclusive"))
+	}
+
+	for _, pattern := range s.SFTPDeniedPaths {
+		if err := validatePathPattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sftp denied path %q: %w", pattern, err))
+		}
+	}
+
+	for _, sig := range s.AllowedSignals {
+		if _, ok := sshSignals[sig]; !ok {
+			errs = append(errs, fmt.Errorf("unknown allowed signal %q", sig))
+		}
+	}
+
+	if s.IdleTimeout > 0 && s.MaxDuration > 0 && s.IdleTimeout >= s.MaxDuration {
+		errs = append(errs, fmt.Errorf("idle timeout %s must be shorter than the max duration %s", s.IdleTimeout, s.MaxDuration))
+	}
+
+	if !s.SanitizeRecordings.valid() {
+		errs = append(errs, fmt.Errorf("unknown recording sanitize mode %q", s.SanitizeRecordings))
+	}
+
+	if _, err := commandCloneflags(s.CommandNamespaces); err != nil {
+		errs = append(errs, fmt.Errorf("invalid command namespaces: %w", err))
+	}
+
+	if s.SessionQueueTimeout > 0 && s.MaxSessions <= 0 {
+		errs = append(errs, errors.New("session queue timeout requires max sessions"))
+	}
+
+	for _, option := range []struct {
+		name  string
+		value int
+	}{
+		{"max sessions", s.MaxSessions},
+		{"max sftp sessions", s.MaxSFTPSessions},
+		{"max command length", s.MaxCommandLength},
+		{"transcript size", s.TranscriptSize},
+		{"max output lines", s.MaxOutputLines},
+		{"sftp buffer size", s.SFTPBufferSize},
+		{"listen backlog", s.ListenBacklog},
+		{"unlock attempts", s.UnlockAttempts},
+		{"pty output buffer", s.PTYOutputBuffer},
+		{"pty output high water", s.PTYOutputHighWater},
+		{"log stream rate", s.LogStreamRate},
+		{"max connection rate", s.MaxConnectionRate},
+		{"connection burst", s.ConnectionBurst},
+	} {
+		if option.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", option.name, option.value))
+		}
+	}
+
+	for _, option := range []struct {
+		name  string
+		value int
+	}{
+		{"interactive nice", s.InteractiveNice},
+		{"batch nice", s.BatchNice},
+	} {
+		if option.value < -20 || option.value > 19 {
+			errs = append(errs, fmt.Errorf("%s must be between -20 and 19, got %d", option.name, option.value))
+		}
+	}
+
+	for _, cpu := range s.CPUAffinity {
+		if cpu < 0 {
+			errs = append(errs, fmt.Errorf("invalid CPU %d in CPU affinity", cpu))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, newTestServer(t).Validate())
+
+	dir := t.TempDir()
+	invalidEnv := filepath.Join(dir, "env")
+	require.NoError(t, os.WriteFile(invalidEnv, []byte("not a variable\n"), 0o600))
+
+	for name, tc := range map[string]struct {
+		configure func(s *Server)
+		expected  []string
+	}{
+		"unsupported cipher": {
+			configure: func(s *Server) { s.Ciphers = []string{"rot13"} },
+			expected:  []string{`unsupported cipher algorithm "rot13"`},
+		},
+		"missing authorized keys": {
+			configure: func(s *Server) { s.AuthorizedKeysFile = filepath.Join(dir, "missing") },
+			expected:  []string{"failed to load authorized keys"},
+		},
+		"invalid env file": {
+			configure: func(s *Server) { s.EnvFile = invalidEnv },
+			expected:  []string{"invalid env file"},
+		},
+		"missing project dirs": {
+			configure: func(s *Server) {
+				s.ProjectDir = filepath.Join(dir, "missing")
+				s.DefaultProjectDir = ""
+			},
+			expected: []string{"project directory is unavailable"},
+		},
+		"unknown namespace": {
+			configure: func(s *Server) { s.CommandNamespaces = []string{"pid", "time"} },
+			expected:  []string{`invalid command namespaces: unknown namespace "time"`},
+		},
+		"invalid templates": {
+			configure: func(s *Server) {
+				s.BannerTemplate = "{{.User"
+				s.WelcomeTemplate = "{{end}}"
+			},
+			expected: []string{"invalid banner template", "invalid welcome template"},
+		},
+		"conflicting sftp modes": {
+			configure: func(s *Server) {
+				s.SFTPUploadOnly = true
+				s.SFTPDownloadOnly = true
+			},
+			expected: []string{"sftp upload-only and download-only modes are mutually exclusive"},
+		},
+		"invalid sftp denied path": {
+			configure: func(s *Server) { s.SFTPDeniedPaths = []string{"**/.env", "**/[id_*"} },
+			expected:  []string{`invalid sftp denied path "**/[id_*"`},
+		},
+		"unknown allowed signal": {
+			configure: func(s *Server) { s.AllowedSignals = []ssh.Signal{ssh.SIGINT, "WINCH"} },
+			expected:  []string{`unknown allowed signal "WINCH"`},
+		},
+		"sftp root is a file": {
+			configure: func(s *Server) { s.SFTPRoot = invalidEnv },
+			expected:  []string{"is not a directory"},
+		},
+		"inconsistent timeouts": {
+			configure: func(s *Server) {
+				s.IdleTimeout = time.Hour
+				s.MaxDuration = time.Minute
+				s.SessionQueueTimeout = time.Second
+			},
+			expected: []string{"must be shorter than the max duration", "session queue timeout requires max sessions"},
+		},
+		"out of range values": {
+			configure: func(s *Server) {
+				s.MaxSessions = -1
+				s.BatchNice = 20
+				s.CPUAffinity = []int{0, -1}
+				s.SanitizeRecordings = "html"
+			},
+			expected: []string{"max sessions must not be negative", "batch nice must be between", "invalid CPU -1", `unknown recording sanitize mode "html"`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			tc.configure(s)
+
+			err := s.Validate()
+			require.Error(t, err)
+			for _, expected := range tc.expected {
+				require.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
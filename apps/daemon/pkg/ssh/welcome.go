@@ -0,0 +1,63 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultWelcomeTimeout = 5 * time.Second
+
+// showWelcome runs WelcomeCommand and writes its stdout to the PTY session
+// before the shell starts. Failures are logged and never keep the shell from
+// starting.
+func (s *Server) showWelcome(session ssh.Session, term string, env []string) {
+	if s.WelcomeCommand == "" {
+		return
+	}
+
+	timeout := s.WelcomeTimeout
+	if timeout <= 0 {
+		timeout = defaultWelcomeTimeout
+	}
+	ctx, cancel := context.WithTimeout(session.Context(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", s.WelcomeCommand)
+	cmd.Env = append(s.daemonEnv(), fmt.Sprintf("TERM=%s", term))
+	cmd.Env = append(cmd.Env, env...)
+	if dir, err := s.resolveProjectDir(s.workspaceDir(session)); err == nil {
+		cmd.Dir = dir
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Background processes holding stdout open don't delay the shell either.
+	cmd.WaitDelay = 100 * time.Millisecond
+
+	output, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Warnf("Welcome command %q timed out after %s", s.WelcomeCommand, timeout)
+	} else if err != nil {
+		log.Warnf("Welcome command %q failed: %v", s.WelcomeCommand, err)
+	}
+
+	// The output doesn't pass through the line discipline of the PTY, which
+	// would translate line endings for the client terminal.
+	output = bytes.ReplaceAll(output, []byte("\n"), []byte("\r\n"))
+	if _, err := session.Write(output); err != nil {
+		logSessionError(log.WarnLevel, err, "Unable to write welcome message: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"github.com/daytonaio/daemon/pkg/common"
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// windowChangeMsg is the payload of a "window-change" request, RFC 4254
+// section 6.7.
+type windowChangeMsg struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+// windowSizes keeps the latest window size of a PTY session until it is read.
+// Sizes are taken from the requests of the channel rather than from
+// gliderlabs/ssh, which buffers a single window-change and blocks the other
+// requests of the session on the next one until the shell started.
+type windowSizes chan common.TTYSize
+
+func newWindowSizes() windowSizes {
+	return make(windowSizes, 1)
+}
+
+// set replaces the pending size. It must only be called by the goroutine
+// handling the requests of the channel, so the send never blocks once a stale
+// size was dropped.
+func (w windowSizes) set(size common.TTYSize) {
+	select {
+	case <-w:
+	default:
+	}
+	w <- size
+}
+
+func ptyRequestSize(payload []byte) (common.TTYSize, bool) {
+	var msg ptyRequestMsg
+	if err := gossh.Unmarshal(payload, &msg); err != nil {
+		return common.TTYSize{}, false
+	}
+	return common.TTYSize{Height: int(msg.Rows), Width: int(msg.Columns)}, true
+}
+
+func windowChangeSize(payload []byte) (common.TTYSize, bool) {
+	var msg windowChangeMsg
+	if err := gossh.Unmarshal(payload, &msg); err != nil {
+		return common.TTYSize{}, false
+	}
+	return common.TTYSize{Height: int(msg.Rows), Width: int(msg.Columns)}, true
+}
+
+// ptySizes returns the window sizes requested for the PTY of session, the
+// latest of those requested before the shell started first.
+func ptySizes(session ssh.Session, winCh <-chan ssh.Window) <-chan common.TTYSize {
+	if channel := sessionChannel(session); channel != nil {
+		return channel.sizes
+	}
+
+	sizes := make(chan common.TTYSize)
+	go func() {
+		defer close(sizes)
+		for win := range winCh {
+			sizes <- common.TTYSize{Height: win.Height, Width: win.Width}
+		}
+	}()
+	return sizes
+}
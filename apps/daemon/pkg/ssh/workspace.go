@@ -0,0 +1,99 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultWorkspaceEnv = "DAYTONA_WORKSPACE"
+
+// workspaceDir returns the directory WorkspaceResolver resolves the workspace
+// of session to, or "" to use the project directory.
+func (s *Server) workspaceDir(session ssh.Session) string {
+	if s.WorkspaceResolver == nil {
+		return ""
+	}
+
+	name, fromEnv := s.workspaceName(session)
+	if name == "" {
+		return ""
+	}
+
+	dir, err := s.WorkspaceResolver(name)
+	if err == nil && !filepath.IsAbs(dir) {
+		err = fmt.Errorf("resolved to relative path %q", dir)
+	}
+	if err != nil {
+		// Most user names don't name a workspace, only an explicitly requested
+		// one is worth a warning.
+		level := log.DebugLevel
+		if fromEnv {
+			level = log.WarnLevel
+		}
+		log.StandardLogger().Logf(level, "Ignoring workspace %q of session %s: %v", name, session.Context().SessionID(), err)
+		return ""
+	}
+
+	return dir
+}
+
+// workspaceName returns the name of the workspace of session and whether it
+// was selected explicitly rather than by the user name. Clients whose identity
+// is bound to a workspace always get that one, see checkWorkspace.
+func (s *Server) workspaceName(session ssh.Session) (string, bool) {
+	if identity, ok := IdentityFromContext(session.Context()); ok && identity.WorkspaceID != "" {
+		return identity.WorkspaceID, true
+	}
+
+	return s.requestedWorkspace(session)
+}
+
+// requestedWorkspace returns the name of the workspace the client selected and
+// whether it was selected with WorkspaceEnv rather than the user name.
+func (s *Server) requestedWorkspace(session ssh.Session) (string, bool) {
+	key := s.WorkspaceEnv
+	if key == "" {
+		key = defaultWorkspaceEnv
+	}
+
+	for _, env := range session.Environ() {
+		if name, ok := strings.CutPrefix(env, key+"="); ok && name != "" {
+			return name, true
+		}
+	}
+
+	return session.User(), false
+}
+
+// checkWorkspace fails for sessions selecting another workspace than the one
+// the identity of the client is bound to. User names that don't name a
+// workspace don't select one.
+func (s *Server) checkWorkspace(session ssh.Session) error {
+	if s.WorkspaceResolver == nil {
+		return nil
+	}
+	identity, ok := IdentityFromContext(session.Context())
+	if !ok || identity.WorkspaceID == "" {
+		return nil
+	}
+
+	name, fromEnv := s.requestedWorkspace(session)
+	if name == identity.WorkspaceID {
+		return nil
+	}
+	if !fromEnv {
+		if _, err := s.WorkspaceResolver(name); err != nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("access to workspace %q denied", name)
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Daytona Platforms Inc.
+// SPDX-License-Identifier: AGPL-3.0
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestWorkspaceResolver(t *testing.T) {
+	alpha := t.TempDir()
+
+	s := newTestServer(t)
+	s.WorkspaceResolver = func(name string) (string, error) {
+		switch name {
+		case "alpha":
+			return alpha, nil
+		case "relative":
+			return "alpha", nil
+		default:
+			return "", errors.New("unknown workspace")
+		}
+	}
+	addr := startTestServer(t, s)
+
+	for _, tc := range []struct {
+		name string
+		user string
+		env  string
+		want string
+	}{
+		{name: "user name", user: "alpha", want: alpha},
+		{name: "env", user: "daytona", env: "alpha", want: alpha},
+		{name: "env over user name", user: "beta", env: "alpha", want: alpha},
+		{name: "unknown user name", user: "beta", want: s.ProjectDir},
+		{name: "unknown env", user: "alpha", env: "beta", want: s.ProjectDir},
+		{name: "relative", user: "relative", want: s.ProjectDir},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := dialTestServerAs(t, addr, tc.user)
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			if tc.env != "" {
+				require.NoError(t, session.Setenv(defaultWorkspaceEnv, tc.env))
+			}
+
+			output, err := session.Output("pwd")
+			require.NoError(t, err)
+			require.Equal(t, tc.want+"\n", string(output))
+		})
+	}
+}
+
+func TestWorkspaceResolverBoundIdentity(t *testing.T) {
+	alpha := t.TempDir()
+	beta := t.TempDir()
+
+	s := newTestServer(t)
+	s.Authenticator = &mockAuthenticator{
+		passwords: map[string]Identity{"secret": {ID: "alice", WorkspaceID: "alpha"}},
+	}
+	s.WorkspaceResolver = func(name string) (string, error) {
+		switch name {
+		case "alpha":
+			return alpha, nil
+		case "beta":
+			return beta, nil
+		default:
+			return "", errors.New("unknown workspace")
+		}
+	}
+	addr := startTestServer(t, s)
+
+	for name, tc := range map[string]struct {
+		user string
+		env  string
+		want string
+		err  bool
+	}{
+		"bound workspace":      {user: "daytona", want: alpha},
+		"selected by env":      {user: "daytona", env: "alpha", want: alpha},
+		"other by env":         {user: "daytona", env: "beta", err: true},
+		"other by user name":   {user: "beta", err: true},
+		"unknown by env":       {user: "daytona", env: "gamma", err: true},
+		"unknown by user name": {user: "gamma", want: alpha},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := dialTestServerAs(t, addr, tc.user, gossh.Password("secret"))
+
+			session, err := client.NewSession()
+			require.NoError(t, err)
+			defer session.Close()
+
+			if tc.env != "" {
+				require.NoError(t, session.Setenv(defaultWorkspaceEnv, tc.env))
+			}
+
+			var stderr bytes.Buffer
+			session.Stderr = &stderr
+			output, err := session.Output("pwd")
+			if tc.err {
+				require.Error(t, err)
+				require.Contains(t, stderr.String(), "access to workspace")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want+"\n", string(output))
+		})
+	}
+}
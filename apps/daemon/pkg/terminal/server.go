@@ -5,11 +5,13 @@ package terminal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"net/http"
+	"os/exec"
 
 	"github.com/daytonaio/daemon/pkg/common"
 	"github.com/gorilla/websocket"
@@ -115,7 +117,8 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		Term:   "xterm-256color",
 		SizeCh: sizeCh,
 	})
-	if err != nil {
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
 		log.Printf("Failed to start pty: %v", err)
 		return
 	}